@@ -0,0 +1,259 @@
+// Package apperror is the error model of the pcbook services. Domain and store errors are
+// converted to gRPC statuses that carry an ErrorInfo reason, BadRequest field violations
+// and a LocalizedMessage, so that clients can react to errors without parsing messages.
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"grpc-project/i18n"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Domain is the ErrorInfo domain of the errors returned by the pcbook services
+const Domain = "pcbook.techschool.dev"
+
+// DefaultLocale is the locale of the messages of the errors
+const DefaultLocale = i18n.English
+
+// ErrAlreadyExists is returned by a store when the record to save already exists
+var ErrAlreadyExists = errors.New("record already exists")
+
+// ErrNotFound is returned by a store when the record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// Reasons are the values of ErrorInfo.Reason, they never change once published
+const (
+	ReasonInvalidArgument      = "INVALID_ARGUMENT"
+	ReasonNotFound             = "NOT_FOUND"
+	ReasonAlreadyExists        = "ALREADY_EXISTS"
+	ReasonLaptopNotFound       = "LAPTOP_NOT_FOUND"
+	ReasonLaptopAlreadyExists  = "LAPTOP_ALREADY_EXISTS"
+	ReasonLaptopHasDependents  = "LAPTOP_HAS_DEPENDENTS"
+	ReasonImageTooLarge        = "IMAGE_TOO_LARGE"
+	ReasonIncorrectCredentials = "INCORRECT_CREDENTIALS"
+	ReasonUnauthenticated      = "UNAUTHENTICATED"
+	ReasonPermissionDenied     = "PERMISSION_DENIED"
+	ReasonCanceled             = "REQUEST_CANCELED"
+	ReasonDeadlineExceeded     = "DEADLINE_EXCEEDED"
+	ReasonStreamBroken         = "STREAM_BROKEN"
+	// ReasonIdempotencyKeyReused is returned when a key is sent again with a different request
+	ReasonIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	// ReasonIdempotencyKeyInProgress is returned while the first request of a key is not finished
+	ReasonIdempotencyKeyInProgress = "IDEMPOTENCY_KEY_IN_PROGRESS"
+	// ReasonTooManyIdempotencyKeys is returned when a caller has too many keys in progress
+	ReasonTooManyIdempotencyKeys = "TOO_MANY_IDEMPOTENCY_KEYS"
+	// ReasonRateLimited is returned when a caller sends too many requests or opens too many streams
+	ReasonRateLimited = "RATE_LIMITED"
+	ReasonInternal    = "INTERNAL"
+)
+
+// FieldViolation tells which field of a request is invalid and why
+type FieldViolation struct {
+	// Field is the path of the field, such as laptop.cpu.number_cores
+	Field       string
+	Description string
+
+	format string
+	args   []interface{}
+}
+
+// Violation returns a field violation whose description is formatted, and translated
+// when the error is localized
+func Violation(field string, format string, args ...interface{}) FieldViolation {
+	return FieldViolation{Field: field, Description: fmt.Sprintf(format, args...), format: format, args: args}
+}
+
+// localize returns the description of the violation in the given locale
+func (violation FieldViolation) localize(locale string) string {
+	if violation.format == "" {
+		return i18n.Sprintf(locale, violation.Description)
+	}
+	return i18n.Sprintf(locale, violation.format, violation.args...)
+}
+
+// Error is an error of a pcbook service. It implements GRPCStatus, so it can be returned
+// by a handler as it is.
+type Error struct {
+	Code    codes.Code
+	Reason  string
+	Message string
+	// Metadata is sent in the ErrorInfo, for example the ID of the laptop that is not found
+	Metadata   map[string]string
+	Violations []FieldViolation
+	// Details are sent after the BadRequest, for example the QuotaFailure of a rate limit
+	Details []protoadapt.MessageV1
+	// Cause is logged but never sent to the client
+	Cause error
+
+	// format and args are kept to translate the message
+	format string
+	args   []interface{}
+}
+
+// New returns a new error with a formatted message
+func New(code codes.Code, reason string, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Reason: reason, Message: fmt.Sprintf(format, args...), format: format, args: args}
+}
+
+// InvalidArgument returns a new InvalidArgument error with field violations
+func InvalidArgument(message string, violations ...FieldViolation) *Error {
+	return &Error{Code: codes.InvalidArgument, Reason: ReasonInvalidArgument, Message: message, Violations: violations}
+}
+
+// Internal returns a new Internal error. The message tells what failed, the cause is only logged.
+func Internal(cause error, message string) *Error {
+	return &Error{Code: codes.Internal, Reason: ReasonInternal, Message: message, Cause: cause}
+}
+
+// Wrap converts err like From, but an error that would become Internal gets the given message
+func Wrap(err error, message string) *Error {
+	appErr := From(err)
+	if appErr != nil && appErr.Code == codes.Internal && appErr.Reason == ReasonInternal && appErr.Cause == err {
+		return Internal(err, message)
+	}
+	return appErr
+}
+
+// WithViolations returns the error with field violations added to its BadRequest details
+func (err *Error) WithViolations(violations ...FieldViolation) *Error {
+	other := *err
+	other.Violations = append(append([]FieldViolation{}, err.Violations...), violations...)
+	return &other
+}
+
+// WithDetails returns the error with details added to its status
+func (err *Error) WithDetails(details ...protoadapt.MessageV1) *Error {
+	other := *err
+	other.Details = append(append([]protoadapt.MessageV1{}, err.Details...), details...)
+	return &other
+}
+
+// WithMetadata returns the error with a key-value pair added to its ErrorInfo metadata
+func (err *Error) WithMetadata(key string, value string) *Error {
+	metadata := make(map[string]string, len(err.Metadata)+1)
+	for k, v := range err.Metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+
+	other := *err
+	other.Metadata = metadata
+	return &other
+}
+
+// WithCause returns the error with a cause that is logged but not sent to the client
+func (err *Error) WithCause(cause error) *Error {
+	other := *err
+	other.Cause = cause
+	return &other
+}
+
+// Error returns the message followed by the cause
+func (err *Error) Error() string {
+	if err.Cause != nil {
+		return err.Message + ": " + err.Cause.Error()
+	}
+	return err.Message
+}
+
+// Unwrap returns the cause of the error
+func (err *Error) Unwrap() error {
+	return err.Cause
+}
+
+// GRPCStatus returns the status of the error with its details in the default locale
+func (err *Error) GRPCStatus() *status.Status {
+	return err.Localize(DefaultLocale)
+}
+
+// Localize returns the status of the error with the message and the field violations
+// translated to the given locale. A message without translation stays in English.
+func (err *Error) Localize(locale string) *status.Status {
+	message := i18n.Sprintf(locale, err.Message)
+	if err.format != "" {
+		message = i18n.Sprintf(locale, err.format, err.args...)
+	}
+
+	localized := *err
+	localized.Violations = make([]FieldViolation, len(err.Violations))
+	for i, violation := range err.Violations {
+		localized.Violations[i] = FieldViolation{Field: violation.Field, Description: violation.localize(locale)}
+	}
+	return localized.Status(locale, message)
+}
+
+// Status returns the status of the error with the message in the given locale
+func (err *Error) Status(locale string, message string) *status.Status {
+	details := []protoadapt.MessageV1{
+		&errdetails.ErrorInfo{Reason: err.Reason, Domain: Domain, Metadata: err.Metadata},
+	}
+	if len(err.Violations) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, violation := range err.Violations {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       violation.Field,
+				Description: violation.Description,
+			})
+		}
+		details = append(details, badRequest)
+	}
+	details = append(details, err.Details...)
+	details = append(details, &errdetails.LocalizedMessage{Locale: locale, Message: message})
+
+	st, detailsErr := status.New(err.Code, message).WithDetails(details...)
+	if detailsErr != nil {
+		return status.New(err.Code, message)
+	}
+	return st
+}
+
+// From converts any error to an *Error. Errors of this package are returned as they are,
+// store and context errors are mapped to their status codes and other errors become
+// Internal errors whose message does not reveal the cause.
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return &Error{Code: codes.NotFound, Reason: ReasonNotFound, Message: "record not found", Cause: err}
+	case errors.Is(err, ErrAlreadyExists):
+		return &Error{Code: codes.AlreadyExists, Reason: ReasonAlreadyExists, Message: "record already exists", Cause: err}
+	case errors.Is(err, context.Canceled):
+		return &Error{Code: codes.Canceled, Reason: ReasonCanceled, Message: "request is canceled", Cause: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Code: codes.DeadlineExceeded, Reason: ReasonDeadlineExceeded, Message: "deadline is exceeded", Cause: err}
+	}
+
+	if st, ok := status.FromError(err); ok {
+		return &Error{Code: st.Code(), Reason: codeReason(st.Code()), Message: st.Message()}
+	}
+	return Internal(err, "internal error")
+}
+
+// codeReason turns a status code such as InvalidArgument into a reason such as INVALID_ARGUMENT
+func codeReason(code codes.Code) string {
+	var reason strings.Builder
+	for i, r := range code.String() {
+		if i > 0 && unicode.IsUpper(r) {
+			reason.WriteByte('_')
+		}
+		reason.WriteRune(unicode.ToUpper(r))
+	}
+	return reason.String()
+}
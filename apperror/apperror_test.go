@@ -0,0 +1,93 @@
+package apperror_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"grpc-project/apperror"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	err := apperror.InvalidArgument("laptop is invalid", apperror.FieldViolation{
+		Field:       "laptop.cpu.number_cores",
+		Description: "must be positive",
+	}).WithMetadata("laptop_id", "42")
+
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Equal(t, "laptop is invalid", st.Message())
+
+	details := st.Details()
+	require.Len(t, details, 3)
+
+	info, ok := details[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	require.Equal(t, apperror.ReasonInvalidArgument, info.GetReason())
+	require.Equal(t, apperror.Domain, info.GetDomain())
+	require.Equal(t, map[string]string{"laptop_id": "42"}, info.GetMetadata())
+
+	badRequest, ok := details[1].(*errdetails.BadRequest)
+	require.True(t, ok)
+	require.Len(t, badRequest.GetFieldViolations(), 1)
+	require.Equal(t, "laptop.cpu.number_cores", badRequest.GetFieldViolations()[0].GetField())
+	require.Equal(t, "must be positive", badRequest.GetFieldViolations()[0].GetDescription())
+
+	localized, ok := details[2].(*errdetails.LocalizedMessage)
+	require.True(t, ok)
+	require.Equal(t, apperror.DefaultLocale, localized.GetLocale())
+	require.Equal(t, "laptop is invalid", localized.GetMessage())
+
+	// without violations there is no BadRequest
+	details = status.Convert(apperror.New(codes.NotFound, apperror.ReasonLaptopNotFound, "laptop %s is not found", "42")).Details()
+	require.Len(t, details, 2)
+	require.IsType(t, &errdetails.LocalizedMessage{}, details[1])
+}
+
+func TestFrom(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, apperror.From(nil))
+
+	appErr := apperror.New(codes.NotFound, apperror.ReasonLaptopNotFound, "laptop is not found")
+	require.Same(t, appErr, apperror.From(fmt.Errorf("cannot find: %w", appErr)))
+
+	testCases := []struct {
+		err    error
+		code   codes.Code
+		reason string
+	}{
+		{fmt.Errorf("cannot find laptop: %w", apperror.ErrNotFound), codes.NotFound, apperror.ReasonNotFound},
+		{fmt.Errorf("cannot save laptop: %w", apperror.ErrAlreadyExists), codes.AlreadyExists, apperror.ReasonAlreadyExists},
+		{context.Canceled, codes.Canceled, apperror.ReasonCanceled},
+		{fmt.Errorf("cannot save image: %w", context.DeadlineExceeded), codes.DeadlineExceeded, apperror.ReasonDeadlineExceeded},
+		{status.Error(codes.PermissionDenied, "no access"), codes.PermissionDenied, "PERMISSION_DENIED"},
+		{status.Error(codes.ResourceExhausted, "too many"), codes.ResourceExhausted, "RESOURCE_EXHAUSTED"},
+		{errors.New("disk is full"), codes.Internal, apperror.ReasonInternal},
+	}
+	for _, tc := range testCases {
+		appErr := apperror.From(tc.err)
+		require.Equal(t, tc.code, appErr.Code, tc.err.Error())
+		require.Equal(t, tc.reason, appErr.Reason, tc.err.Error())
+	}
+
+	// the cause of an internal error is kept for the logs but not sent to the client
+	cause := errors.New("disk is full")
+	err := apperror.Wrap(cause, "cannot save laptop")
+	require.ErrorIs(t, err, cause)
+	require.Equal(t, "cannot save laptop: disk is full", err.Error())
+	require.Equal(t, "cannot save laptop", status.Convert(err).Message())
+
+	// errors with a known code keep their message
+	err = apperror.Wrap(apperror.ErrNotFound, "cannot save laptop")
+	require.Equal(t, codes.NotFound, err.Code)
+	require.Equal(t, "record not found", err.Message)
+}
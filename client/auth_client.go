@@ -2,7 +2,6 @@ package client
 
 import (
 	"context"
-	"time"
 
 	"grpc-project/example.com/pcbook/pb"
 	"google.golang.org/grpc"
@@ -22,10 +21,7 @@ func NewAuthClient(cc *grpc.ClientConn, username string, password string) *AuthC
 }
 
 // Login login user and returns the access token
-func (client *AuthClient) Login() (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
+func (client *AuthClient) Login(ctx context.Context) (string, error) {
 	req := &pb.LoginRequest{
 		Username: client.username,
 		Password: client.password,
@@ -2,7 +2,7 @@ package client
 
 import (
 	"context"
-	"log"
+	"sync"
 	"time"
 
 	"google.golang.org/grpc"
@@ -13,11 +13,16 @@ import (
 type AuthInterceptor struct {
 	authClient  *AuthClient
 	authMethods map[string]bool
+	mutex       sync.RWMutex
 	accessToken string
+	stop        context.CancelFunc
+	stopped     chan struct{}
 }
 
-// NewAuthInterceptor returns a new auth interceptor
+// NewAuthInterceptor returns a new auth interceptor. It logs in with ctx, then refreshes
+// the token in the background every refreshDuration until Close is called.
 func NewAuthInterceptor(
+	ctx context.Context,
 	authClient *AuthClient,
 	authMethods map[string]bool,
 	refreshDuration time.Duration,
@@ -25,9 +30,10 @@ func NewAuthInterceptor(
 	interceptor := &AuthInterceptor{
 		authClient:  authClient,
 		authMethods: authMethods,
+		stopped:     make(chan struct{}),
 	}
 
-	err := interceptor.scheduleRefreshToken(refreshDuration)
+	err := interceptor.scheduleRefreshToken(ctx, refreshDuration)
 	if err != nil {
 		return nil, err
 	}
@@ -45,8 +51,6 @@ func (interceptor *AuthInterceptor) Unary() grpc.UnaryClientInterceptor {
 		invoker grpc.UnaryInvoker,
 		opts ...grpc.CallOption,
 	) error {
-		log.Printf("--> unary interceptor: %s", method)
-
 		if interceptor.authMethods[method] {
 			return invoker(interceptor.attachToken(ctx), method, req, reply, cc, opts...)
 		}
@@ -65,8 +69,6 @@ func (interceptor *AuthInterceptor) Stream() grpc.StreamClientInterceptor {
 		streamer grpc.Streamer,
 		opts ...grpc.CallOption,
 	) (grpc.ClientStream, error) {
-		log.Printf("--> stream interceptor: %s", method)
-
 		if interceptor.authMethods[method] {
 			return streamer(interceptor.attachToken(ctx), desc, cc, method, opts...)
 		}
@@ -75,21 +77,47 @@ func (interceptor *AuthInterceptor) Stream() grpc.StreamClientInterceptor {
 	}
 }
 
+// Close stops refreshing the token and waits for a refresh in progress to end.
+// The token that is already received is still attached to the calls.
+func (interceptor *AuthInterceptor) Close() {
+	interceptor.stop()
+	<-interceptor.stopped
+}
+
 func (interceptor *AuthInterceptor) attachToken(ctx context.Context) context.Context {
+	interceptor.mutex.RLock()
+	defer interceptor.mutex.RUnlock()
+
 	return metadata.AppendToOutgoingContext(ctx, "authorization", interceptor.accessToken)
 }
 
-func (interceptor *AuthInterceptor) scheduleRefreshToken(refreshDuration time.Duration) error {
-	err := interceptor.refreshToken()
+func (interceptor *AuthInterceptor) scheduleRefreshToken(ctx context.Context, refreshDuration time.Duration) error {
+	err := interceptor.refreshToken(ctx)
 	if err != nil {
 		return err
 	}
 
+	// the refresh lives until Close, not as long as ctx which is only meant for the first login
+	refreshCtx, stop := context.WithCancel(context.Background())
+	interceptor.stop = stop
+
 	go func() {
+		defer close(interceptor.stopped)
+
 		wait := refreshDuration
 		for {
-			time.Sleep(wait)
-			err := interceptor.refreshToken()
+			timer := time.NewTimer(wait)
+			select {
+			case <-refreshCtx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			// a login that takes longer than refreshDuration is given up and tried again
+			ctx, cancel := context.WithTimeout(refreshCtx, refreshDuration)
+			err := interceptor.refreshToken(ctx)
+			cancel()
 			if err != nil {
 				wait = time.Second
 			} else {
@@ -101,14 +129,15 @@ func (interceptor *AuthInterceptor) scheduleRefreshToken(refreshDuration time.Du
 	return nil
 }
 
-func (interceptor *AuthInterceptor) refreshToken() error {
-	accessToken, err := interceptor.authClient.Login()
+func (interceptor *AuthInterceptor) refreshToken(ctx context.Context) error {
+	accessToken, err := interceptor.authClient.Login(ctx)
 	if err != nil {
 		return err
 	}
 
-	interceptor.accessToken = accessToken
-	log.Printf("token refreshed: %v", accessToken)
+	interceptor.mutex.Lock()
+	defer interceptor.mutex.Unlock()
 
+	interceptor.accessToken = accessToken
 	return nil
 }
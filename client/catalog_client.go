@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"grpc-project/example.com/pcbook/pb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ImportCatalogResult is the outcome of one entry sent to the import catalog RPC
+type ImportCatalogResult struct {
+	// Index is the position of the entry in the stream, starting from 0
+	Index int
+	Entry *pb.CatalogEntry
+	// ID is the ID of the imported laptop, it is empty if the entry is not imported
+	ID              string
+	ImportedRatings int
+	// Status tells why the entry is not imported, it is nil if the entry is imported
+	Status *pb.ItemStatus
+}
+
+// Err returns the status of an entry that is not imported as an error, or nil
+func (result ImportCatalogResult) Err() error {
+	if result.Status == nil {
+		return nil
+	}
+	return status.Error(codes.Code(result.Status.GetCode()), result.Status.GetMessage())
+}
+
+// ExportCatalog calls export catalog RPC, handle is called with every laptop of the catalog
+// and its ratings. An error returned by handle cancels the RPC and is returned.
+func (laptopClient *LaptopClient) ExportCatalog(ctx context.Context, handle func(entry *pb.CatalogEntry) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := laptopClient.service.ExportCatalog(ctx, &pb.ExportCatalogRequest{})
+	if err != nil {
+		return fmt.Errorf("cannot export catalog: %w", err)
+	}
+
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot receive stream response: %w", err)
+		}
+
+		err = handle(entry)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ImportCatalog calls import catalog RPC. Like CreateLaptops, entries are sent while the responses
+// are received with at most window entries waiting for their response, and handle is called in
+// order for every entry. If validateOnly is true, the server checks the entries without saving them.
+func (laptopClient *LaptopClient) ImportCatalog(
+	ctx context.Context,
+	entries <-chan *pb.CatalogEntry,
+	validateOnly bool,
+	window int,
+	handle func(result ImportCatalogResult),
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := laptopClient.service.ImportCatalog(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot import catalog: %w", err)
+	}
+
+	return pipeline(ctx, cancel, stream, entries, window,
+		func(entry *pb.CatalogEntry) *pb.ImportCatalogRequest {
+			return &pb.ImportCatalogRequest{Entry: entry, ValidateOnly: validateOnly}
+		},
+		func(index int, entry *pb.CatalogEntry, res *pb.ImportCatalogResponse) {
+			handle(ImportCatalogResult{
+				Index:           index,
+				Entry:           entry,
+				ID:              res.GetId(),
+				ImportedRatings: int(res.GetImportedRatings()),
+				Status:          res.GetStatus(),
+			})
+		},
+	)
+}
@@ -1,22 +1,27 @@
 package client
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
-	"log"
-	"os"
-	"path/filepath"
-	"time"
 
 	"grpc-project/example.com/pcbook/pb"
+	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
-// LaptopClient is a client to call laptop service RPCs
+// IdempotencyKeyKey is the metadata key of the idempotency key, the server replays the response
+// of the first request sent with a key instead of running the request again
+const IdempotencyKeyKey = "idempotency-key"
+
+// imageChunkSize is the size of the image data sent in each upload image request
+const imageChunkSize = 1024
+
+// LaptopClient is a client to call laptop service RPCs. Its methods do not set a deadline,
+// the context of each call should have one. Dial with WithRetryPolicy to retry idempotent calls.
 type LaptopClient struct {
 	service pb.LaptopServiceClient
 }
@@ -27,175 +32,282 @@ func NewLaptopClient(cc *grpc.ClientConn) *LaptopClient {
 	return &LaptopClient{service}
 }
 
-// CreateLaptop calls create laptop RPC
-func (laptopClient *LaptopClient) CreateLaptop(laptop *pb.Laptop) {
+// WithIdempotencyKey returns a copy of ctx that sends the given idempotency key
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, IdempotencyKeyKey, key)
+}
+
+// withIdempotencyKey returns ctx if it already sends an idempotency key, or a copy of ctx that sends a new one
+func withIdempotencyKey(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if len(md.Get(IdempotencyKeyKey)) > 0 {
+		return ctx
+	}
+	return WithIdempotencyKey(ctx, uuid.NewString())
+}
+
+// CreateLaptop calls create laptop RPC and returns the ID of the laptop. The request is sent with
+// an idempotency key, so a retried attempt creates the laptop once even if the server generates its ID.
+// A laptop that already exists fails with codes.AlreadyExists.
+func (laptopClient *LaptopClient) CreateLaptop(ctx context.Context, laptop *pb.Laptop) (string, error) {
 	req := &pb.CreateLaptopRequest{
 		Laptop: laptop,
 	}
 
-	// set timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	res, err := laptopClient.service.CreateLaptop(withIdempotencyKey(ctx), req)
+	if err != nil {
+		return "", fmt.Errorf("cannot create laptop: %w", err)
+	}
+
+	return res.GetId(), nil
+}
+
+// CreateLaptopsResult is the outcome of one laptop sent to the create laptops RPC
+type CreateLaptopsResult struct {
+	// Index is the position of the laptop in the stream, starting from 0
+	Index  int
+	Laptop *pb.Laptop
+	// ID is the ID of the created laptop, it is empty if the laptop is not created
+	ID string
+	// Status tells why the laptop is not created, it is nil if the laptop is created
+	Status *pb.ItemStatus
+}
+
+// Err returns the status of a laptop that is not created as an error, or nil
+func (result CreateLaptopsResult) Err() error {
+	if result.Status == nil {
+		return nil
+	}
+	return status.Error(codes.Code(result.Status.GetCode()), result.Status.GetMessage())
+}
+
+// CreateLaptops calls create laptops RPC. Laptops are sent while the responses are received,
+// with at most window laptops waiting for their response: if the server or handle is slow,
+// no more laptops are read from the channel until they catch up. handle is called in order
+// for every laptop, a laptop that cannot be created does not stop the others. The RPC ends
+// when the channel is closed and every response is received, or when it fails.
+func (laptopClient *LaptopClient) CreateLaptops(
+	ctx context.Context,
+	laptops <-chan *pb.Laptop,
+	window int,
+	handle func(result CreateLaptopsResult),
+) error {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	res, err := laptopClient.service.CreateLaptop(ctx, req)
+	stream, err := laptopClient.service.CreateLaptops(ctx)
 	if err != nil {
-		st, ok := status.FromError(err)
-		if ok && st.Code() == codes.AlreadyExists {
-			// not a big deal
-			log.Print("laptop already exists")
-		} else {
-			log.Fatal("cannot create laptop: ", err)
-		}
-		return
+		return fmt.Errorf("cannot create laptops: %w", err)
 	}
 
-	log.Printf("created laptop with id: %s", res.Id)
+	return pipeline(ctx, cancel, stream, laptops, window,
+		func(laptop *pb.Laptop) *pb.CreateLaptopsRequest {
+			return &pb.CreateLaptopsRequest{Laptop: laptop}
+		},
+		func(index int, laptop *pb.Laptop, res *pb.CreateLaptopsResponse) {
+			handle(CreateLaptopsResult{
+				Index:  index,
+				Laptop: laptop,
+				ID:     res.GetId(),
+				Status: res.GetStatus(),
+			})
+		},
+	)
 }
 
-// SearchLaptop calls search laptop RPC
-func (laptopClient *LaptopClient) SearchLaptop(filter *pb.Filter) {
-	log.Print("search filter: ", filter)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// SearchLaptop calls search laptop RPC, handle is called with every laptop that matches the filter
+// of req together with its score, in the order of req.SortBy. An error returned by handle cancels
+// the RPC and is returned.
+func (laptopClient *LaptopClient) SearchLaptop(
+	ctx context.Context,
+	req *pb.SearchLaptopRequest,
+	handle func(res *pb.SearchLaptopResponse) error,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	req := &pb.SearchLaptopRequest{Filter: filter}
 	stream, err := laptopClient.service.SearchLaptop(ctx, req)
 	if err != nil {
-		log.Fatal("cannot search laptop: ", err)
+		return fmt.Errorf("cannot search laptop: %w", err)
 	}
 
 	for {
 		res, err := stream.Recv()
 		if err == io.EOF {
-			return
+			return nil
 		}
 		if err != nil {
-			log.Fatal("cannot receive response: ", err)
+			return fmt.Errorf("cannot receive stream response: %w", err)
 		}
 
-		laptop := res.GetLaptop()
-		log.Print("- found: ", laptop.GetId())
-		log.Print("  + brand: ", laptop.GetBrand())
-		log.Print("  + name: ", laptop.GetName())
-		log.Print("  + cpu cores: ", laptop.GetCpu().GetNumberCores())
-		log.Print("  + cpu min ghz: ", laptop.GetCpu().GetMinGhz())
-		log.Print("  + ram: ", laptop.GetRam())
-		log.Print("  + price: ", laptop.GetPriceUsd())
+		err = handle(res)
+		if err != nil {
+			return err
+		}
 	}
 }
 
-// UploadImage calls upload image RPC
-func (laptopClient *LaptopClient) UploadImage(laptopID string, imagePath string) {
-	file, err := os.Open(imagePath)
+// GetLaptop calls get laptop RPC and returns the laptop with its score.
+// A laptop that does not exist fails with codes.NotFound.
+func (laptopClient *LaptopClient) GetLaptop(ctx context.Context, laptopID string) (*pb.GetLaptopResponse, error) {
+	req := &pb.GetLaptopRequest{Id: laptopID}
+
+	res, err := laptopClient.service.GetLaptop(ctx, req)
 	if err != nil {
-		log.Fatal("cannot open image file: ", err)
+		return nil, fmt.Errorf("cannot get laptop: %w", err)
 	}
-	defer file.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return res, nil
+}
+
+// DeleteLaptop calls delete laptop RPC. A laptop with images or ratings is only deleted
+// with cascade, which deletes them as well, otherwise the call fails with codes.FailedPrecondition.
+func (laptopClient *LaptopClient) DeleteLaptop(ctx context.Context, laptopID string, cascade bool) (*pb.DeleteLaptopResponse, error) {
+	req := &pb.DeleteLaptopRequest{
+		Id:      laptopID,
+		Cascade: cascade,
+	}
+
+	res, err := laptopClient.service.DeleteLaptop(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot delete laptop: %w", err)
+	}
+
+	return res, nil
+}
+
+// UploadImage calls upload image RPC with the image read from image, imageType is its extension
+// such as .jpg. Like CreateLaptop, the request is sent with an idempotency key, so a retried
+// attempt saves the image once.
+func (laptopClient *LaptopClient) UploadImage(
+	ctx context.Context,
+	laptopID string,
+	imageType string,
+	image io.Reader,
+) (*pb.UploadImageResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	stream, err := laptopClient.service.UploadImage(ctx)
+	stream, err := laptopClient.service.UploadImage(withIdempotencyKey(ctx))
 	if err != nil {
-		log.Fatal("cannot upload image: ", err)
+		return nil, fmt.Errorf("cannot upload image: %w", err)
 	}
 
 	req := &pb.UploadImageRequest{
 		Data: &pb.UploadImageRequest_Info{
 			Info: &pb.ImageInfo{
 				LaptopId:  laptopID,
-				ImageType: filepath.Ext(imagePath),
+				ImageType: imageType,
 			},
 		},
 	}
 
 	err = stream.Send(req)
 	if err != nil {
-		log.Fatal("cannot send image info to server: ", err, stream.RecvMsg(nil))
+		// the status of the RPC is returned by RecvMsg
+		return nil, fmt.Errorf("cannot upload image: %w", stream.RecvMsg(nil))
 	}
 
-	reader := bufio.NewReader(file)
-	buffer := make([]byte, 1024)
-
+	buffer := make([]byte, imageChunkSize)
 	for {
-		n, err := reader.Read(buffer)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatal("cannot read chunk to buffer: ", err)
-		}
+		n, err := io.ReadFull(image, buffer)
+		if n > 0 {
+			req := &pb.UploadImageRequest{
+				Data: &pb.UploadImageRequest_ChunkData{
+					ChunkData: buffer[:n],
+				},
+			}
 
-		req := &pb.UploadImageRequest{
-			Data: &pb.UploadImageRequest_ChunkData{
-				ChunkData: buffer[:n],
-			},
+			sendErr := stream.Send(req)
+			if sendErr != nil {
+				return nil, fmt.Errorf("cannot upload image: %w", stream.RecvMsg(nil))
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
 		}
-
-		err = stream.Send(req)
 		if err != nil {
-			log.Fatal("cannot send chunk to server: ", err, stream.RecvMsg(nil))
+			return nil, fmt.Errorf("cannot read image: %w", err)
 		}
 	}
 
 	res, err := stream.CloseAndRecv()
 	if err != nil {
-		log.Fatal("cannot receive response: ", err)
+		return nil, fmt.Errorf("cannot upload image: %w", err)
 	}
 
-	log.Printf("image uploaded with id: %s, size: %d", res.GetId(), res.GetSize())
+	return res, nil
 }
 
-// RateLaptop calls rate laptop RPC
-func (laptopClient *LaptopClient) RateLaptop(laptopIDs []string, scores []float64) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// RateLaptop calls rate laptop RPC with every rating, a rating may have a review.
+// It returns the response of every rating in order.
+func (laptopClient *LaptopClient) RateLaptop(ctx context.Context, ratings []*pb.RateLaptopRequest) ([]*pb.RateLaptopResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	stream, err := laptopClient.service.RateLaptop(ctx)
 	if err != nil {
-		return fmt.Errorf("cannot rate laptop: %v", err)
+		return nil, fmt.Errorf("cannot rate laptop: %w", err)
 	}
 
-	waitResponse := make(chan error)
+	responses := []*pb.RateLaptopResponse{}
+	waitResponse := make(chan error, 1)
 	// go routine to receive responses
 	go func() {
 		for {
 			res, err := stream.Recv()
 			if err == io.EOF {
-				log.Print("no more responses")
 				waitResponse <- nil
 				return
 			}
 			if err != nil {
-				waitResponse <- fmt.Errorf("cannot receive stream response: %v", err)
+				waitResponse <- fmt.Errorf("cannot receive stream response: %w", err)
 				return
 			}
 
-			log.Print("received response: ", res)
+			responses = append(responses, res)
 		}
 	}()
 
 	// send requests
-	for i, laptopID := range laptopIDs {
-		req := &pb.RateLaptopRequest{
-			LaptopId: laptopID,
-			Score:    scores[i],
-		}
-
+	for _, req := range ratings {
 		err := stream.Send(req)
 		if err != nil {
-			return fmt.Errorf("cannot send stream request: %v - %v", err, stream.RecvMsg(nil))
+			// the stream is broken, the receiver gets its status
+			break
 		}
-
-		log.Print("sent request: ", req)
 	}
 
 	err = stream.CloseSend()
 	if err != nil {
-		return fmt.Errorf("cannot close send: %v", err)
+		return nil, fmt.Errorf("cannot close send: %w", err)
 	}
 
 	err = <-waitResponse
-	return err
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// GetLaptopRatings calls get laptop ratings RPC
+func (laptopClient *LaptopClient) GetLaptopRatings(
+	ctx context.Context,
+	laptopID string,
+	pageSize uint32,
+	pageToken string,
+) (*pb.GetLaptopRatingsResponse, error) {
+	req := &pb.GetLaptopRatingsRequest{
+		LaptopId:  laptopID,
+		PageSize:  pageSize,
+		PageToken: pageToken,
+	}
+
+	res, err := laptopClient.service.GetLaptopRatings(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get laptop ratings: %w", err)
+	}
+
+	return res, nil
 }
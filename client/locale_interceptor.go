@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AcceptLanguageKey is the metadata key that tells the server the language of the error messages
+const AcceptLanguageKey = "accept-language"
+
+// LocaleInterceptor is a client interceptor that asks the server for error messages in a language
+type LocaleInterceptor struct {
+	acceptLanguage string
+}
+
+// NewLocaleInterceptor returns a new locale interceptor. acceptLanguage has the format of
+// an Accept-Language header, such as "th" or "th-TH,en;q=0.5".
+func NewLocaleInterceptor(acceptLanguage string) *LocaleInterceptor {
+	return &LocaleInterceptor{acceptLanguage: acceptLanguage}
+}
+
+// WithAcceptLanguage returns a copy of ctx that asks for error messages in the given languages
+func WithAcceptLanguage(ctx context.Context, acceptLanguage string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, AcceptLanguageKey, acceptLanguage)
+}
+
+// LocalizedMessage returns the localized message of an error returned by the server and its
+// locale, or the status message and an empty locale if the server did not localize it
+func LocalizedMessage(err error) (string, string) {
+	st := status.Convert(err)
+	for _, detail := range st.Details() {
+		if localized, ok := detail.(*errdetails.LocalizedMessage); ok {
+			return localized.GetMessage(), localized.GetLocale()
+		}
+	}
+	return st.Message(), ""
+}
+
+// Unary returns a client interceptor to send the accept-language metadata with unary RPC
+func (interceptor *LocaleInterceptor) Unary() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		return invoker(interceptor.attachLocale(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// Stream returns a client interceptor to send the accept-language metadata with stream RPC
+func (interceptor *LocaleInterceptor) Stream() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		return streamer(interceptor.attachLocale(ctx), desc, cc, method, opts...)
+	}
+}
+
+// attachLocale adds the accept-language metadata unless the caller already set it with WithAcceptLanguage
+func (interceptor *LocaleInterceptor) attachLocale(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if interceptor.acceptLanguage == "" || len(md.Get(AcceptLanguageKey)) > 0 {
+		return ctx
+	}
+	return WithAcceptLanguage(ctx, interceptor.acceptLanguage)
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey is the metadata key that carries the ID of a request, the server logs it with every message
+const RequestIDKey = "x-request-id"
+
+// LoggingInterceptor is a client interceptor that sends a request ID with every RPC and logs the RPC
+type LoggingInterceptor struct {
+	logger *slog.Logger
+}
+
+// NewLoggingInterceptor returns a new logging interceptor
+func NewLoggingInterceptor(logger *slog.Logger) *LoggingInterceptor {
+	return &LoggingInterceptor{logger: logger}
+}
+
+// WithRequestID returns a copy of ctx that sends the given request ID instead of a generated one
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, RequestIDKey, requestID)
+}
+
+// Unary returns a client interceptor to log unary RPC
+func (interceptor *LoggingInterceptor) Unary() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		ctx, requestID := withRequestID(ctx)
+
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		interceptor.logResult(ctx, method, requestID, start, err)
+		return err
+	}
+}
+
+// Stream returns a client interceptor to log stream RPC, only the opening of the stream is logged
+func (interceptor *LoggingInterceptor) Stream() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		ctx, requestID := withRequestID(ctx)
+
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		interceptor.logResult(ctx, method, requestID, start, err)
+		return stream, err
+	}
+}
+
+func (interceptor *LoggingInterceptor) logResult(ctx context.Context, method string, requestID string, start time.Time, err error) {
+	attrs := []any{
+		"request_id", requestID,
+		"method", method,
+		"code", status.Code(err).String(),
+		"duration", time.Since(start),
+	}
+
+	if err != nil {
+		interceptor.logger.WarnContext(ctx, "rpc failed", append(attrs, "error", status.Convert(err).Message())...)
+		return
+	}
+	interceptor.logger.DebugContext(ctx, "rpc finished", attrs...)
+}
+
+// withRequestID returns the request ID already set in the outgoing metadata of ctx,
+// or a context that sends a newly generated one
+func withRequestID(ctx context.Context) (context.Context, string) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if values := md.Get(RequestIDKey); len(values) > 0 {
+		return ctx, values[0]
+	}
+
+	requestID := uuid.NewString()
+	return WithRequestID(ctx, requestID), requestID
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// bidiStream is a bidirectional stream whose server sends one response per request, in order
+type bidiStream[Req any, Res any] interface {
+	Send(req Req) error
+	Recv() (Res, error)
+	CloseSend() error
+}
+
+// pipeline sends a request for every item of the channel while it receives the responses, with
+// at most window items waiting for their response: if the server or handle is slow, no more
+// items are read from the channel until they catch up. handle is called in order with every
+// item and its response. cancel must cancel the context of the stream.
+func pipeline[T any, Req any, Res any](
+	ctx context.Context,
+	cancel context.CancelFunc,
+	stream bidiStream[Req, Res],
+	items <-chan T,
+	window int,
+	request func(item T) Req,
+	handle func(index int, item T, res Res),
+) error {
+	if window < 1 {
+		window = 1
+	}
+
+	// pending holds the items sent without a response yet, its capacity is the window
+	pending := make(chan T, window)
+	sendErr := make(chan error, 1)
+	go func() {
+		err := sendItems(ctx, stream, items, pending, request)
+		if err != nil {
+			// a request that cannot be sent, such as one that cannot be marshaled,
+			// does not end the stream, so Recv would wait for its response forever
+			cancel()
+		}
+		sendErr <- err
+	}()
+
+	for index := 0; ; index++ {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			// the server ends the stream after CloseSend, unless it stopped early
+			cancel()
+			return <-sendErr
+		}
+		if err != nil {
+			// stop the sender before returning, so that it does not read the channel anymore
+			cancel()
+			if sendErr := <-sendErr; sendErr != nil && sendErr != ctx.Err() {
+				// the stream is canceled because the sender failed
+				return sendErr
+			}
+			return fmt.Errorf("cannot receive stream response: %w", err)
+		}
+
+		handle(index, <-pending, res)
+	}
+}
+
+// sendItems sends the items of the channel until it is closed. Every item is added to
+// pending before it is sent, which blocks while the window is full.
+func sendItems[T any, Req any, Res any](
+	ctx context.Context,
+	stream bidiStream[Req, Res],
+	items <-chan T,
+	pending chan<- T,
+	request func(item T) Req,
+) error {
+	for {
+		var item T
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case item, ok = <-items:
+		}
+		if !ok {
+			err := stream.CloseSend()
+			if err != nil {
+				return fmt.Errorf("cannot close send: %w", err)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case pending <- item:
+		}
+
+		err := stream.Send(request(item))
+		if err == io.EOF {
+			// the stream is broken, Recv returns its status
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot send stream request: %w", err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// RetryPolicy tells gRPC how to retry the idempotent calls of the pcbook services.
+// Attempts share the deadline of the context of the call, so a call that times out is not retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one, gRPC allows at most 5
+	MaxAttempts int
+	// InitialBackoff is the longest wait before the second attempt, the wait is randomized
+	InitialBackoff time.Duration
+	// MaxBackoff bounds the wait between attempts
+	MaxBackoff time.Duration
+	// BackoffMultiplier grows the wait with every attempt
+	BackoffMultiplier float64
+	// RetryableStatusCodes are the codes of the attempts that are retried
+	RetryableStatusCodes []codes.Code
+}
+
+// DefaultRetryPolicy retries a call up to 3 times while the server is unavailable,
+// or while a request with the same idempotency key is in progress
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:          3,
+	InitialBackoff:       500 * time.Millisecond,
+	MaxBackoff:           5 * time.Second,
+	BackoffMultiplier:    2,
+	RetryableStatusCodes: []codes.Code{codes.Unavailable, codes.Aborted},
+}
+
+// idempotentMethods are the methods that are safe to send again. CreateLaptop and UploadImage
+// are sent with an idempotency key, so the server replays the response of the first attempt.
+// A stream is only retried until the first response is received, and a client stream only
+// while the messages sent so far fit in the retry buffer of gRPC (256 KiB by default).
+var idempotentMethods = []methodName{
+	{"techshcool.pcbook.AuthService", "Login"},
+	{"techshcool.pcbook.LaptopService", "CreateLaptop"},
+	{"techshcool.pcbook.LaptopService", "UploadImage"},
+	{"techshcool.pcbook.LaptopService", "SearchLaptop"},
+	{"techshcool.pcbook.LaptopService", "GetLaptop"},
+	{"techshcool.pcbook.LaptopService", "GetLaptopRatings"},
+	{"techshcool.pcbook.LaptopService", "ExportCatalog"},
+}
+
+type serviceConfig struct {
+	MethodConfig []methodConfig `json:"methodConfig"`
+}
+
+type methodConfig struct {
+	Name        []methodName      `json:"name"`
+	RetryPolicy retryPolicyConfig `json:"retryPolicy"`
+}
+
+type methodName struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+}
+
+type retryPolicyConfig struct {
+	MaxAttempts          int      `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+// ServiceConfig returns the gRPC service config in JSON that applies the policy to the idempotent methods
+func (policy RetryPolicy) ServiceConfig() string {
+	config := methodConfig{
+		Name: idempotentMethods,
+		RetryPolicy: retryPolicyConfig{
+			MaxAttempts:       policy.MaxAttempts,
+			InitialBackoff:    durationConfig(policy.InitialBackoff),
+			MaxBackoff:        durationConfig(policy.MaxBackoff),
+			BackoffMultiplier: policy.BackoffMultiplier,
+		},
+	}
+	for _, code := range policy.RetryableStatusCodes {
+		config.RetryPolicy.RetryableStatusCodes = append(config.RetryPolicy.RetryableStatusCodes, codeConfig(code))
+	}
+
+	data, err := json.Marshal(serviceConfig{MethodConfig: []methodConfig{config}})
+	if err != nil {
+		// the config only has strings and numbers
+		panic(err)
+	}
+	return string(data)
+}
+
+// WithRetryPolicy returns a dial option that retries the idempotent calls with the policy.
+// gRPC ignores an invalid policy, such as MaxAttempts below 2, and does not retry the calls.
+func WithRetryPolicy(policy RetryPolicy) grpc.DialOption {
+	return grpc.WithDefaultServiceConfig(policy.ServiceConfig())
+}
+
+// durationConfig formats a duration the way service configs write it, such as 0.5s
+func durationConfig(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// codeConfig formats a status code the way service configs write it, such as DEADLINE_EXCEEDED
+func codeConfig(code codes.Code) string {
+	if code == codes.Canceled {
+		return "CANCELLED"
+	}
+
+	var name strings.Builder
+	previous := ' '
+	for _, r := range code.String() {
+		if unicode.IsUpper(r) && unicode.IsLower(previous) {
+			name.WriteByte('_')
+		}
+		name.WriteRune(unicode.ToUpper(r))
+		previous = r
+	}
+	return name.String()
+}
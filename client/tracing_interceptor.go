@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"grpc-project/tracing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TracingInterceptor is a client interceptor that records a client span for every RPC
+// and sends its span context to the server in the traceparent metadata
+type TracingInterceptor struct {
+	tracer *tracing.Tracer
+}
+
+// NewTracingInterceptor returns a new tracing interceptor that records spans with tracer
+func NewTracingInterceptor(tracer *tracing.Tracer) *TracingInterceptor {
+	return &TracingInterceptor{tracer: tracer}
+}
+
+// Unary returns a client interceptor to trace unary RPC
+func (interceptor *TracingInterceptor) Unary() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		ctx, span := interceptor.startSpan(ctx, method, cc)
+		defer span.End()
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		endClientSpan(span, err)
+		return err
+	}
+}
+
+// Stream returns a client interceptor to trace stream RPC.
+// The span ends when the stream returns an error or io.EOF from RecvMsg,
+// or after the response of a stream that is not server streaming.
+func (interceptor *TracingInterceptor) Stream() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		ctx, span := interceptor.startSpan(ctx, method, cc)
+
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			endClientSpan(span, err)
+			span.End()
+			return nil, err
+		}
+		return &tracedClientStream{ClientStream: stream, span: span, serverStreams: desc.ServerStreams}, nil
+	}
+}
+
+// startSpan starts the client span of an RPC and puts its traceparent in the outgoing metadata
+func (interceptor *TracingInterceptor) startSpan(ctx context.Context, method string, cc *grpc.ClientConn) (context.Context, *tracing.Span) {
+	name := strings.TrimPrefix(method, "/")
+	service, rpcMethod, _ := strings.Cut(name, "/")
+
+	ctx, span := interceptor.tracer.Start(ctx, name,
+		tracing.WithSpanKind(tracing.SpanKindClient),
+		tracing.WithAttributes(
+			tracing.String("rpc.system", "grpc"),
+			tracing.String("rpc.service", service),
+			tracing.String("rpc.method", rpcMethod),
+			tracing.String("server.address", cc.Target()),
+		),
+	)
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(tracing.TraceparentKey, span.SpanContext().Traceparent())
+	return metadata.NewOutgoingContext(ctx, md), span
+}
+
+func endClientSpan(span *tracing.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(tracing.Int("rpc.grpc.status_code", int(code)))
+	if code != codes.OK {
+		span.SetStatus(tracing.StatusError, status.Convert(err).Message())
+	}
+}
+
+// tracedClientStream ends the span of a stream RPC when the stream finishes
+type tracedClientStream struct {
+	grpc.ClientStream
+	span          *tracing.Span
+	serverStreams bool
+	once          sync.Once
+}
+
+func (stream *tracedClientStream) RecvMsg(m interface{}) error {
+	err := stream.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		stream.end(nil)
+	case err != nil:
+		stream.end(err)
+	case !stream.serverStreams:
+		stream.end(nil)
+	}
+	return err
+}
+
+func (stream *tracedClientStream) SendMsg(m interface{}) error {
+	err := stream.ClientStream.SendMsg(m)
+	if err != nil && err != io.EOF {
+		stream.end(err)
+	}
+	return err
+}
+
+func (stream *tracedClientStream) end(err error) {
+	stream.once.Do(func() {
+		endClientSpan(stream.span, err)
+		stream.span.End()
+	})
+}
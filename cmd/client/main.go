@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"time"
@@ -15,21 +17,64 @@ import (
 	"grpc-project/client"
 	"grpc-project/example.com/pcbook/pb"
 	"grpc-project/sample"
+	"grpc-project/tracing"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-// testCreateLaptop ทดสอบการสร้างแล็ปท็อปใหม่
-func testCreateLaptop(laptopClient *client.LaptopClient) {
-	laptopClient.CreateLaptop(sample.NewLaptop())
+// timeout คือ timeout ของการเรียก RPC แต่ละครั้ง
+var timeout = flag.Duration("timeout", 5*time.Second, "the timeout of each call to the server")
+
+// testCreateLaptop ทดสอบการสร้างแล็ปท็อปใหม่ แล็ปท็อปที่มีอยู่แล้วไม่ถือว่าผิดพลาด
+func testCreateLaptop(ctx context.Context, laptopClient *client.LaptopClient, laptop *pb.Laptop) {
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	id, err := laptopClient.CreateLaptop(ctx, laptop)
+	if status.Code(err) == codes.AlreadyExists {
+		log.Print("laptop already exists")
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("created laptop with id: %s", id)
+}
+
+// testCreateLaptops ทดสอบการสร้างแล็ปท็อปหลายเครื่องใน stream เดียว โดยมีแล็ปท็อปที่ซ้ำกันหนึ่งเครื่อง
+func testCreateLaptops(ctx context.Context, laptopClient *client.LaptopClient) {
+	laptops := make(chan *pb.Laptop)
+	go func() {
+		defer close(laptops)
+		duplicate := sample.NewLaptop()
+		laptops <- duplicate
+		for i := 0; i < 10; i++ {
+			laptops <- sample.NewLaptop()
+		}
+		laptops <- duplicate // เซิร์ฟเวอร์จะตอบ AlreadyExists เฉพาะเครื่องนี้
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	err := laptopClient.CreateLaptops(ctx, laptops, 4, func(result client.CreateLaptopsResult) {
+		if result.Status != nil {
+			log.Printf("laptop %d is not created: %v", result.Index, result.Err())
+			return
+		}
+		log.Printf("laptop %d created with id: %s", result.Index, result.ID)
+	})
+	if err != nil {
+		log.Fatal("cannot create laptops: ", err)
+	}
 }
 
 // testSearchLaptop ทดสอบการค้นหาแล็ปท็อปตามเงื่อนไข
-func testSearchLaptop(laptopClient *client.LaptopClient) {
+func testSearchLaptop(ctx context.Context, laptopClient *client.LaptopClient) {
 	for i := 0; i < 10; i++ {
-		laptopClient.CreateLaptop(sample.NewLaptop()) // สร้างแล็ปท็อปใหม่ 10 เครื่องเพื่อทดสอบการค้นหา
+		testCreateLaptop(ctx, laptopClient, sample.NewLaptop()) // สร้างแล็ปท็อปใหม่ 10 เครื่องเพื่อทดสอบการค้นหา
 	}
 
 	filter := &pb.Filter{
@@ -38,27 +83,63 @@ func testSearchLaptop(laptopClient *client.LaptopClient) {
 		MinCpuGhz:   2.5,
 		MinRam:      &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE},
 	}
-	laptopClient.SearchLaptop(filter) // ค้นหาแล็ปท็อปตามเงื่อนไขที่ระบุ
+	log.Print("search filter: ", filter)
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	// ค้นหาแล็ปท็อปตามเงื่อนไขที่ระบุ เรียงตามคะแนน Bayesian และพิมพ์แล็ปท็อปที่พบทีละเครื่อง
+	req := &pb.SearchLaptopRequest{Filter: filter, SortBy: pb.SearchLaptopRequest_BAYESIAN_SCORE}
+	err := laptopClient.SearchLaptop(ctx, req, func(res *pb.SearchLaptopResponse) error {
+		laptop := res.GetLaptop()
+		log.Print("- found: ", laptop.GetId())
+		log.Print("  + brand: ", laptop.GetBrand())
+		log.Print("  + name: ", laptop.GetName())
+		log.Print("  + cpu cores: ", laptop.GetCpu().GetNumberCores())
+		log.Print("  + cpu min ghz: ", laptop.GetCpu().GetMinGhz())
+		log.Print("  + ram: ", laptop.GetRam())
+		log.Print("  + price: ", laptop.GetPriceUsd())
+		log.Print("  + score: ", res.GetScore().GetBayesianScore())
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 // testUploadImage ทดสอบการอัปโหลดภาพของแล็ปท็อป
-func testUploadImage(laptopClient *client.LaptopClient) {
+func testUploadImage(ctx context.Context, laptopClient *client.LaptopClient) {
 	laptop := sample.NewLaptop()
-	laptopClient.CreateLaptop(laptop)
-	laptopClient.UploadImage(laptop.GetId(), "tmp/laptop.jpg")
+	testCreateLaptop(ctx, laptopClient, laptop)
+
+	imagePath := "tmp/laptop.jpg"
+	file, err := os.Open(imagePath)
+	if err != nil {
+		log.Fatal("cannot open image file: ", err)
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	res, err := laptopClient.UploadImage(ctx, laptop.GetId(), filepath.Ext(imagePath), file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("image uploaded with id: %s, size: %d", res.GetId(), res.GetSize())
 }
 
-func testRateLaptop(laptopClient *client.LaptopClient) {
+func testRateLaptop(ctx context.Context, laptopClient *client.LaptopClient) {
 	n := 3
 	laptopIDs := make([]string, n)
 
 	for i := 0; i < n; i++ {
 		laptop := sample.NewLaptop()
 		laptopIDs[i] = laptop.GetId()
-		laptopClient.CreateLaptop(laptop)
+		testCreateLaptop(ctx, laptopClient, laptop)
 	}
 
-	scores := make([]float64, n)
+	ratings := make([]*pb.RateLaptopRequest, n)
 	for {
 		fmt.Print("rate laptop (y/n)? ")
 		var answer string
@@ -69,13 +150,19 @@ func testRateLaptop(laptopClient *client.LaptopClient) {
 		}
 
 		for i := 0; i < n; i++ {
-			scores[i] = sample.RandomLaptopScore()
+			ratings[i] = &pb.RateLaptopRequest{LaptopId: laptopIDs[i], Score: sample.RandomLaptopScore()}
 		}
 
-		err := laptopClient.RateLaptop(laptopIDs, scores)
+		// เวลาที่ผู้ใช้ใช้ตอบคำถามไม่นับรวมใน timeout
+		callCtx, cancel := context.WithTimeout(ctx, *timeout)
+		responses, err := laptopClient.RateLaptop(callCtx, ratings)
+		cancel()
 		if err != nil {
 			log.Fatal(err)
 		}
+		for _, res := range responses {
+			log.Print("received response: ", res)
+		}
 	}
 }
 
@@ -222,7 +309,7 @@ func rateLaptop(laptopClient pb.LaptopServiceClient, laptopIDs []string, scores
 		}
 		err := stream.Send(req)
 		if err != nil {
-			fmt.Errorf("cannot sead stream request: %v - %v", err, stream.RecvMsg(nil))
+			return fmt.Errorf("cannot send stream request: %v - %v", err, stream.RecvMsg(nil))
 		}
 		log.Print("sent  request: ", req)
 	}
@@ -242,33 +329,91 @@ const (
 )
 
 func authMethods() map[string]bool {
-	const laptopServicePath = "/techschool.pcbook.LaptopService/"
+	// ต้องตรงกับ package ใน proto คือ techshcool.pcbook มิฉะนั้น access token จะไม่ถูกส่งไปกับการเรียก
+	const laptopServicePath = "/techshcool.pcbook.LaptopService/"
 
 	return map[string]bool{
-		laptopServicePath + "CreateLaptop": true,
-		laptopServicePath + "UploadImage":  true,
-		laptopServicePath + "RateLaptop":   true,
+		laptopServicePath + "CreateLaptop":  true,
+		laptopServicePath + "CreateLaptops": true,
+		laptopServicePath + "UploadImage":   true,
+		laptopServicePath + "DeleteLaptop":  true,
+		laptopServicePath + "RateLaptop":    true,
+	}
+}
+
+// newTracer สร้าง tracer ตาม exporter ที่เลือก และคืนค่า nil ถ้าไม่ได้เปิด tracing
+func newTracer(exporter string, filename string) (*tracing.Tracer, func(), error) {
+	switch exporter {
+	case "none":
+		return nil, func() {}, nil
+	case "stdout":
+		return tracing.NewTracer(tracing.NewStdoutExporter(os.Stdout)), func() {}, nil
+	case "otlp_file":
+		file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tracing.NewTracer(tracing.NewOTLPFileExporter(file, "pcbook-client")), func() { file.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q", exporter)
 	}
 }
 
 func main() {
 	fmt.Println("Hello World client")
 	serverAddress := flag.String("address", "", "the server address") // อ่านที่อยู่เซิร์ฟเวอร์จาก flag
+	traceExporter := flag.String("trace", "none", "where to export spans: none, stdout or otlp_file")
+	traceFile := flag.String("trace-file", "client-traces.jsonl", "the file the otlp_file exporter appends spans to")
+	lang := flag.String("lang", "en", "the language of the error messages of the server, such as en or th")
 	flag.Parse()
 	log.Printf("dial server %s", *serverAddress)
 
-	cc1, err := grpc.Dial(*serverAddress, grpc.WithInsecure()) // เชื่อมต่อไปยังเซิร์ฟเวอร์
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+	logging := client.NewLoggingInterceptor(slog.Default()) // ส่ง x-request-id ไปกับทุกคำขอเพื่อให้ค้นหา log ฝั่งเซิร์ฟเวอร์ได้
+	locale := client.NewLocaleInterceptor(*lang) // ขอข้อความของข้อผิดพลาดเป็นภาษาที่ผู้ใช้เลือก
+	unaryInterceptors := []grpc.UnaryClientInterceptor{logging.Unary(), locale.Unary()}
+	streamInterceptors := []grpc.StreamClientInterceptor{logging.Stream(), locale.Stream()}
+
+	// ส่ง traceparent ไปกับทุกคำขอ เพื่อให้ span ฝั่งเซิร์ฟเวอร์อยู่ใน trace เดียวกับฝั่ง client
+	tracer, closeTracer, err := newTracer(*traceExporter, *traceFile)
+	if err != nil {
+		log.Fatal("cannot create tracer: ", err)
+	}
+	defer closeTracer()
+	if tracer != nil {
+		tracingInterceptor := client.NewTracingInterceptor(tracer)
+		unaryInterceptors = append([]grpc.UnaryClientInterceptor{tracingInterceptor.Unary()}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamClientInterceptor{tracingInterceptor.Stream()}, streamInterceptors...)
+	}
+
+	// ยกเลิก RPC เมื่อผู้ใช้กด Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cc1, err := grpc.Dial(
+		*serverAddress,
+		grpc.WithInsecure(),
+		client.WithRetryPolicy(client.DefaultRetryPolicy), // ลองเรียกใหม่เมื่อเซิร์ฟเวอร์ยังไม่พร้อม
+		grpc.WithChainUnaryInterceptor(unaryInterceptors...),
+	) // เชื่อมต่อไปยังเซิร์ฟเวอร์
 	if err != nil {
 		log.Fatalf("cannot connect to server: %v", err)
 	}
 	authClient := client.NewAuthClient(cc1,username,password)
-	interceptor, err := client.NewAuthInterceptor(authClient, authMethods(), refreshDuration)
-	
+	loginCtx, cancel := context.WithTimeout(ctx, *timeout)
+	interceptor, err := client.NewAuthInterceptor(loginCtx, authClient, authMethods(), refreshDuration)
+	cancel()
+	if err != nil {
+		log.Fatal("cannot login: ", err)
+	}
+	defer interceptor.Close() // หยุด refresh token เมื่อโปรแกรมจบ
+
 	cc2, err := grpc.Dial(
 		*serverAddress,
 		grpc.WithInsecure(),
-		grpc.WithUnaryInterceptor(interceptor.Unary()),
-		grpc.WithStreamInterceptor(interceptor.Stream()),
+		client.WithRetryPolicy(client.DefaultRetryPolicy),
+		grpc.WithChainUnaryInterceptor(append(unaryInterceptors, interceptor.Unary())...),
+		grpc.WithChainStreamInterceptor(append(streamInterceptors, interceptor.Stream())...),
 	)
 	if err != nil {
 		log.Fatal("cannot dial server: ", err)
@@ -276,5 +421,5 @@ func main() {
 
 	laptopClient := client.NewLaptopClient(cc2)
 	// laptopClient := pb.NewLaptopServiceClient(conn) // สร้าง client สำหรับเรียกใช้บริการ LaptopService
-	testRateLaptop(laptopClient)                   // เรียกใช้ฟังก์ชันทดสอบการอัปโหลดภาพ
+	testRateLaptop(ctx, laptopClient)              // เรียกใช้ฟังก์ชันทดสอบการให้คะแนนแล็ปท็อป
 }
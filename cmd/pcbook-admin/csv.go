@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/serializer"
+)
+
+// csvOptions ทำให้คอลัมน์ของแล็ปท็อปเหมือนกับไฟล์ CSV ของแล็ปท็อปจาก serializer.ProtobufToCsv
+// เช่น brand, cpu.name และ storages[0].memory.value ส่วนคะแนนเป็นคอลัมน์เพิ่ม เช่น ratings[0].score
+// ไฟล์ CSV ของแล็ปท็อปจึง import ได้โดยตรงเป็นรายการที่ไม่มีคะแนน
+var csvOptions = serializer.CsvOptions{Inline: "laptop"}
+
+// csvWriter เก็บรายการไว้จนถึง Close เพราะจำนวนคอลัมน์ของ storages, gpus และ ratings
+// ขึ้นกับรายการที่ยาวที่สุดในไฟล์
+type csvWriter struct {
+	writer  io.Writer
+	entries []*pb.CatalogEntry
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{writer: w}
+}
+
+func (w *csvWriter) Write(entry *pb.CatalogEntry) error {
+	w.entries = append(w.entries, entry)
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	// ไฟล์ที่ไม่มีรายการยังคงมี header เพื่อให้ import กลับได้
+	return serializer.ProtobufToCsv(w.writer, w.entries, csvOptions)
+}
+
+// csvReader อ่านทั้งไฟล์ในการเรียก Read ครั้งแรก ข้อผิดพลาดเป็น *serializer.CsvError ที่ระบุแถวและคอลัมน์
+type csvReader struct {
+	reader  io.Reader
+	entries []*pb.CatalogEntry
+	read    bool
+}
+
+func newCSVReader(r io.Reader) *csvReader {
+	return &csvReader{reader: r}
+}
+
+func (r *csvReader) Read() (*pb.CatalogEntry, error) {
+	if !r.read {
+		r.read = true
+		entries, err := serializer.CsvToProtobuf[*pb.CatalogEntry](r.reader, csvOptions)
+		if err != nil {
+			return nil, err
+		}
+		r.entries = entries
+	}
+
+	if len(r.entries) == 0 {
+		return nil, io.EOF
+	}
+	entry := r.entries[0]
+	r.entries = r.entries[1:]
+	return entry, nil
+}
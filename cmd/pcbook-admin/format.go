@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/serializer"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// รูปแบบไฟล์ที่รองรับในการ export และ import แค็ตตาล็อก
+const (
+	formatNDJSON   = "ndjson"   // JSON หนึ่งบรรทัดต่อหนึ่งรายการ
+	formatCSV      = "csv"      // หนึ่งแถวต่อหนึ่งรายการ ด้วยคอลัมน์ของแล็ปท็อปจาก serializer และคอลัมน์ของคะแนน
+	formatProtobuf = "protobuf" // protobuf แบบ binary ที่มีความยาวแบบ varint นำหน้าแต่ละรายการ
+)
+
+// maxLineSize คือความยาวสูงสุดของหนึ่งบรรทัดในไฟล์ NDJSON
+const maxLineSize = 16 << 20
+
+// entryWriter เขียนรายการในแค็ตตาล็อกทีละรายการ ต้องเรียก Close เพื่อเขียนข้อมูลที่ค้างอยู่ใน buffer
+type entryWriter interface {
+	Write(entry *pb.CatalogEntry) error
+	Close() error
+}
+
+// entryReader อ่านรายการในแค็ตตาล็อกทีละรายการ และคืนค่า io.EOF เมื่อไม่มีรายการเหลือ
+// ข้อผิดพลาดระบุตำแหน่งของรายการในไฟล์ เช่น บรรทัดหรือแถว
+type entryReader interface {
+	Read() (*pb.CatalogEntry, error)
+}
+
+// formatFromFilename เลือกรูปแบบไฟล์จากนามสกุลของไฟล์
+func formatFromFilename(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ndjson", ".jsonl":
+		return formatNDJSON, nil
+	case ".csv":
+		return formatCSV, nil
+	case ".pb", ".binpb":
+		return formatProtobuf, nil
+	default:
+		return "", fmt.Errorf("cannot tell the format of %q, use -format", filename)
+	}
+}
+
+// newEntryWriter สร้าง entryWriter ของรูปแบบที่กำหนด
+func newEntryWriter(format string, w io.Writer) (entryWriter, error) {
+	switch format {
+	case formatNDJSON:
+		return &ndjsonWriter{writer: bufio.NewWriter(w)}, nil
+	case formatCSV:
+		return newCSVWriter(w), nil
+	case formatProtobuf:
+		writer, err := serializer.NewDelimitedWriter(w, serializer.DelimitedOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &protobufWriter{writer: writer}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// newEntryReader สร้าง entryReader ของรูปแบบที่กำหนด
+func newEntryReader(format string, r io.Reader) (entryReader, error) {
+	switch format {
+	case formatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+		return &ndjsonReader{scanner: scanner}, nil
+	case formatCSV:
+		return newCSVReader(r), nil
+	case formatProtobuf:
+		reader, err := serializer.NewDelimitedReader(r, serializer.DelimitedOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &protobufReader{reader: reader}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+type ndjsonWriter struct {
+	writer *bufio.Writer
+}
+
+func (w *ndjsonWriter) Write(entry *pb.CatalogEntry) error {
+	data, err := marshalJSON(entry)
+	if err != nil {
+		return fmt.Errorf("cannot marshal entry to JSON: %w", err)
+	}
+	w.writer.Write(data)
+	return w.writer.WriteByte('\n')
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.writer.Flush()
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonReader) Read() (*pb.CatalogEntry, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue // ข้ามบรรทัดว่าง
+		}
+
+		entry := &pb.CatalogEntry{}
+		err := protojson.Unmarshal([]byte(line), entry)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return entry, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return nil, io.EOF
+}
+
+// marshalJSON แปลง message เป็น JSON บรรทัดเดียว
+// protojson เว้นวรรคแบบสุ่มโดยตั้งใจ จึงต้องตัดช่องว่างออกเพื่อให้ไฟล์ที่ export ซ้ำได้ผลลัพธ์เหมือนเดิม
+func marshalJSON(message proto.Message) ([]byte, error) {
+	data, err := protojson.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	var compact bytes.Buffer
+	err = json.Compact(&compact, data)
+	if err != nil {
+		return nil, err
+	}
+	return compact.Bytes(), nil
+}
+
+type protobufWriter struct {
+	writer *serializer.DelimitedWriter
+}
+
+func (w *protobufWriter) Write(entry *pb.CatalogEntry) error {
+	err := w.writer.Write(entry)
+	if err != nil {
+		return fmt.Errorf("cannot marshal entry to protobuf: %w", err)
+	}
+	return nil
+}
+
+func (w *protobufWriter) Close() error {
+	return w.writer.Close()
+}
+
+type protobufReader struct {
+	reader *serializer.DelimitedReader
+}
+
+func (r *protobufReader) Read() (*pb.CatalogEntry, error) {
+	entry := &pb.CatalogEntry{}
+	err := r.reader.Read(entry)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("message %d: file is truncated", r.reader.Count()+1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/serializer"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestFormatRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	withRatings := &pb.CatalogEntry{
+		Laptop: sample.NewLaptop(),
+		Ratings: []*pb.Review{
+			{Username: "user1", Score: 8.5, Review: "fast, quiet", UpdatedAt: timestamppb.New(time.Unix(1700000000, 42))},
+			{Username: "user2", Score: 3},
+		},
+	}
+	minimal := &pb.CatalogEntry{Laptop: &pb.Laptop{Brand: "Apple", Name: "Macbook \"Air\"", Weight: &pb.Laptop_WeightLb{WeightLb: 2.5}}}
+	entries := []*pb.CatalogEntry{withRatings, {Laptop: sample.NewLaptop()}, minimal}
+
+	for _, format := range []string{formatNDJSON, formatCSV, formatProtobuf} {
+		var buffer bytes.Buffer
+		writer, err := newEntryWriter(format, &buffer)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			require.NoError(t, writer.Write(entry), format)
+		}
+		require.NoError(t, writer.Close())
+
+		reader, err := newEntryReader(format, &buffer)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			read, err := reader.Read()
+			require.NoError(t, err, format)
+			require.True(t, proto.Equal(entry, read), "%s: %v != %v", format, entry, read)
+		}
+		_, err = reader.Read()
+		require.Equal(t, io.EOF, err, format)
+	}
+}
+
+func TestCSVColumns(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	laptop.Storages = []*pb.Storage{
+		{Driver: pb.Storage_SSD, Memory: &pb.Memory{Value: 256, Unit: pb.Memory_GIGABYTE}},
+		{Driver: pb.Storage_HDD, Memory: &pb.Memory{Value: 1, Unit: pb.Memory_TERABYTE}},
+	}
+	var buffer bytes.Buffer
+	writer := newCSVWriter(&buffer)
+	require.NoError(t, writer.Write(&pb.CatalogEntry{Laptop: laptop, Ratings: []*pb.Review{{Username: "user1", Score: 8}}}))
+	require.NoError(t, writer.Close())
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	row := map[string]string{}
+	for i, column := range records[0] {
+		row[column] = records[1][i]
+	}
+	require.Equal(t, laptop.GetCpu().GetName(), row["cpu.name"])
+	require.Equal(t, laptop.GetRam().GetUnit().String(), row["ram.unit"])
+	require.Equal(t, "SSD", row["storages[0].driver"])
+	require.Equal(t, "256", row["storages[0].memory.value"])
+	require.Equal(t, "HDD", row["storages[1].driver"])
+	require.Equal(t, "TERABYTE", row["storages[1].memory.unit"])
+	require.Equal(t, "user1", row["ratings[0].username"])
+	require.Equal(t, "8", row["ratings[0].score"])
+
+	// ไฟล์ CSV ของแล็ปท็อปจาก serializer อ่านได้เป็นรายการที่ไม่มีคะแนน
+	buffer.Reset()
+	require.NoError(t, serializer.ProtobufToCsv(&buffer, []*pb.Laptop{laptop}, serializer.CsvOptions{}))
+	entry, err := newCSVReader(&buffer).Read()
+	require.NoError(t, err)
+	require.True(t, proto.Equal(&pb.CatalogEntry{Laptop: laptop}, entry))
+}
+
+func TestReadErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		format string
+		input  string
+		err    string
+	}{
+		{formatCSV, "", "row 1: header is missing"},
+		{formatCSV, "id,color\n", `row 1, column 2 (color): unknown column "color"`},
+		{formatCSV, "id,brand\n,Apple\n,Dell,extra\n", "row 3: "},
+		{formatCSV, "brand,ram.value,ram.unit\nApple,8,GIGABYTE\nDell,8,PETABYTE\n", `row 3, column 3 (ram.unit): unknown value "PETABYTE"`},
+		{formatCSV, "brand,storages[0].memory.value\nApple,256 GB\n", `row 2, column 2 (storages[0].memory.value): "256 GB" is not`},
+		{formatCSV, "brand,ratings[0].score\nApple,high\n", `row 2, column 2 (ratings[0].score): "high" is not a number`},
+		{formatCSV, "weight_kg,weight_lb\n1,2\n", "row 2, column 2 (weight_lb): only one of weight_kg or weight_lb can be set"},
+		{formatNDJSON, "{\"laptop\":{}}\n\n{\"laptop\":\n", "line 3: "},
+		{formatProtobuf, "\x05abc", "message 1: file is truncated"},
+	}
+	for _, tc := range testCases {
+		reader, err := newEntryReader(tc.format, strings.NewReader(tc.input))
+		require.NoError(t, err)
+
+		for err == nil {
+			_, err = reader.Read()
+		}
+		require.ErrorContains(t, err, tc.err, "%s: %q", tc.format, tc.input)
+	}
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	t.Parallel()
+
+	for filename, format := range map[string]string{
+		"catalog.ndjson": formatNDJSON,
+		"catalog.JSONL":  formatNDJSON,
+		"tmp/export.csv": formatCSV,
+		"catalog.binpb":  formatProtobuf,
+	} {
+		actual, err := formatFromFilename(filename)
+		require.NoError(t, err)
+		require.Equal(t, format, actual, filename)
+	}
+
+	_, err := formatFromFilename("catalog.xml")
+	require.Error(t, err)
+}
@@ -0,0 +1,303 @@
+// pcbook-admin export และ import แค็ตตาล็อกของแล็ปท็อปทั้งหมด รวมถึงคะแนนของผู้ใช้ ผ่าน gRPC
+//
+//	pcbook-admin -address 0.0.0.0:8080 export -output catalog.ndjson
+//	pcbook-admin -address 0.0.0.0:8080 import -input catalog.csv -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"grpc-project/client"
+	"grpc-project/example.com/pcbook/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const refreshDuration = 30 * time.Second
+
+// connection เก็บข้อมูลสำหรับเชื่อมต่อและยืนยันตัวตนกับเซิร์ฟเวอร์
+type connection struct {
+	address  string
+	username string
+	password string
+	lang     string
+}
+
+// dial เชื่อมต่อกับเซิร์ฟเวอร์และ login ด้วยบัญชีของ admin
+// ผู้เรียกต้องเรียก disconnect เมื่อใช้งานเสร็จ เพื่อหยุดการ refresh token และปิดการเชื่อมต่อ
+func (conn *connection) dial(ctx context.Context) (*client.LaptopClient, func(), error) {
+	cc1, err := grpc.NewClient(
+		conn.address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		client.WithRetryPolicy(client.DefaultRetryPolicy),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot connect to server: %w", err)
+	}
+
+	authClient := client.NewAuthClient(cc1, conn.username, conn.password)
+	authMethods := map[string]bool{
+		"/techshcool.pcbook.LaptopService/ExportCatalog": true,
+		"/techshcool.pcbook.LaptopService/ImportCatalog": true,
+	}
+	loginCtx, cancel := context.WithTimeout(ctx, refreshDuration)
+	defer cancel()
+	auth, err := client.NewAuthInterceptor(loginCtx, authClient, authMethods, refreshDuration)
+	if err != nil {
+		cc1.Close()
+		return nil, nil, fmt.Errorf("cannot login: %w", err)
+	}
+
+	locale := client.NewLocaleInterceptor(conn.lang) // ขอข้อความของข้อผิดพลาดเป็นภาษาที่ผู้ใช้เลือก
+	cc2, err := grpc.NewClient(
+		conn.address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		client.WithRetryPolicy(client.DefaultRetryPolicy), // export ถูกเรียกใหม่ได้ถ้ายังไม่ได้รับรายการแรก
+		grpc.WithChainStreamInterceptor(locale.Stream(), auth.Stream()),
+	)
+	if err != nil {
+		auth.Close()
+		cc1.Close()
+		return nil, nil, fmt.Errorf("cannot connect to server: %w", err)
+	}
+
+	disconnect := func() {
+		auth.Close()
+		cc1.Close()
+		cc2.Close()
+	}
+	return client.NewLaptopClient(cc2), disconnect, nil
+}
+
+// runExport เขียนแค็ตตาล็อกทั้งหมดของเซิร์ฟเวอร์ลงไฟล์
+func runExport(ctx context.Context, conn *connection, args []string, interval time.Duration) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("output", "-", "the file to write, - writes to stdout")
+	format := flags.String("format", "", "ndjson, csv or protobuf, by default the extension of the output file")
+	flags.Parse(args)
+
+	file := os.Stdout
+	name := "stdout"
+	if *output != "-" {
+		name = *output
+	}
+	err := resolveFormat(format, *output)
+	if err != nil {
+		return err
+	}
+
+	laptopClient, disconnect, err := conn.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+
+	if *output != "-" {
+		file, err = os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("cannot create output file: %w", err)
+		}
+		defer file.Close()
+	}
+
+	writer, err := newEntryWriter(*format, file)
+	if err != nil {
+		return err
+	}
+
+	progress := newProgress(os.Stderr, "exported", interval)
+	err = laptopClient.ExportCatalog(ctx, func(entry *pb.CatalogEntry) error {
+		err := writer.Write(entry)
+		if err != nil {
+			return fmt.Errorf("cannot write %s: %w", name, err)
+		}
+		progress.add(len(entry.GetRatings()))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("cannot write %s: %w", name, err)
+	}
+	if file != os.Stdout {
+		err = file.Close()
+		if err != nil {
+			return fmt.Errorf("cannot write %s: %w", name, err)
+		}
+	}
+
+	progress.done()
+	return nil
+}
+
+// runImport อ่านแค็ตตาล็อกจากไฟล์และส่งไปยังเซิร์ฟเวอร์ รายการที่ไม่สำเร็จถูกรายงานโดยไม่หยุดรายการอื่น
+func runImport(ctx context.Context, conn *connection, args []string, interval time.Duration) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	input := flags.String("input", "-", "the file to read, - reads from stdin")
+	format := flags.String("format", "", "ndjson, csv or protobuf, by default the extension of the input file")
+	dryRun := flags.Bool("dry-run", false, "validate the entries on the server without saving them")
+	window := flags.Int("window", 32, "the number of entries sent to the server without a response yet")
+	flags.Parse(args)
+
+	file := os.Stdin
+	name := "stdin"
+	if *input != "-" {
+		name = *input
+	}
+	err := resolveFormat(format, *input)
+	if err != nil {
+		return err
+	}
+
+	if *input != "-" {
+		file, err = os.Open(*input)
+		if err != nil {
+			return fmt.Errorf("cannot open input file: %w", err)
+		}
+		defer file.Close()
+	}
+
+	reader, err := newEntryReader(*format, file)
+	if err != nil {
+		return err
+	}
+
+	laptopClient, disconnect, err := conn.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// อ่านไฟล์ไปพร้อมกับการส่ง ไฟล์ถูกอ่านเร็วเท่าที่เซิร์ฟเวอร์ตอบกลับทัน
+	entries := make(chan *pb.CatalogEntry)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(entries)
+		readErr <- readEntries(ctx, reader, entries)
+	}()
+
+	action := "imported"
+	if *dryRun {
+		action = "validated"
+	}
+	progress := newProgress(os.Stderr, action, interval)
+	err = laptopClient.ImportCatalog(ctx, entries, *dryRun, *window, func(result client.ImportCatalogResult) {
+		if result.Status != nil {
+			progress.fail()
+			printFailure(os.Stderr, result)
+			return
+		}
+		progress.add(result.ImportedRatings)
+	})
+	if err != nil {
+		return err
+	}
+
+	// รายการก่อนหน้าข้อผิดพลาดถูกนำเข้าแล้ว จึงรายงานความคืบหน้าก่อนข้อผิดพลาด
+	progress.done()
+	err = <-readErr
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", name, err)
+	}
+	if progress.failed > 0 {
+		return fmt.Errorf("%d entries were not %s", progress.failed, action)
+	}
+	return nil
+}
+
+// resolveFormat เลือกรูปแบบไฟล์จากนามสกุลของไฟล์ เมื่อไม่ได้ระบุ -format
+func resolveFormat(format *string, filename string) error {
+	if *format != "" {
+		return nil
+	}
+	if filename == "-" {
+		return fmt.Errorf("-format is required to use stdin or stdout")
+	}
+
+	var err error
+	*format, err = formatFromFilename(filename)
+	return err
+}
+
+// readEntries ส่งรายการทั้งหมดจาก reader ไปยัง channel จนกว่าจะหมดไฟล์หรือ context ถูกยกเลิก
+func readEntries(ctx context.Context, reader entryReader, entries chan<- *pb.CatalogEntry) error {
+	for {
+		entry, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case entries <- entry:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// printFailure พิมพ์สาเหตุที่รายการไม่สำเร็จ รวมถึงทุกช่องที่ไม่ถูกต้อง
+func printFailure(output io.Writer, result client.ImportCatalogResult) {
+	fmt.Fprintf(output, "entry %d (laptop %q): %s\n", result.Index+1, result.Entry.GetLaptop().GetId(), result.Status.GetMessage())
+	for _, violation := range result.Status.GetFieldViolations() {
+		fmt.Fprintf(output, "  - %s: %s\n", violation.GetField(), violation.GetDescription())
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] export|import [command flags]\n\nflags:\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprint(flag.CommandLine.Output(), "\nrun \"export -h\" or \"import -h\" for the flags of a command\n")
+}
+
+func main() {
+	conn := &connection{}
+	flag.StringVar(&conn.address, "address", "0.0.0.0:8080", "the server address")
+	flag.StringVar(&conn.username, "username", "admin1", "the username of an admin")
+	flag.StringVar(&conn.password, "password", "secret", "the password of the admin")
+	flag.StringVar(&conn.lang, "lang", "en", "the language of the error messages of the server, such as en or th")
+	interval := flag.Duration("progress", time.Second, "how often to report the progress, 0 only reports the total")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// ยกเลิก RPC เมื่อผู้ใช้กด Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch flag.Arg(0) {
+	case "export":
+		err = runExport(ctx, conn, flag.Args()[1:], *interval)
+	case "import":
+		err = runImport(ctx, conn, flag.Args()[1:], *interval)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
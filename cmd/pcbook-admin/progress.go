@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// progress นับจำนวนรายการที่ประมวลผลแล้ว และรายงานความคืบหน้าไม่บ่อยกว่าทุก interval
+type progress struct {
+	mutex      sync.Mutex
+	output     io.Writer
+	action     string // เช่น exported หรือ imported
+	interval   time.Duration
+	start      time.Time
+	lastReport time.Time
+	laptops    int
+	ratings    int
+	failed     int
+}
+
+func newProgress(output io.Writer, action string, interval time.Duration) *progress {
+	now := time.Now()
+	return &progress{
+		output:     output,
+		action:     action,
+		interval:   interval,
+		start:      now,
+		lastReport: now,
+	}
+}
+
+// add นับรายการที่สำเร็จหนึ่งรายการพร้อมจำนวนคะแนนของรายการนั้น
+func (p *progress) add(ratings int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.laptops++
+	p.ratings += ratings
+	p.maybeReport()
+}
+
+// fail นับรายการที่ไม่สำเร็จหนึ่งรายการ
+func (p *progress) fail() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.failed++
+	p.maybeReport()
+}
+
+// done รายงานผลรวมสุดท้าย
+func (p *progress) done() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.report("done: ")
+}
+
+func (p *progress) maybeReport() {
+	if p.interval <= 0 || time.Since(p.lastReport) < p.interval {
+		return
+	}
+	p.report("")
+}
+
+func (p *progress) report(prefix string) {
+	p.lastReport = time.Now()
+	elapsed := p.lastReport.Sub(p.start)
+	rate := float64(p.laptops+p.failed) / max(elapsed.Seconds(), 0.001)
+	fmt.Fprintf(
+		p.output, "%s%s %d laptops and %d ratings, %d failed, %.1f laptops/s, %s elapsed\n",
+		prefix, p.action, p.laptops, p.ratings, p.failed, rate, elapsed.Round(time.Millisecond),
+	)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc-project/client"
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+)
+
+// ชื่อของการเรียกแต่ละชนิด ใช้ทั้งใน -mix และในผลลัพธ์
+const (
+	operationCreate = "create_laptop"
+	operationSearch = "search_laptop"
+	operationUpload = "upload_image"
+	operationRate   = "rate_laptop"
+)
+
+// operations คือการเรียกทุกชนิดตามลำดับที่ใช้ในการสุ่ม
+var operations = []string{operationCreate, operationSearch, operationUpload, operationRate}
+
+// benchmark สร้างโหลดให้เซิร์ฟเวอร์ตามสัดส่วนของการเรียกแต่ละชนิด
+type benchmark struct {
+	laptopClient *client.LaptopClient
+	concurrency  int
+	rate         float64        // จำนวนการเรียกต่อวินาทีของทุก worker รวมกัน 0 คือไม่จำกัด
+	timeout      time.Duration  // timeout ของการเรียกแต่ละครั้ง
+	mix          map[string]int // น้ำหนักของการเรียกแต่ละชนิด
+	seed         int64
+	image        []byte
+	imageType    string
+
+	recorder *recorder
+	mutex    sync.RWMutex
+	ids      []string // ID ของแล็ปท็อปที่สร้างแล้ว ใช้กับการอัปโหลดภาพและการให้คะแนน
+}
+
+// parseMix อ่านน้ำหนักของการเรียกแต่ละชนิด เช่น create_laptop=3,search_laptop=1
+func parseMix(value string) (map[string]int, error) {
+	mix := map[string]int{}
+	total := 0
+	for _, part := range strings.Split(value, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("mix %q must be like %s=3,%s=1", part, operationCreate, operationSearch)
+		}
+
+		known := false
+		for _, operation := range operations {
+			known = known || operation == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown operation %q, use %s", name, strings.Join(operations, ", "))
+		}
+
+		n, err := strconv.Atoi(weight)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("weight of %s must be a number from 0, got %q", name, weight)
+		}
+		mix[name] = n
+		total += n
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("mix %q has no operation with a weight", value)
+	}
+	return mix, nil
+}
+
+// preload สร้างแล็ปท็อปก่อนเริ่มจับเวลา เพื่อให้การอัปโหลดภาพและการให้คะแนนมีแล็ปท็อปให้ใช้
+func (b *benchmark) preload(ctx context.Context, n int) error {
+	generator := sample.NewGenerator(b.seed - 1)
+	for _, laptop := range generator.NewLaptops(n) {
+		err := b.createLaptop(ctx, laptop)
+		if err != nil {
+			return fmt.Errorf("cannot preload laptops: %w", err)
+		}
+	}
+	return nil
+}
+
+// run เรียกเซิร์ฟเวอร์จนครบ duration หรือ ctx ถูกยกเลิก การเรียกที่เริ่มไปแล้วจะทำต่อจนเสร็จ
+func (b *benchmark) run(ctx context.Context, duration time.Duration) *report {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var tokens <-chan struct{}
+	skipped := 0
+	pacer := sync.WaitGroup{}
+	if b.rate > 0 {
+		channel := make(chan struct{}, b.concurrency)
+		tokens = channel
+		pacer.Add(1)
+		go func() {
+			defer pacer.Done()
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / b.rate))
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					select {
+					case channel <- struct{}{}:
+					default:
+						skipped++ // ทุก worker ยังรอผลอยู่ เซิร์ฟเวอร์รับอัตรานี้ไม่ไหว
+					}
+				}
+			}
+		}()
+	}
+
+	start := time.Now()
+	workers := sync.WaitGroup{}
+	for i := 0; i < b.concurrency; i++ {
+		workers.Add(1)
+		go func(worker int) {
+			defer workers.Done()
+			b.work(ctx, tokens, b.seed+int64(worker))
+		}(i)
+	}
+	workers.Wait()
+	elapsed := time.Since(start)
+	pacer.Wait()
+
+	result := b.recorder.report(elapsed)
+	result.Concurrency = b.concurrency
+	result.TargetRate = b.rate
+	result.SkippedTicks = skipped
+	return result
+}
+
+// work เรียกเซิร์ฟเวอร์ทีละครั้งจนกว่า ctx จะหมดเวลา แต่ละ worker มี generator ของตัวเอง
+func (b *benchmark) work(ctx context.Context, tokens <-chan struct{}, seed int64) {
+	generator := sample.NewGenerator(seed)
+	random := rand.New(rand.NewSource(seed))
+
+	for {
+		if tokens != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tokens:
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		operation := b.pick(random)
+		callCtx, cancel := context.WithTimeout(context.Background(), b.timeout)
+		start := time.Now()
+		err := b.call(callCtx, operation, generator, random)
+		b.recorder.record(operation, time.Since(start), err)
+		cancel()
+	}
+}
+
+// pick สุ่มชนิดของการเรียกตามน้ำหนักใน mix
+func (b *benchmark) pick(random *rand.Rand) string {
+	total := 0
+	for _, operation := range operations {
+		total += b.mix[operation]
+	}
+	n := random.Intn(total)
+	for _, operation := range operations {
+		if n < b.mix[operation] {
+			return operation
+		}
+		n -= b.mix[operation]
+	}
+	return operations[0]
+}
+
+func (b *benchmark) call(ctx context.Context, operation string, generator *sample.Generator, random *rand.Rand) error {
+	switch operation {
+	case operationCreate:
+		return b.createLaptop(ctx, generator.NewLaptop())
+	case operationSearch:
+		return b.searchLaptop(ctx, random)
+	case operationUpload:
+		return b.uploadImage(ctx, b.randomID(random))
+	default:
+		return b.rateLaptop(ctx, b.randomID(random), generator.RandomLaptopScore())
+	}
+}
+
+func (b *benchmark) randomID(random *rand.Rand) string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if len(b.ids) == 0 {
+		return ""
+	}
+	return b.ids[random.Intn(len(b.ids))]
+}
+
+func (b *benchmark) createLaptop(ctx context.Context, laptop *pb.Laptop) error {
+	id, err := b.laptopClient.CreateLaptop(ctx, laptop)
+	if err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.ids = append(b.ids, id)
+	return nil
+}
+
+// searchLaptop ค้นหาด้วยเงื่อนไขแบบสุ่มและรับผลลัพธ์จนครบ
+func (b *benchmark) searchLaptop(ctx context.Context, random *rand.Rand) error {
+	filter := &pb.Filter{
+		MaxPriceUsd: float64(1000 + 500*random.Intn(7)),
+		MinCpuCores: uint32(2 * random.Intn(5)),
+		MinCpuGhz:   float64(random.Intn(4)) * 0.5,
+		MinRam:      &pb.Memory{Value: uint64(4 << random.Intn(4)), Unit: pb.Memory_GIGABYTE},
+	}
+	return b.laptopClient.SearchLaptop(ctx, &pb.SearchLaptopRequest{Filter: filter}, func(res *pb.SearchLaptopResponse) error {
+		return nil
+	})
+}
+
+func (b *benchmark) uploadImage(ctx context.Context, laptopID string) error {
+	_, err := b.laptopClient.UploadImage(ctx, laptopID, b.imageType, bytes.NewReader(b.image))
+	return err
+}
+
+func (b *benchmark) rateLaptop(ctx context.Context, laptopID string, score float64) error {
+	_, err := b.laptopClient.RateLaptop(ctx, []*pb.RateLaptopRequest{{LaptopId: laptopID, Score: score}})
+	return err
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPercentile(t *testing.T) {
+	t.Parallel()
+
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+	require.Equal(t, 1*time.Millisecond, percentile(latencies, 0))
+	require.Equal(t, 50*time.Millisecond, percentile(latencies, 50))
+	require.Equal(t, 99*time.Millisecond, percentile(latencies, 99))
+	require.Equal(t, 100*time.Millisecond, percentile(latencies, 100))
+	require.Equal(t, 7*time.Millisecond, percentile(latencies[6:7], 99))
+}
+
+func TestRecorderReport(t *testing.T) {
+	t.Parallel()
+
+	recorder := newRecorder()
+	recorder.record(operationCreate, 10*time.Millisecond, nil)
+	recorder.record(operationCreate, 30*time.Millisecond, status.Error(codes.AlreadyExists, "exists"))
+	recorder.record(operationRate, 20*time.Millisecond, nil)
+	recorder.record(operationRate, 40*time.Millisecond, errors.New("not a status"))
+
+	result := recorder.report(2 * time.Second)
+	require.Equal(t, 4, result.Total.Requests)
+	require.Equal(t, 2, result.Total.Errors)
+	require.Equal(t, 2.0, result.Total.Throughput)
+	require.Equal(t, 25.0, result.Total.LatencyMs.Mean)
+	require.Equal(t, map[string]int{"OK": 2, "AlreadyExists": 1, "Unknown": 1}, result.Total.Codes)
+
+	create := result.Operations[operationCreate]
+	require.Equal(t, 1, create.Errors)
+	require.Equal(t, 10.0, create.LatencyMs.Min)
+	require.Equal(t, 30.0, create.LatencyMs.Max)
+}
+
+func TestParseMix(t *testing.T) {
+	t.Parallel()
+
+	mix, err := parseMix("create_laptop=3, rate_laptop=1,upload_image=0")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{operationCreate: 3, operationRate: 1, operationUpload: 0}, mix)
+
+	for _, value := range []string{"create_laptop", "delete_laptop=1", "create_laptop=-1", "create_laptop=0"} {
+		_, err := parseMix(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestBenchmarkRun(t *testing.T) {
+	t.Parallel()
+
+	userStore := service.NewInMemoryUserStore()
+	user, err := service.NewUser("admin1", "secret", "admin")
+	require.NoError(t, err)
+	require.NoError(t, userStore.Save(context.Background(), user))
+	jwtManager := service.NewJWTManager("secret", time.Minute)
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	imageStore := service.NewDiskImageStore(t.TempDir())
+	ratingStore := service.NewInMemoryRatingStore()
+	laptopServer := service.NewLaptopServer(laptopStore, imageStore, ratingStore, nil)
+
+	const laptopServicePath = "/techshcool.pcbook.LaptopService/"
+	auth := service.NewAuthInterceptor(jwtManager, map[string][]string{
+		laptopServicePath + "CreateLaptop": {"admin"},
+		laptopServicePath + "UploadImage":  {"admin"},
+		laptopServicePath + "RateLaptop":   {"admin", "user"},
+	})
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(auth.Unary()), grpc.StreamInterceptor(auth.Stream()))
+	pb.RegisterAuthServiceServer(grpcServer, service.NewAuthServer(userStore, jwtManager))
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	laptopClient, disconnect, err := dial(context.Background(), listener.Addr().String(), "admin1", "secret")
+	require.NoError(t, err)
+	defer disconnect()
+
+	// การค้นหาช้าเกินไปสำหรับการทดสอบ เพราะ store หน่วงเวลาทุกแล็ปท็อปที่ตรวจ
+	b := &benchmark{
+		laptopClient: laptopClient,
+		concurrency:  4,
+		rate:         200,
+		timeout:      5 * time.Second,
+		mix:          map[string]int{operationCreate: 1, operationUpload: 1, operationRate: 1},
+		seed:         1,
+		image:        []byte("image"),
+		imageType:    ".jpg",
+		recorder:     newRecorder(),
+	}
+	require.NoError(t, b.preload(context.Background(), 3))
+
+	result := b.run(context.Background(), 300*time.Millisecond)
+	require.Greater(t, result.Total.Requests, 0)
+	require.LessOrEqual(t, result.Total.Requests, 70) // 200 ต่อวินาทีใน 0.3 วินาที รวมกับที่รออยู่ใน channel
+	require.Equal(t, 0, result.Total.Errors, "%v", result.Total.Codes)
+	for _, operation := range []string{operationCreate, operationUpload, operationRate} {
+		require.Greater(t, result.Operations[operation].Requests, 0, operation)
+	}
+	require.NotContains(t, result.Operations, operationSearch)
+}
@@ -0,0 +1,167 @@
+// pcbook-bench สร้างโหลดให้เซิร์ฟเวอร์ด้วยแล็ปท็อปจาก sample แล้วรายงาน latency, throughput และข้อผิดพลาดแยกตามรหัสสถานะ
+//
+//	pcbook-bench -address 0.0.0.0:8080 -duration 30s -concurrency 16 -rate 200 -output bench.json
+//	pcbook-bench -mix create_laptop=1,rate_laptop=9 -duration 1m
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"grpc-project/client"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const refreshDuration = 30 * time.Second
+
+// dial เชื่อมต่อกับเซิร์ฟเวอร์และ login ถ้า username ว่างจะเรียกโดยไม่ยืนยันตัวตน
+// การเรียกที่ไม่สำเร็จจะไม่ถูกเรียกใหม่ เพื่อให้ทุกข้อผิดพลาดปรากฏในผลลัพธ์
+// ผู้เรียกต้องเรียก disconnect เมื่อใช้งานเสร็จ เพื่อหยุดการ refresh token และปิดการเชื่อมต่อ
+func dial(ctx context.Context, address string, username string, password string) (*client.LaptopClient, func(), error) {
+	options := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	closers := []func(){}
+	disconnect := func() {
+		for _, closer := range closers {
+			closer()
+		}
+	}
+
+	if username != "" {
+		cc1, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot connect to server: %w", err)
+		}
+		closers = append(closers, func() { cc1.Close() })
+
+		authClient := client.NewAuthClient(cc1, username, password)
+		authMethods := map[string]bool{
+			"/techshcool.pcbook.LaptopService/CreateLaptop": true,
+			"/techshcool.pcbook.LaptopService/UploadImage":  true,
+			"/techshcool.pcbook.LaptopService/RateLaptop":   true,
+		}
+		auth, err := client.NewAuthInterceptor(ctx, authClient, authMethods, refreshDuration)
+		if err != nil {
+			disconnect()
+			return nil, nil, fmt.Errorf("cannot login: %w", err)
+		}
+		// หยุด refresh ก่อนปิดการเชื่อมต่อที่ใช้ login
+		closers = append([]func(){auth.Close}, closers...)
+		options = append(options,
+			grpc.WithUnaryInterceptor(auth.Unary()),
+			grpc.WithStreamInterceptor(auth.Stream()),
+		)
+	}
+
+	cc2, err := grpc.NewClient(address, options...)
+	if err != nil {
+		disconnect()
+		return nil, nil, fmt.Errorf("cannot connect to server: %w", err)
+	}
+	closers = append(closers, func() { cc2.Close() })
+	return client.NewLaptopClient(cc2), disconnect, nil
+}
+
+// writeReport เขียนผลลัพธ์เป็น JSON ลงไฟล์ หรือ stdout เมื่อ output เป็น -
+func writeReport(result *report, output string) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal report: %w", err)
+	}
+	data = append(data, '\n')
+
+	if output == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	err = os.WriteFile(output, data, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot write report: %w", err)
+	}
+	return nil
+}
+
+func run() error {
+	address := flag.String("address", "0.0.0.0:8080", "the server address")
+	username := flag.String("username", "admin1", "the username to login with, empty calls the server without auth")
+	password := flag.String("password", "secret", "the password of the user")
+	duration := flag.Duration("duration", 30*time.Second, "how long to generate traffic")
+	concurrency := flag.Int("concurrency", 8, "the number of calls in flight at the same time")
+	rate := flag.Float64("rate", 0, "the calls per second of all workers together, 0 calls as fast as possible")
+	mixFlag := flag.String("mix", "create_laptop=3,search_laptop=1,upload_image=1,rate_laptop=5", "the weight of each operation")
+	timeout := flag.Duration("timeout", 5*time.Second, "the timeout of each call")
+	imagePath := flag.String("image", "tmp/laptop.jpg", "the image to upload")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "the seed of the sample laptops")
+	preload := flag.Int("preload", 10, "the number of laptops to create before the run")
+	output := flag.String("output", "-", "the file to write the JSON report, - writes to stdout")
+	flag.Parse()
+
+	if *concurrency < 1 {
+		return fmt.Errorf("-concurrency must be at least 1")
+	}
+	if *rate < 0 {
+		return fmt.Errorf("-rate cannot be negative")
+	}
+	mix, err := parseMix(*mixFlag)
+	if err != nil {
+		return err
+	}
+
+	var image []byte
+	if mix[operationUpload] > 0 {
+		image, err = os.ReadFile(*imagePath)
+		if err != nil {
+			return fmt.Errorf("cannot read image: %w", err)
+		}
+	}
+
+	// หยุดสร้างโหลดเมื่อผู้ใช้กด Ctrl+C แต่ยังรายงานผลของการเรียกที่เกิดขึ้นแล้ว
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	loginCtx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	laptopClient, disconnect, err := dial(loginCtx, *address, *username, *password)
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+
+	b := &benchmark{
+		laptopClient: laptopClient,
+		concurrency:  *concurrency,
+		rate:         *rate,
+		timeout:      *timeout,
+		mix:          mix,
+		seed:         *seed,
+		image:        image,
+		imageType:    filepath.Ext(*imagePath),
+		recorder:     newRecorder(),
+	}
+
+	preloadCtx, cancelPreload := context.WithTimeout(ctx, *timeout*time.Duration(max(*preload, 1)))
+	defer cancelPreload()
+	err = b.preload(preloadCtx, *preload)
+	if err != nil {
+		return err
+	}
+
+	result := b.run(ctx, *duration)
+	result.print(os.Stderr)
+	return writeReport(result, *output)
+}
+
+func main() {
+	err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// recorder เก็บ latency และรหัสสถานะของทุกการเรียก แยกตามชนิดของการเรียก
+type recorder struct {
+	mutex      sync.Mutex
+	operations map[string]*operationStats
+}
+
+type operationStats struct {
+	latencies []time.Duration
+	codes     map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{operations: map[string]*operationStats{}}
+}
+
+// record เก็บผลของการเรียกหนึ่งครั้ง err ที่ไม่ใช่สถานะของ gRPC นับเป็น Unknown
+func (r *recorder) record(operation string, latency time.Duration, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats := r.operations[operation]
+	if stats == nil {
+		stats = &operationStats{codes: map[string]int{}}
+		r.operations[operation] = stats
+	}
+	stats.latencies = append(stats.latencies, latency)
+	stats.codes[status.Code(err).String()]++
+}
+
+// report คือผลของการทดสอบที่เขียนเป็น JSON
+type report struct {
+	DurationSeconds float64                    `json:"duration_seconds"`
+	Concurrency     int                        `json:"concurrency"`
+	TargetRate      float64                    `json:"target_rate"` // 0 คือเรียกเร็วที่สุดเท่าที่ทำได้
+	SkippedTicks    int                        `json:"skipped_ticks"`
+	Total           operationReport            `json:"total"`
+	Operations      map[string]operationReport `json:"operations"`
+}
+
+// operationReport สรุปการเรียกชนิดหนึ่ง codes นับทุกการเรียกรวมถึงที่สำเร็จ (OK)
+type operationReport struct {
+	Requests   int            `json:"requests"`
+	Errors     int            `json:"errors"`
+	Throughput float64        `json:"throughput_per_second"`
+	LatencyMs  latencyReport  `json:"latency_ms"`
+	Codes      map[string]int `json:"codes"`
+}
+
+type latencyReport struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// report สรุปผลของการเรียกทั้งหมดที่เกิดขึ้นใน elapsed
+func (r *recorder) report(elapsed time.Duration) *report {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := &report{
+		DurationSeconds: elapsed.Seconds(),
+		Operations:      map[string]operationReport{},
+	}
+	total := &operationStats{codes: map[string]int{}}
+	for name, stats := range r.operations {
+		result.Operations[name] = summarize(stats, elapsed)
+		total.latencies = append(total.latencies, stats.latencies...)
+		for code, count := range stats.codes {
+			total.codes[code] += count
+		}
+	}
+	result.Total = summarize(total, elapsed)
+	return result
+}
+
+func summarize(stats *operationStats, elapsed time.Duration) operationReport {
+	latencies := append([]time.Duration{}, stats.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	summary := operationReport{
+		Requests:   len(latencies),
+		Errors:     len(latencies) - stats.codes["OK"],
+		Throughput: float64(len(latencies)) / math.Max(elapsed.Seconds(), 0.001),
+		Codes:      stats.codes,
+	}
+	if len(latencies) == 0 {
+		return summary
+	}
+
+	var sum time.Duration
+	for _, latency := range latencies {
+		sum += latency
+	}
+	summary.LatencyMs = latencyReport{
+		Min:  milliseconds(latencies[0]),
+		Mean: milliseconds(sum / time.Duration(len(latencies))),
+		P50:  milliseconds(percentile(latencies, 50)),
+		P90:  milliseconds(percentile(latencies, 90)),
+		P95:  milliseconds(percentile(latencies, 95)),
+		P99:  milliseconds(percentile(latencies, 99)),
+		Max:  milliseconds(latencies[len(latencies)-1]),
+	}
+	return summary
+}
+
+// percentile คืนค่า latency ที่ percentile p ของ latency ที่เรียงแล้ว แบบ nearest-rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// print เขียนสรุปผลแบบอ่านง่าย หนึ่งบรรทัดต่อการเรียกหนึ่งชนิด
+func (result *report) print(output io.Writer) {
+	names := make([]string, 0, len(result.Operations))
+	for name := range result.Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(output, "%.1fs, concurrency %d", result.DurationSeconds, result.Concurrency)
+	if result.TargetRate > 0 {
+		fmt.Fprintf(output, ", target %.1f req/s, %d ticks skipped", result.TargetRate, result.SkippedTicks)
+	}
+	fmt.Fprintln(output)
+
+	for _, name := range names {
+		printOperation(output, name, result.Operations[name])
+	}
+	printOperation(output, "total", result.Total)
+}
+
+func printOperation(output io.Writer, name string, summary operationReport) {
+	errors := []string{}
+	for code, count := range summary.Codes {
+		if code != "OK" {
+			errors = append(errors, fmt.Sprintf("%s=%d", code, count))
+		}
+	}
+	sort.Strings(errors)
+
+	fmt.Fprintf(
+		output, "%-14s %7d req %9.1f req/s  p50 %8.2fms  p95 %8.2fms  p99 %8.2fms  max %8.2fms  errors %d %s\n",
+		name, summary.Requests, summary.Throughput,
+		summary.LatencyMs.P50, summary.LatencyMs.P95, summary.LatencyMs.P99, summary.LatencyMs.Max,
+		summary.Errors, strings.Join(errors, " "),
+	)
+}
@@ -2,16 +2,29 @@ package main
 
 import (
 	"context" // เรียกใช้งาน package context เพื่อจัดการกับ context ของการเรียกใช้งาน RPC
-	"flag"    // เรียกใช้งาน package flag เพื่อจัดการกับ command-line flag
-	"fmt"     // เรียกใช้งาน package fmt เพื่อใช้ฟังก์ชัน Println และ Printf
-	"log"     // เรียกใช้งาน package log เพื่อ logging
-	"net"     // เรียกใช้งาน package net เพื่อใช้ net.Listen สำหรับเปิด port
-	"time"    // เรียกใช้งาน package time เพื่อการจัดการกับเวลา
+	"crypto/tls"
+	"crypto/x509"
+	"flag" // เรียกใช้งาน package flag เพื่อจัดการกับ command-line flag
+	"fmt"  // เรียกใช้งาน package fmt เพื่อใช้ฟังก์ชัน Println และ Printf
+	"log"  // เรียกใช้งาน package log เพื่อ logging
+	"log/slog"
+	"net" // เรียกใช้งาน package net เพื่อใช้ net.Listen สำหรับเปิด port
+	"net/http"
+	"os" // เรียกใช้งาน package os เพื่ออ่านค่า environment variable
+	"os/signal"
+	"syscall"
+	"time" // เรียกใช้งาน package time เพื่อการจัดการกับเวลา
 
+	"grpc-project/config"                // import server configuration
 	"grpc-project/example.com/pcbook/pb" // import protobuf generated code
+	"grpc-project/metrics"               // import metrics registry
 	"grpc-project/service"               // import local service package
+	"grpc-project/tracing"               // import span tracer และ exporter
 
 	"google.golang.org/grpc" // เรียกใช้งาน package grpc สำหรับการทำ gRPC
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	// เรียกใช้งาน package reflection สำหรับการทำ gRPC reflection
 )
@@ -37,13 +50,15 @@ func streamInterceptor(
 	return handler(srv, stream)                              // เรียก handler เพื่อดำเนินการ RPC และคืนค่า error ถ้ามี
 }
 
-// seedUsers เป็นฟังก์ชันเพื่อสร้างผู้ใช้เริ่มต้นใน user store
-func seedUsers(userStore service.UserStore) error {
-	err := createUser(userStore, "admin1", "secret", "admin")
-	if err != nil {
-		return err
+// seedUsers เป็นฟังก์ชันเพื่อสร้างผู้ใช้เริ่มต้นใน user store ตามที่กำหนดใน config
+func seedUsers(userStore service.UserStore, users []config.SeedUser) error {
+	for _, user := range users {
+		err := createUser(userStore, user.Username, user.Password, user.Role)
+		if err != nil {
+			return err
+		}
 	}
-	return createUser(userStore, "user1", "secret", "user")
+	return nil
 }
 
 // createUser เป็นฟังก์ชันเพื่อสร้างผู้ใช้ใหม่และบันทึกลงใน user store
@@ -52,81 +67,324 @@ func createUser(userStore service.UserStore, username, password, role string) er
 	if err != nil {
 		return err
 	}
-	return userStore.Save(user)
+	return userStore.Save(context.Background(), user)
 }
 
-const (
-	secretKey     = "secret"         // ค่าคีย์ลับสำหรับ JWT
-	tokenDuration = 15 * time.Minute // ระยะเวลาหมดอายุของ JWT
-)
+// newImageStore สร้าง image store ตามชนิดที่กำหนดใน config
+func newImageStore(cfg config.StoreConfig) (service.ImageStore, error) {
+	switch cfg.ImageStore {
+	case "disk":
+		store, _, err := service.OpenDiskImageStore(cfg.ImageFolder) // mismatches กับไฟล์ในโฟลเดอร์จะถูกบันทึกลง log
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "s3":
+		return service.NewS3ImageStore(service.S3Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Region:          cfg.S3.Region,
+			Bucket:          cfg.S3.Bucket,
+			Prefix:          cfg.S3.Prefix,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown image store: %s", cfg.ImageStore)
+	}
+}
 
-func accessibleRoles() map[string][]string {
-	const laptopServicePath = "/techschool.pcbook.LaptopService/"
+// newRateLimiter สร้าง rate limiter จากกฎใน config ถ้าไม่ได้เปิด rate limit จะจำกัดเฉพาะจำนวน stream
+func newRateLimiter(cfg config.RateLimitConfig) *service.RateLimiter {
+	toRule := func(rule config.RateLimitRule) service.RateLimitRule {
+		limit := service.RateLimitRule{
+			Method:     rule.Method,
+			Role:       rule.Role,
+			Rate:       rule.Rate,
+			Burst:      rule.Burst,
+			MaxStreams: rule.MaxStreams,
+		}
+		if !cfg.Enabled {
+			limit.Rate = 0
+		}
+		return limit
+	}
+
+	rules := make([]service.RateLimitRule, 0, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		rules = append(rules, toRule(rule))
+	}
+	return service.NewRateLimiter(toRule(cfg.Default), rules)
+}
 
-	return map[string][]string{
-		laptopServicePath + "CreateLaptop": {"admin"},
-		laptopServicePath + "UploadImage":  {"admin"},
-		laptopServicePath + "RateLaptop":   {"admin", "user"},
+// newTracer สร้าง tracer ที่ส่ง span ไปยัง exporter ตาม config และคืนค่า nil ถ้าปิด tracing ไว้
+// ถ้าเขียน span ลงไฟล์ จะคืนค่าไฟล์มาด้วยเพื่อปิดเมื่อเซิร์ฟเวอร์หยุดทำงาน
+func newTracer(cfg config.TracingConfig) (*tracing.Tracer, *os.File, error) {
+	switch cfg.Exporter {
+	case "stdout":
+		return tracing.NewTracer(tracing.NewStdoutExporter(os.Stdout)), nil, nil
+	case "otlp_file":
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot open trace file: %w", err)
+		}
+		return tracing.NewTracer(tracing.NewOTLPFileExporter(file, cfg.ServiceName)), file, nil
+	default:
+		return nil, nil, nil
 	}
 }
 
+// loadTLSCredentials โหลด certificate ของเซิร์ฟเวอร์ และ CA สำหรับตรวจสอบ client ถ้ามีการกำหนด (mutual TLS)
+func loadTLSCredentials(cfg config.TLSConfig) (credentials.TransportCredentials, error) {
+	serverCert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load server certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.NoClientCert,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pemClientCA, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read client CA certificate: %w", err)
+		}
+
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(pemClientCA) {
+			return nil, fmt.Errorf("cannot add client CA certificate from %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		tlsConfig.ClientCAs = certPool
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// setupLogging ตั้งค่า logger เริ่มต้นตาม config ข้อความจาก package log จะถูกส่งผ่าน logger นี้ด้วย
+func setupLogging(cfg config.LoggingConfig) *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.Level)) // ค่าถูกตรวจสอบแล้วใน config.Validate
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, options)
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	}
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	return logger
+}
+
 func main() {
-	fmt.Println("Hello World from server") // พิมพ์ Hello World from server ออกทาง console
+	// ตั้งค่าจาก flag
+	configFile := flag.String("config", "", "the config file (.yaml, .yml, .toml or .json), environment variables prefixed with "+config.EnvPrefix+" override it")
+	printConfig := flag.Bool("print-config", false, "print the effective config with secrets redacted and exit")
+	port := flag.Int("port", 0, "the server port, overrides server.port of the config") // กำหนด flag -port โดยใช้ Int และคำอธิบาย
+	flag.Parse()                                                                        // แปลง command-line flag เป็นค่าตัวแปร
 
-	// ตั้งค่าพอร์ตจาก flag
-	port := flag.Int("port", 0, "the server port") // กำหนด flag -port โดยใช้ Int และคำอธิบาย
-	flag.Parse()                                   // แปลง command-line flag เป็นค่าตัวแปร
+	// โหลด config จากไฟล์และ environment variable แล้วตรวจสอบความถูกต้อง
+	cfg, err := config.Load(*configFile, func(cfg *config.Config) {
+		if *port != 0 {
+			cfg.Server.Port = *port
+		}
+	})
+	if err != nil {
+		log.Fatal(err) // ถ้า config ไม่ถูกต้อง ให้แสดงปัญหาทั้งหมดและปิดโปรแกรม
+	}
 
-	// ตรวจสอบว่าพอร์ตได้รับการตั้งค่า
-	if *port == 0 {
-		log.Fatal("port must be set") // ถ้าพอร์ตยังไม่ได้ตั้งค่า ให้ log.Fatal และปิดโปรแกรม
+	if *printConfig {
+		err := cfg.Print(os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	log.Printf("start server on port %d", *port) // บันทึก log ว่าเริ่มเซิร์ฟเวอร์ที่พอร์ตที่ตั้งค่าไว้
+	fmt.Println("Hello World from server") // พิมพ์ Hello World from server ออกทาง console
+
+	logger := setupLogging(cfg.Logging)
+	logger.Info("start server", "address", cfg.Address()) // บันทึก log ว่าเริ่มเซิร์ฟเวอร์ที่พอร์ตที่ตั้งค่าไว้
 
 	userStore := service.NewInMemoryUserStore() // สร้าง in-memory user store
 
-	err := seedUsers(userStore) // เริ่มต้นผู้ใช้ใน user store
+	err = seedUsers(userStore, cfg.Auth.SeedUsers) // เริ่มต้นผู้ใช้ใน user store
 	if err != nil {
 		log.Fatal("cannot seed users: ", err) // ถ้ามี error ในการสร้างผู้ใช้ ให้ log.Fatal และปิดโปรแกรม
 	}
 
-	jwtManager := service.NewJWTManager(secretKey, tokenDuration) // สร้าง JWT manager
-	authServer := service.NewAuthServer(userStore, jwtManager)    // สร้าง authentication server
+	jwtManager := service.NewJWTManager(cfg.Auth.SecretKey, time.Duration(cfg.Auth.TokenDuration)) // สร้าง JWT manager
+	authServer := service.NewAuthServer(userStore, jwtManager)                                     // สร้าง authentication server
 
 	// สร้างเซิร์ฟเวอร์ gRPC
-	laptopStore := service.NewInMemoryLaptopStore()                               // สร้าง in-memory store สำหรับเก็บข้อมูล laptop
-	imageStore := service.NewDiskImageStore("img")                                // สร้าง disk image store สำหรับเก็บรูปภาพ
-	ratingStore := service.NewInMemoryRatingStore()                               // สร้าง in-memory store สำหรับเก็บ rating
-	laptopServer := service.NewLaptopServer(laptopStore, imageStore, ratingStore) // สร้าง instance ของ gRPC server โดยใช้ stores ที่สร้างขึ้นมา
-	interceptor := service.NewAuthInterceptor(jwtManager, accessibleRoles())
-	// serverOptions := []grpc.ServerOption{
-	// 	grpc.UnaryInterceptor(interceptor.Unary()),
-	// 	grpc.StreamInterceptor(interceptor.Stream()),
-	// }
-	
+	laptopStore := service.NewInMemoryLaptopStore() // สร้าง in-memory store สำหรับเก็บข้อมูล laptop
+	imageStore, err := newImageStore(cfg.Store)     // สร้าง image store สำหรับเก็บรูปภาพ
+	if err != nil {
+		log.Fatal("cannot create image store: ", err)
+	}
+	ratingStore := service.NewInMemoryRatingStore() // สร้าง in-memory store สำหรับเก็บ rating
+	ranker := service.NewRanker(ratingStore, service.RankingConfig{
+		PriorMean:   cfg.Ranking.PriorMean,
+		PriorWeight: cfg.Ranking.PriorWeight,
+		HalfLife:    time.Duration(cfg.Ranking.HalfLife),
+	}) // สร้างตัวคำนวณคะแนนสำหรับจัดอันดับแล็ปท็อป
+	laptopServer := service.NewLaptopServer(laptopStore, imageStore, ratingStore, ranker) // สร้าง instance ของ gRPC server โดยใช้ stores ที่สร้างขึ้นมา
+	laptopServer.SetMaxImageSize(cfg.Limits.MaxImageSize)
+	if cfg.Idempotency.TTL > 0 {
+		// เก็บการตอบสนองของคำขอที่มี idempotency key เพื่อให้ client ส่งคำขอซ้ำได้โดยไม่สร้างข้อมูลซ้ำ
+		laptopServer.SetIdempotencyStore(service.NewInMemoryIdempotencyStore(cfg.Idempotency.MaxKeysPerCaller), time.Duration(cfg.Idempotency.TTL))
+	}
+	interceptor := service.NewAuthInterceptor(jwtManager, cfg.Auth.AccessibleRoles)
+	logging := service.NewLoggingInterceptor(logger) // กำหนด request ID และ logger ของคำขอก่อน interceptor อื่น
+
+	// เก็บ metrics ของทุกคำขอ รวมถึงคำขอที่ถูกปฏิเสธโดย auth interceptor
+	registry := metrics.NewRegistry()
+	monitoring := service.NewMetricsInterceptor(registry)
+	service.RegisterStoreMetrics(registry, laptopStore, imageStore, ratingStore)
+
+	// แปลข้อความของข้อผิดพลาดตาม accept-language ของ client ต้องทำงานก่อน logging interceptor เพื่อให้ log เห็นสาเหตุของข้อผิดพลาด
+	locale := service.NewLocaleInterceptor()
+
+	unaryInterceptors := []grpc.UnaryServerInterceptor{monitoring.Unary(), locale.Unary(), logging.Unary(), interceptor.Unary()}
+	streamInterceptors := []grpc.StreamServerInterceptor{monitoring.Stream(), locale.Stream(), logging.Stream(), interceptor.Stream()}
+
+	// จำกัดจำนวนคำขอและ stream ที่เปิดค้างไว้ของผู้ใช้แต่ละคน ต้องทำงานหลัง auth interceptor เพื่อให้รู้ว่าผู้ใช้เป็นใคร
+	rateLimiter := newRateLimiter(cfg.RateLimit)
+	unaryInterceptors = append(unaryInterceptors, rateLimiter.Unary())
+	streamInterceptors = append(streamInterceptors, rateLimiter.Stream())
+
+	// เริ่ม span ของคำขอก่อน interceptor อื่น เพื่อให้ log มี trace_id และเวลาของ interceptor ถูกนับรวมใน span
+	tracer, traceFile, err := newTracer(cfg.Tracing)
+	if err != nil {
+		log.Fatal("cannot create tracer: ", err)
+	}
+	if tracer != nil {
+		tracingInterceptor := service.NewTracingInterceptor(tracer)
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{tracingInterceptor.Unary()}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{tracingInterceptor.Stream()}, streamInterceptors...)
+	}
+
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),   // ใช้ unary interceptor
+		grpc.ChainStreamInterceptor(streamInterceptors...), // ใช้ stream interceptor
+	}
+	if cfg.TLS.Enabled {
+		tlsCredentials, err := loadTLSCredentials(cfg.TLS)
+		if err != nil {
+			log.Fatal("cannot load TLS credentials: ", err)
+		}
+		serverOptions = append(serverOptions, grpc.Creds(tlsCredentials))
+	}
+
 	// สร้างเซิร์ฟเวอร์ gRPC
-	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(interceptor.Unary()),   // ใช้ unary interceptor
-		grpc.StreamInterceptor(interceptor.Stream()), // ใช้ stream interceptor
-	)
+	grpcServer := grpc.NewServer(serverOptions...)
 
 	pb.RegisterAuthServiceServer(grpcServer, authServer)     // ลงทะเบียน authentication service
 	pb.RegisterLaptopServiceServer(grpcServer, laptopServer) // ลงทะเบียน laptop service
-	
+
+	// ลงทะเบียน health service มาตรฐาน grpc.health.v1 โดยสถานะของแต่ละ service ขึ้นกับผลการตรวจความพร้อมของ stores
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	healthChecker := service.NewHealthChecker(
+		healthServer,
+		[]string{pb.AuthService_ServiceDesc.ServiceName, pb.LaptopService_ServiceDesc.ServiceName},
+		service.StoreReadinessChecks(map[string]interface{}{
+			"user store":   userStore,
+			"laptop store": laptopStore,
+			"image store":  imageStore,
+			"rating store": ratingStore,
+		}),
+		5*time.Second,
+	)
 
 	// ลงทะเบียน Reflection Service
 	reflection.Register(grpcServer)
 
 	// สร้าง listener และเริ่มฟัง
-	address := fmt.Sprintf("0.0.0.0:%d", *port) // สร้าง address string จากพอร์ตที่ตั้งค่าไว้
-	listener, err := net.Listen("tcp", address) // เปิด listener ด้วย address ที่สร้างขึ้น
+	listener, err := net.Listen("tcp", cfg.Address()) // เปิด listener ด้วย address จาก config
 	if err != nil {
 		log.Fatalf("cannot start listener: %v", err) // ถ้ามี error ในการเปิด listener ให้ log.Fatal และปิดโปรแกรม
 	}
 
+	// ctx จะถูกยกเลิกเมื่อได้รับ SIGINT หรือ SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go healthChecker.Run(ctx, time.Duration(cfg.Server.HealthCheckInterval))
+
+	// เปิด endpoint /metrics ในรูปแบบ Prometheus บน port แยกจาก gRPC
+	var metricsServer *http.Server
+	if cfg.Server.MetricsPort != 0 {
+		metricsServer, err = serveMetrics(fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.MetricsPort), registry)
+		if err != nil {
+			log.Fatalf("cannot start metrics server: %v", err)
+		}
+	}
+
 	// เริ่มเซิร์ฟเวอร์
-	if err := grpcServer.Serve(listener); err != nil {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
 		log.Fatalf("cannot start server: %v", err) // ถ้ามี error ในการเริ่มเซิร์ฟเวอร์ ให้ log.Fatal และปิดโปรแกรม
+	case <-ctx.Done():
+	}
+
+	shutdown(grpcServer, healthChecker, time.Duration(cfg.Server.DrainTimeout))
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+	if traceFile != nil {
+		traceFile.Close()
+	}
+}
+
+// serveMetrics เปิด HTTP server ที่ส่ง metrics ของ registry ที่ path /metrics
+func serveMetrics(address string, registry *metrics.Registry) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry.Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("metrics server stopped", "error", err)
+		}
+	}()
+	slog.Info("serving metrics", "address", listener.Addr().String())
+	return server, nil
+}
+
+// shutdown แจ้ง NOT_SERVING ผ่าน health service แล้วรอให้ RPC ที่กำลังทำงานอยู่ (เช่น UploadImage) เสร็จ
+// ถ้าเกิน drainTimeout จะปิด connection ที่เหลือทั้งหมดทันที
+func shutdown(grpcServer *grpc.Server, healthChecker *service.HealthChecker, drainTimeout time.Duration) {
+	log.Printf("shutting down, draining in-flight requests for up to %s", drainTimeout)
+	healthChecker.Drain()
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(drainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+		log.Print("server stopped")
+	case <-timer.C:
+		log.Print("drain timeout exceeded, closing remaining connections")
+		grpcServer.Stop()
+		<-stopped
 	}
 }
@@ -0,0 +1,371 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"grpc-project/service"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secret values when the config is printed
+const redacted = "<redacted>"
+
+// Config is the configuration of the pcbook server
+type Config struct {
+	Server  ServerConfig  `yaml:"server" toml:"server" json:"server"`
+	Store   StoreConfig   `yaml:"store" toml:"store" json:"store"`
+	TLS     TLSConfig     `yaml:"tls" toml:"tls" json:"tls"`
+	Auth    AuthConfig    `yaml:"auth" toml:"auth" json:"auth"`
+	Limits  LimitsConfig  `yaml:"limits" toml:"limits" json:"limits"`
+	Logging LoggingConfig `yaml:"logging" toml:"logging" json:"logging"`
+	Ranking RankingConfig `yaml:"ranking" toml:"ranking" json:"ranking"`
+	Tracing TracingConfig `yaml:"tracing" toml:"tracing" json:"tracing"`
+	// RateLimit caps the open streams of every caller, its request rates only apply when Enabled is true
+	RateLimit   RateLimitConfig   `yaml:"rate_limit" toml:"rate_limit" json:"rate_limit"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency" json:"idempotency"`
+}
+
+// ServerConfig contains the network and lifecycle settings of the server
+type ServerConfig struct {
+	Host                string   `yaml:"host" toml:"host" json:"host"`
+	Port                int      `yaml:"port" toml:"port" json:"port"`
+	DrainTimeout        Duration `yaml:"drain_timeout" toml:"drain_timeout" json:"drain_timeout"`
+	HealthCheckInterval Duration `yaml:"health_check_interval" toml:"health_check_interval" json:"health_check_interval"`
+	// MetricsPort is the HTTP port that serves /metrics, 0 disables the metrics endpoint
+	MetricsPort int `yaml:"metrics_port" toml:"metrics_port" json:"metrics_port"`
+}
+
+// StoreConfig selects and configures the storage backends
+type StoreConfig struct {
+	// ImageStore is the image store backend, "disk" or "s3"
+	ImageStore  string   `yaml:"image_store" toml:"image_store" json:"image_store"`
+	ImageFolder string   `yaml:"image_folder" toml:"image_folder" json:"image_folder"`
+	S3          S3Config `yaml:"s3" toml:"s3" json:"s3"`
+}
+
+// S3Config contains the settings of the S3 image store
+type S3Config struct {
+	Endpoint        string `yaml:"endpoint" toml:"endpoint" json:"endpoint"`
+	Region          string `yaml:"region" toml:"region" json:"region"`
+	Bucket          string `yaml:"bucket" toml:"bucket" json:"bucket"`
+	Prefix          string `yaml:"prefix" toml:"prefix" json:"prefix"`
+	AccessKeyID     string `yaml:"access_key_id" toml:"access_key_id" json:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" toml:"secret_access_key" json:"secret_access_key"`
+}
+
+// TLSConfig contains the TLS settings of the server
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled" toml:"enabled" json:"enabled"`
+	CertFile string `yaml:"cert_file" toml:"cert_file" json:"cert_file"`
+	KeyFile  string `yaml:"key_file" toml:"key_file" json:"key_file"`
+	// ClientCAFile enables mutual TLS when it is set
+	ClientCAFile string `yaml:"client_ca_file" toml:"client_ca_file" json:"client_ca_file"`
+}
+
+// AuthConfig contains the authentication and authorization settings
+type AuthConfig struct {
+	SecretKey     string     `yaml:"secret_key" toml:"secret_key" json:"secret_key"`
+	TokenDuration Duration   `yaml:"token_duration" toml:"token_duration" json:"token_duration"`
+	SeedUsers     []SeedUser `yaml:"seed_users" toml:"seed_users" json:"seed_users"`
+	// AccessibleRoles maps a full gRPC method name to the roles that can call it,
+	// methods that are not in the map can be called by anyone
+	AccessibleRoles map[string][]string `yaml:"accessible_roles" toml:"accessible_roles" json:"accessible_roles"`
+}
+
+// SeedUser is a user created when the server starts
+type SeedUser struct {
+	Username string `yaml:"username" toml:"username" json:"username"`
+	Password string `yaml:"password" toml:"password" json:"password"`
+	Role     string `yaml:"role" toml:"role" json:"role"`
+}
+
+// LimitsConfig contains the request size limits
+type LimitsConfig struct {
+	MaxImageSize int `yaml:"max_image_size" toml:"max_image_size" json:"max_image_size"`
+}
+
+// LoggingConfig contains the log settings
+type LoggingConfig struct {
+	// Level is one of debug, info, warn or error
+	Level string `yaml:"level" toml:"level" json:"level"`
+	// Format is text or json
+	Format string `yaml:"format" toml:"format" json:"format"`
+}
+
+// RankingConfig contains the parameters of the laptop ranking scores
+type RankingConfig struct {
+	PriorMean   float64  `yaml:"prior_mean" toml:"prior_mean" json:"prior_mean"`
+	PriorWeight float64  `yaml:"prior_weight" toml:"prior_weight" json:"prior_weight"`
+	HalfLife    Duration `yaml:"half_life" toml:"half_life" json:"half_life"`
+}
+
+// TracingConfig selects where the spans of requests are exported
+type TracingConfig struct {
+	// Exporter is none, stdout or otlp_file
+	Exporter string `yaml:"exporter" toml:"exporter" json:"exporter"`
+	// File is the file the otlp_file exporter appends to
+	File        string `yaml:"file" toml:"file" json:"file"`
+	ServiceName string `yaml:"service_name" toml:"service_name" json:"service_name"`
+}
+
+// RateLimitConfig contains the limits of requests and open streams of every caller.
+// A caller is an authenticated user, or the IP address of an anonymous client.
+type RateLimitConfig struct {
+	// Enabled turns on the request rates of the rules. The stream caps (MaxStreams) always apply,
+	// a MaxStreams of 0 disables them.
+	Enabled bool `yaml:"enabled" toml:"enabled" json:"enabled"`
+	// Default applies to the methods and roles that no rule matches
+	Default RateLimitRule `yaml:"default" toml:"default" json:"default"`
+	// Rules override the default, a rule that names both the method and the role
+	// takes precedence over one that names only the method, then only the role.
+	// A rule with only a method applies to every role, admins included.
+	Rules []RateLimitRule `yaml:"rules" toml:"rules" json:"rules"`
+}
+
+// RateLimitRule is a token bucket that refills Rate tokens per second up to Burst tokens.
+// A rate of 0 disables the request limit and MaxStreams of 0 disables the stream limit.
+type RateLimitRule struct {
+	// Method is a full method name, empty for all methods
+	Method string `yaml:"method" toml:"method" json:"method"`
+	// Role is a user role or "anonymous", empty for all callers
+	Role       string  `yaml:"role" toml:"role" json:"role"`
+	Rate       float64 `yaml:"rate" toml:"rate" json:"rate"`
+	Burst      int     `yaml:"burst" toml:"burst" json:"burst"`
+	MaxStreams int     `yaml:"max_streams" toml:"max_streams" json:"max_streams"`
+}
+
+// IdempotencyConfig contains the settings of the idempotency keys of CreateLaptop and UploadImage
+type IdempotencyConfig struct {
+	// TTL is how long the response to a key is replayed, 0 disables idempotency keys
+	TTL Duration `yaml:"ttl" toml:"ttl" json:"ttl"`
+	// MaxKeysPerCaller bounds the keys kept for every caller, 0 keeps any number of keys
+	MaxKeysPerCaller int `yaml:"max_keys_per_caller" toml:"max_keys_per_caller" json:"max_keys_per_caller"`
+}
+
+// Duration is a time.Duration written as a string such as "15m" in config files
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	value, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(value)
+	return nil
+}
+
+// Default returns the configuration used when no config file is given
+func Default() *Config {
+	ranking := service.DefaultRankingConfig()
+	return &Config{
+		Server: ServerConfig{
+			Host:                "0.0.0.0",
+			DrainTimeout:        Duration(30 * time.Second),
+			HealthCheckInterval: Duration(10 * time.Second),
+		},
+		Store: StoreConfig{
+			ImageStore:  "disk",
+			ImageFolder: "img",
+			S3:          S3Config{Region: "us-east-1"},
+		},
+		Auth: AuthConfig{
+			SecretKey:     "secret",
+			TokenDuration: Duration(15 * time.Minute),
+			SeedUsers: []SeedUser{
+				{Username: "admin1", Password: "secret", Role: "admin"},
+				{Username: "user1", Password: "secret", Role: "user"},
+			},
+			// the names use the package of the proto file, techshcool.pcbook. A name that matches no
+			// method leaves the method open to anyone, as CreateLaptop and UploadImage were when the
+			// names were spelled techschool.pcbook.
+			AccessibleRoles: map[string][]string{
+				"/techshcool.pcbook.LaptopService/CreateLaptop":  {"admin"},
+				"/techshcool.pcbook.LaptopService/CreateLaptops": {"admin"},
+				"/techshcool.pcbook.LaptopService/ExportCatalog": {"admin"},
+				"/techshcool.pcbook.LaptopService/ImportCatalog": {"admin"},
+				"/techshcool.pcbook.LaptopService/UploadImage":   {"admin"},
+				"/techshcool.pcbook.LaptopService/DeleteLaptop":  {"admin"},
+				"/techshcool.pcbook.LaptopService/RateLaptop":    {"admin", "user"},
+			},
+		},
+		Limits: LimitsConfig{
+			MaxImageSize: 1 << 20,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Ranking: RankingConfig{
+			PriorMean:   ranking.PriorMean,
+			PriorWeight: ranking.PriorWeight,
+			HalfLife:    Duration(ranking.HalfLife),
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "pcbook-server",
+		},
+		// request rates are opt-in, but the stream caps apply by default so that a user cannot keep
+		// unlimited SearchLaptop and RateLaptop streams open. The method rules name their roles,
+		// because a rule with a method takes precedence over a rule with only a role, and would
+		// also throttle admins.
+		RateLimit: RateLimitConfig{
+			Enabled: false,
+			Default: RateLimitRule{Rate: 20, Burst: 40, MaxStreams: 8},
+			Rules: []RateLimitRule{
+				{Method: "/techshcool.pcbook.LaptopService/SearchLaptop", Role: "user", Rate: 2, Burst: 5, MaxStreams: 2},
+				{Method: "/techshcool.pcbook.LaptopService/SearchLaptop", Role: "anonymous", Rate: 2, Burst: 5, MaxStreams: 2},
+				{Method: "/techshcool.pcbook.LaptopService/RateLaptop", Role: "user", Rate: 2, Burst: 5, MaxStreams: 2},
+				{Role: "admin", Rate: 100, Burst: 200, MaxStreams: 32},
+				{Role: "anonymous", Rate: 5, Burst: 10, MaxStreams: 2},
+			},
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:              Duration(24 * time.Hour),
+			MaxKeysPerCaller: 10000,
+		},
+	}
+}
+
+// Load returns the default config overridden by the config file, if filename is not empty,
+// then by environment variables and finally by the given overrides, such as command-line flags.
+// The result is validated.
+func Load(filename string, overrides ...func(config *Config)) (*Config, error) {
+	config := Default()
+
+	if filename != "" {
+		err := config.loadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := applyEnv(config, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	// the standard AWS variables are used when the S3 credentials are not configured
+	if config.Store.S3.AccessKeyID == "" {
+		config.Store.S3.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if config.Store.S3.SecretAccessKey == "" {
+		config.Store.S3.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	for _, override := range overrides {
+		override(config)
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadFile decodes the config file over the current values, the format is chosen by the file extension.
+// Unknown keys are rejected so that typos do not silently fall back to defaults.
+func (config *Config) loadFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	// decoders merge maps and list elements into existing ones,
+	// but a role map or a list in the file replaces the default one
+	defaultRoles := config.Auth.AccessibleRoles
+	defaultUsers := config.Auth.SeedUsers
+	defaultRules := config.RateLimit.Rules
+	config.Auth.AccessibleRoles = nil
+	config.Auth.SeedUsers = nil
+	config.RateLimit.Rules = nil
+	defer func() {
+		if config.Auth.AccessibleRoles == nil {
+			config.Auth.AccessibleRoles = defaultRoles
+		}
+		if config.Auth.SeedUsers == nil {
+			config.Auth.SeedUsers = defaultUsers
+		}
+		if config.RateLimit.Rules == nil {
+			config.RateLimit.Rules = defaultRules
+		}
+	}()
+
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(config)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(data), config)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown key %s", meta.Undecoded()[0])
+		}
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(config)
+	default:
+		return fmt.Errorf("unknown config file format %q, use .yaml, .yml, .toml or .json", ext)
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot parse config file %s: %w", filename, err)
+	}
+	return nil
+}
+
+// Address returns the address the server listens on
+func (config *Config) Address() string {
+	return fmt.Sprintf("%s:%d", config.Server.Host, config.Server.Port)
+}
+
+// Redacted returns a copy of the config where secrets are replaced, so that it can be printed
+func (config *Config) Redacted() *Config {
+	other := *config
+
+	if other.Auth.SecretKey != "" {
+		other.Auth.SecretKey = redacted
+	}
+	if other.Store.S3.SecretAccessKey != "" {
+		other.Store.S3.SecretAccessKey = redacted
+	}
+
+	other.Auth.SeedUsers = make([]SeedUser, len(config.Auth.SeedUsers))
+	for i, user := range config.Auth.SeedUsers {
+		user.Password = redacted
+		other.Auth.SeedUsers[i] = user
+	}
+	return &other
+}
+
+// Print writes the config as YAML with secrets redacted
+func (config *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	err := encoder.Encode(config.Redacted())
+	if err != nil {
+		return fmt.Errorf("cannot print config: %w", err)
+	}
+	return encoder.Close()
+}
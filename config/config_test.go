@@ -0,0 +1,283 @@
+package config_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"grpc-project/config"
+	"grpc-project/example.com/pcbook/pb"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testYAMLConfig = `
+server:
+  port: 8080
+  drain_timeout: 5s
+store:
+  image_store: s3
+  s3:
+    endpoint: http://localhost:9000
+    bucket: pcbook
+    access_key_id: key-id
+    secret_access_key: s3-secret
+auth:
+  secret_key: jwt-secret
+  token_duration: 1h
+  seed_users:
+    - username: root
+      password: root-password
+      role: admin
+  accessible_roles:
+    /techshcool.pcbook.LaptopService/CreateLaptop: [admin]
+limits:
+  max_image_size: 2048
+logging:
+  level: debug
+  format: json
+rate_limit:
+  enabled: true
+  rules:
+    - role: user
+      rate: 1
+      burst: 2
+`
+
+const testTOMLConfig = `
+[server]
+port = 8080
+drain_timeout = "5s"
+
+[store]
+image_store = "s3"
+
+[store.s3]
+endpoint = "http://localhost:9000"
+bucket = "pcbook"
+access_key_id = "key-id"
+secret_access_key = "s3-secret"
+
+[auth]
+secret_key = "jwt-secret"
+token_duration = "1h"
+
+[[auth.seed_users]]
+username = "root"
+password = "root-password"
+role = "admin"
+
+[auth.accessible_roles]
+"/techshcool.pcbook.LaptopService/CreateLaptop" = ["admin"]
+
+[limits]
+max_image_size = 2048
+
+[logging]
+level = "debug"
+format = "json"
+
+[rate_limit]
+enabled = true
+
+[[rate_limit.rules]]
+role = "user"
+rate = 1
+burst = 2
+`
+
+const testJSONConfig = `{
+  "server": {"port": 8080, "drain_timeout": "5s"},
+  "store": {
+    "image_store": "s3",
+    "s3": {"endpoint": "http://localhost:9000", "bucket": "pcbook", "access_key_id": "key-id", "secret_access_key": "s3-secret"}
+  },
+  "auth": {
+    "secret_key": "jwt-secret",
+    "token_duration": "1h",
+    "seed_users": [{"username": "root", "password": "root-password", "role": "admin"}],
+    "accessible_roles": {"/techshcool.pcbook.LaptopService/CreateLaptop": ["admin"]}
+  },
+  "limits": {"max_image_size": 2048},
+  "logging": {"level": "debug", "format": "json"},
+  "rate_limit": {"enabled": true, "rules": [{"role": "user", "rate": 1, "burst": 2}]}
+}`
+
+func TestLoadFileFormats(t *testing.T) {
+	testCases := []struct {
+		filename string
+		content  string
+	}{
+		{filename: "pcbook.yaml", content: testYAMLConfig},
+		{filename: "pcbook.toml", content: testTOMLConfig},
+		{filename: "pcbook.json", content: testJSONConfig},
+	}
+
+	for _, tc := range testCases {
+		cfg, err := config.Load(writeTestConfig(t, tc.filename, tc.content))
+		require.NoError(t, err, tc.filename)
+
+		require.Equal(t, "0.0.0.0:8080", cfg.Address(), tc.filename)
+		require.Equal(t, config.Duration(5*time.Second), cfg.Server.DrainTimeout, tc.filename)
+		require.Equal(t, config.Duration(10*time.Second), cfg.Server.HealthCheckInterval, tc.filename)
+		require.Equal(t, "s3", cfg.Store.ImageStore, tc.filename)
+		require.Equal(t, "us-east-1", cfg.Store.S3.Region, tc.filename)
+		require.Equal(t, "s3-secret", cfg.Store.S3.SecretAccessKey, tc.filename)
+		require.Equal(t, "jwt-secret", cfg.Auth.SecretKey, tc.filename)
+		require.Equal(t, config.Duration(time.Hour), cfg.Auth.TokenDuration, tc.filename)
+		require.Equal(t, []config.SeedUser{{Username: "root", Password: "root-password", Role: "admin"}}, cfg.Auth.SeedUsers, tc.filename)
+		require.Equal(t, map[string][]string{"/techshcool.pcbook.LaptopService/CreateLaptop": {"admin"}}, cfg.Auth.AccessibleRoles, tc.filename)
+		require.Equal(t, 2048, cfg.Limits.MaxImageSize, tc.filename)
+		require.Equal(t, "debug", cfg.Logging.Level, tc.filename)
+		require.Equal(t, "json", cfg.Logging.Format, tc.filename)
+		require.Equal(t, 5.5, cfg.Ranking.PriorMean, tc.filename)
+		require.True(t, cfg.RateLimit.Enabled, tc.filename)
+		require.Equal(t, config.RateLimitRule{Rate: 20, Burst: 40, MaxStreams: 8}, cfg.RateLimit.Default, tc.filename)
+		require.Equal(t, []config.RateLimitRule{{Role: "user", Rate: 1, Burst: 2}}, cfg.RateLimit.Rules, tc.filename)
+	}
+}
+
+func TestLoadEnvOverrides(t *testing.T) {
+	t.Setenv("PCBOOK_SERVER_PORT", "9090")
+	t.Setenv("PCBOOK_AUTH_SECRET_KEY", "env-secret")
+	t.Setenv("PCBOOK_AUTH_TOKEN_DURATION", "30m")
+	t.Setenv("PCBOOK_TLS_ENABLED", "false")
+	t.Setenv("PCBOOK_RANKING_PRIOR_MEAN", "7")
+
+	cfg, err := config.Load(writeTestConfig(t, "pcbook.yaml", testYAMLConfig))
+	require.NoError(t, err)
+	require.Equal(t, 9090, cfg.Server.Port)
+	require.Equal(t, "env-secret", cfg.Auth.SecretKey)
+	require.Equal(t, config.Duration(30*time.Minute), cfg.Auth.TokenDuration)
+	require.Equal(t, 7.0, cfg.Ranking.PriorMean)
+
+	cfg, err = config.Load("", func(cfg *config.Config) {
+		cfg.Server.Port = 7070
+	})
+	require.NoError(t, err)
+	require.Equal(t, 7070, cfg.Server.Port)
+	require.Len(t, cfg.Auth.AccessibleRoles, 7)
+
+	t.Setenv("PCBOOK_SERVER_PORT", "http")
+	_, err = config.Load("")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "PCBOOK_SERVER_PORT")
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	testCases := []struct {
+		filename string
+		content  string
+	}{
+		{filename: "pcbook.yaml", content: "server:\n  prot: 8080\n"},
+		{filename: "pcbook.toml", content: "[server]\nprot = 8080\n"},
+		{filename: "pcbook.json", content: `{"server": {"prot": 8080}}`},
+		{filename: "pcbook.ini", content: "port=8080"},
+	}
+
+	for _, tc := range testCases {
+		_, err := config.Load(writeTestConfig(t, tc.filename, tc.content))
+		require.Error(t, err, tc.filename)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Server.Port = 8080
+	require.NoError(t, cfg.Validate())
+
+	cfg.Server.MetricsPort = 8080
+	cfg.Store.ImageStore = "s3"
+	cfg.TLS.Enabled = true
+	cfg.TLS.KeyFile = filepath.Join(t.TempDir(), "missing.pem")
+	cfg.Auth.SecretKey = ""
+	cfg.Auth.SeedUsers = append(cfg.Auth.SeedUsers, config.SeedUser{Username: "admin1", Password: "x", Role: "admin"})
+	cfg.Auth.AccessibleRoles["CreateLaptop"] = []string{"admin"}
+	cfg.Logging.Level = "verbose"
+	cfg.Tracing.Exporter = "otlp_file"
+	cfg.Idempotency.TTL = config.Duration(-time.Hour)
+	cfg.Idempotency.MaxKeysPerCaller = -1
+
+	err := cfg.Validate()
+	var validationErr *config.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	require.Equal(t, []string{
+		"server.metrics_port: must differ from server.port",
+		"store.s3.endpoint: must be set for the s3 image store",
+		"store.s3.bucket: must be set for the s3 image store",
+		"store.s3.access_key_id: must be set for the s3 image store",
+		"store.s3.secret_access_key: must be set for the s3 image store",
+		"tls.cert_file: must be set when TLS is enabled",
+		"tls.key_file: stat " + cfg.TLS.KeyFile + ": no such file or directory",
+		"auth.secret_key: must be set",
+		`auth.seed_users[2].username: duplicate user "admin1"`,
+		`auth.accessible_roles: "CreateLaptop" is not a full method name such as /package.Service/Method`,
+		`logging.level: must be debug, info, warn or error, got "verbose"`,
+		"tracing.file: must be set for the otlp_file exporter",
+		"idempotency.ttl: must not be negative",
+		"idempotency.max_keys_per_caller: must not be negative",
+	}, validationErr.Problems)
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Store.S3.SecretAccessKey = "s3-secret"
+
+	var output bytes.Buffer
+	require.NoError(t, cfg.Print(&output))
+	require.NotContains(t, output.String(), "secret\n")
+	require.NotContains(t, output.String(), "s3-secret")
+	require.Contains(t, output.String(), "secret_key: <redacted>")
+	require.Contains(t, output.String(), "token_duration: 15m0s")
+
+	// the original config keeps its secrets
+	require.Equal(t, "secret", cfg.Auth.SecretKey)
+	require.Equal(t, "secret", cfg.Auth.SeedUsers[0].Password)
+}
+
+func writeTestConfig(t *testing.T, filename string, content string) string {
+	path := filepath.Join(t.TempDir(), filename)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestDefaultRateLimit(t *testing.T) {
+	cfg := config.Default()
+	require.False(t, cfg.RateLimit.Enabled)
+
+	// the streams of users are capped even though the request rates are off
+	require.Positive(t, cfg.RateLimit.Default.MaxStreams)
+	for _, rule := range cfg.RateLimit.Rules {
+		require.Positive(t, rule.MaxStreams, "%+v", rule)
+	}
+
+	// a rule with a method and no role would take precedence over the rule of the admin role
+	for _, rule := range cfg.RateLimit.Rules {
+		if rule.Method != "" {
+			require.NotEmpty(t, rule.Role, rule.Method)
+			require.NotEqual(t, "admin", rule.Role, rule.Method)
+		}
+	}
+}
+
+func TestDefaultAccessibleRolesNameMethods(t *testing.T) {
+	methods := map[string]bool{}
+	for _, method := range pb.LaptopService_ServiceDesc.Methods {
+		methods["/"+pb.LaptopService_ServiceDesc.ServiceName+"/"+method.MethodName] = true
+	}
+	for _, stream := range pb.LaptopService_ServiceDesc.Streams {
+		methods["/"+pb.LaptopService_ServiceDesc.ServiceName+"/"+stream.StreamName] = true
+	}
+
+	// a name that matches no method would leave the method without auth
+	for method := range config.Default().Auth.AccessibleRoles {
+		require.True(t, methods[method], method)
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of the environment variables that override config values.
+// The variable of a value is the prefix followed by the upper-cased path of its keys,
+// for example PCBOOK_AUTH_SECRET_KEY overrides auth.secret_key.
+const EnvPrefix = "PCBOOK_"
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// applyEnv overrides the scalar values of the config with the environment variables that are set.
+// Lists and maps such as the seed users and the role map can only be set in the config file.
+func applyEnv(config *Config, lookup func(string) (string, bool)) error {
+	return applyEnvToStruct(reflect.ValueOf(config).Elem(), strings.TrimSuffix(EnvPrefix, "_"), lookup)
+}
+
+func applyEnvToStruct(value reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := prefix + "_" + strings.ToUpper(yamlKey(field))
+		fieldValue := value.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			err := applyEnvToStruct(fieldValue, name, lookup)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		env, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		err := setFromString(fieldValue, env)
+		if err != nil {
+			return fmt.Errorf("invalid value of %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setFromString(value reflect.Value, s string) error {
+	if value.Type() == durationType {
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("%s values cannot be set from the environment", value.Kind())
+	}
+	return nil
+}
+
+// yamlKey returns the config file key of a struct field
+func yamlKey(field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if key == "" {
+		return field.Name
+	}
+	return key
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ValidationError lists all problems found in a config
+type ValidationError struct {
+	Problems []string
+}
+
+func (err *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(err.Problems, "\n  - ")
+}
+
+// Validate checks the config and returns a *ValidationError with every problem it finds
+func (config *Config) Validate() error {
+	v := &validator{}
+
+	v.check(config.Server.Port > 0 && config.Server.Port <= 65535, "server.port: must be between 1 and 65535, got %d", config.Server.Port)
+	v.check(config.Server.DrainTimeout >= 0, "server.drain_timeout: must not be negative")
+	v.check(config.Server.HealthCheckInterval > 0, "server.health_check_interval: must be positive")
+	v.check(config.Server.MetricsPort >= 0 && config.Server.MetricsPort <= 65535, "server.metrics_port: must be between 0 and 65535, got %d", config.Server.MetricsPort)
+	v.check(config.Server.MetricsPort == 0 || config.Server.MetricsPort != config.Server.Port, "server.metrics_port: must differ from server.port")
+
+	switch config.Store.ImageStore {
+	case "disk":
+		v.check(config.Store.ImageFolder != "", "store.image_folder: must be set for the disk image store")
+	case "s3":
+		v.check(config.Store.S3.Endpoint != "", "store.s3.endpoint: must be set for the s3 image store")
+		v.check(config.Store.S3.Bucket != "", "store.s3.bucket: must be set for the s3 image store")
+		v.check(config.Store.S3.AccessKeyID != "", "store.s3.access_key_id: must be set for the s3 image store")
+		v.check(config.Store.S3.SecretAccessKey != "", "store.s3.secret_access_key: must be set for the s3 image store")
+	default:
+		v.add("store.image_store: must be disk or s3, got %q", config.Store.ImageStore)
+	}
+
+	if config.TLS.Enabled {
+		v.checkFile("tls.cert_file", config.TLS.CertFile)
+		v.checkFile("tls.key_file", config.TLS.KeyFile)
+		if config.TLS.ClientCAFile != "" {
+			v.checkFile("tls.client_ca_file", config.TLS.ClientCAFile)
+		}
+	}
+
+	v.check(config.Auth.SecretKey != "", "auth.secret_key: must be set")
+	v.check(config.Auth.TokenDuration > 0, "auth.token_duration: must be positive")
+	usernames := make(map[string]bool)
+	for i, user := range config.Auth.SeedUsers {
+		v.check(user.Username != "", "auth.seed_users[%d].username: must be set", i)
+		v.check(user.Password != "", "auth.seed_users[%d].password: must be set", i)
+		v.check(user.Role != "", "auth.seed_users[%d].role: must be set", i)
+		v.check(!usernames[user.Username], "auth.seed_users[%d].username: duplicate user %q", i, user.Username)
+		usernames[user.Username] = true
+	}
+	methods := make([]string, 0, len(config.Auth.AccessibleRoles))
+	for method := range config.Auth.AccessibleRoles {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		roles := config.Auth.AccessibleRoles[method]
+		v.check(
+			strings.HasPrefix(method, "/") && strings.Count(method, "/") == 2,
+			"auth.accessible_roles: %q is not a full method name such as /package.Service/Method", method,
+		)
+		v.check(len(roles) > 0, "auth.accessible_roles[%s]: must list at least one role", method)
+	}
+
+	v.check(config.Limits.MaxImageSize > 0, "limits.max_image_size: must be positive")
+
+	switch config.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		v.add("logging.level: must be debug, info, warn or error, got %q", config.Logging.Level)
+	}
+	switch config.Logging.Format {
+	case "text", "json":
+	default:
+		v.add("logging.format: must be text or json, got %q", config.Logging.Format)
+	}
+
+	v.check(config.Ranking.PriorWeight >= 0, "ranking.prior_weight: must not be negative")
+	v.check(config.Ranking.HalfLife >= 0, "ranking.half_life: must not be negative")
+
+	switch config.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp_file":
+		v.check(config.Tracing.File != "", "tracing.file: must be set for the otlp_file exporter")
+	default:
+		v.add("tracing.exporter: must be none, stdout or otlp_file, got %q", config.Tracing.Exporter)
+	}
+	v.check(config.Tracing.ServiceName != "", "tracing.service_name: must be set")
+
+	v.checkRateLimitRule("rate_limit.default", config.RateLimit.Default)
+	for i, rule := range config.RateLimit.Rules {
+		name := fmt.Sprintf("rate_limit.rules[%d]", i)
+		v.checkRateLimitRule(name, rule)
+		v.check(
+			rule.Method == "" || strings.HasPrefix(rule.Method, "/") && strings.Count(rule.Method, "/") == 2,
+			"%s.method: %q is not a full method name such as /package.Service/Method", name, rule.Method,
+		)
+	}
+
+	v.check(config.Idempotency.TTL >= 0, "idempotency.ttl: must not be negative")
+	v.check(config.Idempotency.MaxKeysPerCaller >= 0, "idempotency.max_keys_per_caller: must not be negative")
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.add(format, args...)
+	}
+}
+
+func (v *validator) checkFile(key string, filename string) {
+	if filename == "" {
+		v.add("%s: must be set when TLS is enabled", key)
+		return
+	}
+	_, err := os.Stat(filename)
+	if err != nil {
+		v.add("%s: %v", key, err)
+	}
+}
+
+func (v *validator) checkRateLimitRule(name string, rule RateLimitRule) {
+	v.check(rule.Rate >= 0, "%s.rate: must not be negative", name)
+	v.check(rule.Rate == 0 || rule.Burst >= 1, "%s.burst: must be at least 1 when rate is set", name)
+	v.check(rule.MaxStreams >= 0, "%s.max_streams: must not be negative", name)
+}
@@ -21,10 +21,16 @@ import (
 const _ = grpc.SupportPackageIsVersion8
 
 const (
-	LaptopService_CreateLaptop_FullMethodName = "/techshcool.pcbook.LaptopService/CreateLaptop"
-	LaptopService_RateLaptop_FullMethodName   = "/techshcool.pcbook.LaptopService/RateLaptop"
-	LaptopService_SearchLaptop_FullMethodName = "/techshcool.pcbook.LaptopService/SearchLaptop"
-	LaptopService_UploadImage_FullMethodName  = "/techshcool.pcbook.LaptopService/UploadImage"
+	LaptopService_CreateLaptop_FullMethodName     = "/techshcool.pcbook.LaptopService/CreateLaptop"
+	LaptopService_RateLaptop_FullMethodName       = "/techshcool.pcbook.LaptopService/RateLaptop"
+	LaptopService_SearchLaptop_FullMethodName     = "/techshcool.pcbook.LaptopService/SearchLaptop"
+	LaptopService_UploadImage_FullMethodName      = "/techshcool.pcbook.LaptopService/UploadImage"
+	LaptopService_GetLaptopRatings_FullMethodName = "/techshcool.pcbook.LaptopService/GetLaptopRatings"
+	LaptopService_GetLaptop_FullMethodName        = "/techshcool.pcbook.LaptopService/GetLaptop"
+	LaptopService_DeleteLaptop_FullMethodName     = "/techshcool.pcbook.LaptopService/DeleteLaptop"
+	LaptopService_CreateLaptops_FullMethodName    = "/techshcool.pcbook.LaptopService/CreateLaptops"
+	LaptopService_ExportCatalog_FullMethodName    = "/techshcool.pcbook.LaptopService/ExportCatalog"
+	LaptopService_ImportCatalog_FullMethodName    = "/techshcool.pcbook.LaptopService/ImportCatalog"
 )
 
 // LaptopServiceClient is the client API for LaptopService service.
@@ -37,6 +43,12 @@ type LaptopServiceClient interface {
 	RateLaptop(ctx context.Context, opts ...grpc.CallOption) (LaptopService_RateLaptopClient, error)
 	SearchLaptop(ctx context.Context, in *SearchLaptopRequest, opts ...grpc.CallOption) (LaptopService_SearchLaptopClient, error)
 	UploadImage(ctx context.Context, opts ...grpc.CallOption) (LaptopService_UploadImageClient, error)
+	GetLaptopRatings(ctx context.Context, in *GetLaptopRatingsRequest, opts ...grpc.CallOption) (*GetLaptopRatingsResponse, error)
+	GetLaptop(ctx context.Context, in *GetLaptopRequest, opts ...grpc.CallOption) (*GetLaptopResponse, error)
+	DeleteLaptop(ctx context.Context, in *DeleteLaptopRequest, opts ...grpc.CallOption) (*DeleteLaptopResponse, error)
+	CreateLaptops(ctx context.Context, opts ...grpc.CallOption) (LaptopService_CreateLaptopsClient, error)
+	ExportCatalog(ctx context.Context, in *ExportCatalogRequest, opts ...grpc.CallOption) (LaptopService_ExportCatalogClient, error)
+	ImportCatalog(ctx context.Context, opts ...grpc.CallOption) (LaptopService_ImportCatalogClient, error)
 }
 
 type laptopServiceClient struct {
@@ -157,6 +169,133 @@ func (x *laptopServiceUploadImageClient) CloseAndRecv() (*UploadImageResponse, e
 	return m, nil
 }
 
+func (c *laptopServiceClient) GetLaptopRatings(ctx context.Context, in *GetLaptopRatingsRequest, opts ...grpc.CallOption) (*GetLaptopRatingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetLaptopRatingsResponse)
+	err := c.cc.Invoke(ctx, LaptopService_GetLaptopRatings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) GetLaptop(ctx context.Context, in *GetLaptopRequest, opts ...grpc.CallOption) (*GetLaptopResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetLaptopResponse)
+	err := c.cc.Invoke(ctx, LaptopService_GetLaptop_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) DeleteLaptop(ctx context.Context, in *DeleteLaptopRequest, opts ...grpc.CallOption) (*DeleteLaptopResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteLaptopResponse)
+	err := c.cc.Invoke(ctx, LaptopService_DeleteLaptop_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) CreateLaptops(ctx context.Context, opts ...grpc.CallOption) (LaptopService_CreateLaptopsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[3], LaptopService_CreateLaptops_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceCreateLaptopsClient{ClientStream: stream}
+	return x, nil
+}
+
+type LaptopService_CreateLaptopsClient interface {
+	Send(*CreateLaptopsRequest) error
+	Recv() (*CreateLaptopsResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceCreateLaptopsClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceCreateLaptopsClient) Send(m *CreateLaptopsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *laptopServiceCreateLaptopsClient) Recv() (*CreateLaptopsResponse, error) {
+	m := new(CreateLaptopsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) ExportCatalog(ctx context.Context, in *ExportCatalogRequest, opts ...grpc.CallOption) (LaptopService_ExportCatalogClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[4], LaptopService_ExportCatalog_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceExportCatalogClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LaptopService_ExportCatalogClient interface {
+	Recv() (*CatalogEntry, error)
+	grpc.ClientStream
+}
+
+type laptopServiceExportCatalogClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceExportCatalogClient) Recv() (*CatalogEntry, error) {
+	m := new(CatalogEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) ImportCatalog(ctx context.Context, opts ...grpc.CallOption) (LaptopService_ImportCatalogClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[5], LaptopService_ImportCatalog_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceImportCatalogClient{ClientStream: stream}
+	return x, nil
+}
+
+type LaptopService_ImportCatalogClient interface {
+	Send(*ImportCatalogRequest) error
+	Recv() (*ImportCatalogResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceImportCatalogClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceImportCatalogClient) Send(m *ImportCatalogRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *laptopServiceImportCatalogClient) Recv() (*ImportCatalogResponse, error) {
+	m := new(ImportCatalogResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // LaptopServiceServer is the server API for LaptopService service.
 // All implementations must embed UnimplementedLaptopServiceServer
 // for forward compatibility
@@ -167,6 +306,12 @@ type LaptopServiceServer interface {
 	RateLaptop(LaptopService_RateLaptopServer) error
 	SearchLaptop(*SearchLaptopRequest, LaptopService_SearchLaptopServer) error
 	UploadImage(LaptopService_UploadImageServer) error
+	GetLaptopRatings(context.Context, *GetLaptopRatingsRequest) (*GetLaptopRatingsResponse, error)
+	GetLaptop(context.Context, *GetLaptopRequest) (*GetLaptopResponse, error)
+	DeleteLaptop(context.Context, *DeleteLaptopRequest) (*DeleteLaptopResponse, error)
+	CreateLaptops(LaptopService_CreateLaptopsServer) error
+	ExportCatalog(*ExportCatalogRequest, LaptopService_ExportCatalogServer) error
+	ImportCatalog(LaptopService_ImportCatalogServer) error
 	mustEmbedUnimplementedLaptopServiceServer()
 }
 
@@ -186,6 +331,24 @@ func (UnimplementedLaptopServiceServer) SearchLaptop(*SearchLaptopRequest, Lapto
 func (UnimplementedLaptopServiceServer) UploadImage(LaptopService_UploadImageServer) error {
 	return status.Errorf(codes.Unimplemented, "method UploadImage not implemented")
 }
+func (UnimplementedLaptopServiceServer) GetLaptopRatings(context.Context, *GetLaptopRatingsRequest) (*GetLaptopRatingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLaptopRatings not implemented")
+}
+func (UnimplementedLaptopServiceServer) GetLaptop(context.Context, *GetLaptopRequest) (*GetLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) DeleteLaptop(context.Context, *DeleteLaptopRequest) (*DeleteLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) CreateLaptops(LaptopService_CreateLaptopsServer) error {
+	return status.Errorf(codes.Unimplemented, "method CreateLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) ExportCatalog(*ExportCatalogRequest, LaptopService_ExportCatalogServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportCatalog not implemented")
+}
+func (UnimplementedLaptopServiceServer) ImportCatalog(LaptopService_ImportCatalogServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportCatalog not implemented")
+}
 func (UnimplementedLaptopServiceServer) mustEmbedUnimplementedLaptopServiceServer() {}
 
 // UnsafeLaptopServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -290,6 +453,133 @@ func (x *laptopServiceUploadImageServer) Recv() (*UploadImageRequest, error) {
 	return m, nil
 }
 
+func _LaptopService_GetLaptopRatings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLaptopRatingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).GetLaptopRatings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LaptopService_GetLaptopRatings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).GetLaptopRatings(ctx, req.(*GetLaptopRatingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_GetLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).GetLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LaptopService_GetLaptop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).GetLaptop(ctx, req.(*GetLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_DeleteLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).DeleteLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LaptopService_DeleteLaptop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).DeleteLaptop(ctx, req.(*DeleteLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_CreateLaptops_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LaptopServiceServer).CreateLaptops(&laptopServiceCreateLaptopsServer{ServerStream: stream})
+}
+
+type LaptopService_CreateLaptopsServer interface {
+	Send(*CreateLaptopsResponse) error
+	Recv() (*CreateLaptopsRequest, error)
+	grpc.ServerStream
+}
+
+type laptopServiceCreateLaptopsServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceCreateLaptopsServer) Send(m *CreateLaptopsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *laptopServiceCreateLaptopsServer) Recv() (*CreateLaptopsRequest, error) {
+	m := new(CreateLaptopsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _LaptopService_ExportCatalog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportCatalogRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LaptopServiceServer).ExportCatalog(m, &laptopServiceExportCatalogServer{ServerStream: stream})
+}
+
+type LaptopService_ExportCatalogServer interface {
+	Send(*CatalogEntry) error
+	grpc.ServerStream
+}
+
+type laptopServiceExportCatalogServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceExportCatalogServer) Send(m *CatalogEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LaptopService_ImportCatalog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LaptopServiceServer).ImportCatalog(&laptopServiceImportCatalogServer{ServerStream: stream})
+}
+
+type LaptopService_ImportCatalogServer interface {
+	Send(*ImportCatalogResponse) error
+	Recv() (*ImportCatalogRequest, error)
+	grpc.ServerStream
+}
+
+type laptopServiceImportCatalogServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceImportCatalogServer) Send(m *ImportCatalogResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *laptopServiceImportCatalogServer) Recv() (*ImportCatalogRequest, error) {
+	m := new(ImportCatalogRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // LaptopService_ServiceDesc is the grpc.ServiceDesc for LaptopService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -301,6 +591,18 @@ var LaptopService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateLaptop",
 			Handler:    _LaptopService_CreateLaptop_Handler,
 		},
+		{
+			MethodName: "GetLaptopRatings",
+			Handler:    _LaptopService_GetLaptopRatings_Handler,
+		},
+		{
+			MethodName: "GetLaptop",
+			Handler:    _LaptopService_GetLaptop_Handler,
+		},
+		{
+			MethodName: "DeleteLaptop",
+			Handler:    _LaptopService_DeleteLaptop_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -319,6 +621,23 @@ var LaptopService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _LaptopService_UploadImage_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "CreateLaptops",
+			Handler:       _LaptopService_CreateLaptops_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportCatalog",
+			Handler:       _LaptopService_ExportCatalog_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportCatalog",
+			Handler:       _LaptopService_ImportCatalog_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "laptop_service.proto",
 }
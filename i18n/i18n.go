@@ -0,0 +1,87 @@
+// Package i18n is the message catalog of the pcbook services. Messages are written in English
+// in the code, and the English format string is the key of its translations, so a message
+// without a translation is simply sent in English.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locales supported by the catalog
+const (
+	English = "en-US"
+	Thai    = "th-TH"
+)
+
+// AcceptLanguageKey is the metadata key a client uses to choose the language of the messages
+const AcceptLanguageKey = "accept-language"
+
+// translations maps a locale to the translations of the English format strings
+var translations = map[string]map[string]string{
+	Thai: thai,
+}
+
+// Sprintf formats the translation of format in the given locale, or format itself if it has
+// no translation. Without args, the translation is returned as it is.
+func Sprintf(locale string, format string, args ...interface{}) string {
+	if translated, ok := translations[locale][format]; ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// MatchLocale returns the supported locale that best matches the values of an
+// Accept-Language header, such as "th-TH,th;q=0.9,en;q=0.8", or English if none matches
+func MatchLocale(acceptLanguages ...string) string {
+	type weightedTag struct {
+		language string
+		weight   float64
+	}
+
+	tags := []weightedTag{}
+	for _, acceptLanguage := range acceptLanguages {
+		for _, part := range strings.Split(acceptLanguage, ",") {
+			fields := strings.Split(part, ";")
+			tag := weightedTag{language: baseLanguage(fields[0]), weight: 1}
+			for _, param := range fields[1:] {
+				name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+				if !found || strings.TrimSpace(name) != "q" {
+					continue
+				}
+				weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					weight = 0
+				}
+				tag.weight = weight
+			}
+			if tag.language != "" && tag.weight > 0 {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].weight > tags[j].weight })
+
+	for _, tag := range tags {
+		switch tag.language {
+		case "th":
+			return Thai
+		case "en", "*":
+			return English
+		}
+	}
+	return English
+}
+
+// baseLanguage returns the lower case primary subtag of a language tag, "th" for "th-TH"
+func baseLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	language, _, _ := strings.Cut(tag, "-")
+	language, _, _ = strings.Cut(language, "_")
+	return language
+}
@@ -0,0 +1,53 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchLocale(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		acceptLanguages []string
+		locale          string
+	}{
+		{nil, English},
+		{[]string{""}, English},
+		{[]string{"th"}, Thai},
+		{[]string{"th-TH,th;q=0.9,en;q=0.8"}, Thai},
+		{[]string{"TH_th"}, Thai},
+		{[]string{"en-GB,th;q=0.5"}, English},
+		{[]string{"en;q=0.5,th;q=0.8"}, Thai},
+		{[]string{"fr-FR,th;q=0.3"}, Thai},
+		{[]string{"fr-FR,de"}, English},
+		{[]string{"th;q=0,en"}, English},
+		{[]string{"th;q=abc"}, English},
+		{[]string{"*;q=0.9,th;q=0.5"}, English},
+		{[]string{"fr", "th"}, Thai},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.locale, MatchLocale(tc.acceptLanguages...), "%q", tc.acceptLanguages)
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "laptop 42 is not found", Sprintf(English, "laptop %s is not found", "42"))
+	require.Equal(t, "ไม่พบแล็ปท็อป 42", Sprintf(Thai, "laptop %s is not found", "42"))
+
+	// messages without translation stay in English, and are not formatted without args
+	require.Equal(t, "disk is 100% full", Sprintf(Thai, "disk is 100% full"))
+}
+
+func TestThaiTranslations(t *testing.T) {
+	t.Parallel()
+
+	verb := regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+	for format, translated := range thai {
+		require.Equal(t, verb.FindAllString(format, -1), verb.FindAllString(translated, -1), format)
+	}
+}
@@ -0,0 +1,81 @@
+package i18n
+
+// thai holds the Thai translations, keyed by the English format strings used in the code.
+// A translation must use the same verbs in the same order as its key.
+var thai = map[string]string{
+	// apperror
+	"record not found":      "ไม่พบข้อมูล",
+	"record already exists": "ข้อมูลนี้มีอยู่แล้ว",
+	"request is canceled":   "คำขอถูกยกเลิก",
+	"deadline is exceeded":  "หมดเวลาในการดำเนินการ",
+	"internal error":        "เกิดข้อผิดพลาดภายในเซิร์ฟเวอร์",
+
+	// AuthInterceptor and AuthServer
+	"metadata is not provided":            "ไม่ได้ส่ง metadata มากับคำขอ",
+	"authorization token is not provided": "ไม่ได้ส่ง authorization token มากับคำขอ",
+	"access token is invalid":             "access token ไม่ถูกต้อง",
+	"no permission to access this RPC":    "ไม่มีสิทธิ์เรียกใช้ RPC นี้",
+	"user is not authenticated":           "ผู้ใช้ยังไม่ได้ยืนยันตัวตน",
+	"cannot find user":                    "ไม่สามารถค้นหาผู้ใช้",
+	"incorrect username/password":         "ชื่อผู้ใช้หรือรหัสผ่านไม่ถูกต้อง",
+	"cannot generate access token":        "ไม่สามารถสร้าง access token",
+
+	// LaptopServer
+	"cannot generate a new laptop ID":                         "ไม่สามารถสร้าง ID ใหม่สำหรับแล็ปท็อป",
+	"laptop %s already exists":                                "แล็ปท็อป %s มีอยู่แล้ว",
+	"laptop %s is not found":                                  "ไม่พบแล็ปท็อป %s",
+	"laptop %s has images or ratings, delete it with cascade": "แล็ปท็อป %s มีรูปภาพหรือคะแนนอยู่ ต้องลบแบบ cascade",
+	"cannot save laptop to the store":                         "ไม่สามารถบันทึกแล็ปท็อปลงใน store",
+	"cannot search laptops":                                   "ไม่สามารถค้นหาแล็ปท็อป",
+	"cannot send laptop":                                      "ไม่สามารถส่งแล็ปท็อป",
+	"cannot find laptop":                                      "ไม่สามารถดึงข้อมูลแล็ปท็อป",
+	"cannot delete laptop":                                    "ไม่สามารถลบแล็ปท็อป",
+	"cannot compute laptop score":                             "ไม่สามารถคำนวณคะแนนของแล็ปท็อป",
+	"cannot receive image info":                               "ไม่สามารถรับข้อมูลของรูปภาพ",
+	"cannot receive chunk data":                               "ไม่สามารถรับข้อมูลส่วนย่อยของรูปภาพ",
+	"cannot write chunk data":                                 "ไม่สามารถเขียนข้อมูลส่วนย่อยของรูปภาพ",
+	"image is too large: %d > %d bytes":                       "รูปภาพมีขนาดใหญ่เกินไป: %d > %d ไบต์",
+	"the image must be at most %d bytes":                      "รูปภาพต้องมีขนาดไม่เกิน %d ไบต์",
+	"cannot save image to the store":                          "ไม่สามารถบันทึกรูปภาพลงใน store",
+	"cannot send response":                                    "ไม่สามารถส่งการตอบสนอง",
+	"cannot receive stream request":                           "ไม่สามารถรับคำขอจาก stream",
+	"cannot send stream response":                             "ไม่สามารถส่งการตอบสนองผ่าน stream",
+	"score is out of range":                                   "คะแนนอยู่นอกช่วงที่กำหนด",
+	"must be between %d and %d, got %.2f":                     "ต้องอยู่ระหว่าง %d ถึง %d แต่ได้รับ %.2f",
+	"cannot add rating to the store":                          "ไม่สามารถเพิ่มคะแนนลงใน store",
+	"cannot find rating":                                      "ไม่สามารถดึงคะแนนของแล็ปท็อป",
+	"cannot list reviews":                                     "ไม่สามารถดึงรายการรีวิว",
+	"page token is invalid":                                   "page token ไม่ถูกต้อง",
+	"must be the next_page_token of a previous response":      "ต้องเป็น next_page_token จากการตอบสนองก่อนหน้า",
+
+	// idempotency keys
+	"idempotency key is invalid":                                             "idempotency key ไม่ถูกต้อง",
+	"cannot reserve idempotency key":                                         "ไม่สามารถจอง idempotency key",
+	"idempotency key %s was already used with a different request":           "idempotency key %s ถูกใช้กับคำขออื่นไปแล้ว",
+	"a request with idempotency key %s is in progress, retry later":          "คำขอที่ใช้ idempotency key %s กำลังดำเนินการอยู่ โปรดลองใหม่ภายหลัง",
+	"cannot read idempotent response":                                        "ไม่สามารถอ่านการตอบสนองที่บันทึกไว้",
+	"too many requests with an idempotency key are in progress, retry later": "มีคำขอที่ใช้ idempotency key กำลังดำเนินการอยู่มากเกินไป โปรดลองใหม่ภายหลัง",
+
+	// rate limits
+	"too many requests, retry after %s":             "ส่งคำขอมากเกินไป โปรดลองใหม่หลังจาก %s",
+	"too many open streams, at most %d are allowed": "เปิด stream มากเกินไป เปิดได้ไม่เกิน %d stream",
+
+	// validation
+	"laptop is invalid":                  "ข้อมูลแล็ปท็อปไม่ถูกต้อง",
+	"is required":                        "ต้องระบุค่า",
+	"must be a valid UUID":               "ต้องเป็น UUID ที่ถูกต้อง",
+	"must be positive":                   "ต้องมีค่ามากกว่าศูนย์",
+	"must be at most %d characters":      "ต้องมีความยาวไม่เกิน %d ตัวอักษร",
+	"must be at least number_cores (%d)": "ต้องมีค่าไม่น้อยกว่า number_cores (%d)",
+	"must be at least min_ghz (%g)":      "ต้องมีค่าไม่น้อยกว่า min_ghz (%g)",
+	"must be one of %s":                  "ต้องเป็นค่าใดค่าหนึ่งต่อไปนี้ %s",
+	"must have at least one storage":     "ต้องมี storage อย่างน้อยหนึ่งรายการ",
+	"must be between %d and %d":          "ต้องอยู่ระหว่าง %d ถึง %d",
+
+	// catalog export and import
+	"cannot list laptops":                               "ไม่สามารถดึงรายการแล็ปท็อป",
+	"cannot list ratings":                               "ไม่สามารถดึงรายการคะแนน",
+	"cannot import laptop":                              "ไม่สามารถนำเข้าแล็ปท็อป",
+	"catalog entry is invalid":                          "ข้อมูลรายการในแค็ตตาล็อกไม่ถูกต้อง",
+	"must be unique, %s is also the username of %s[%d]": "ต้องไม่ซ้ำกัน %s เป็นชื่อผู้ใช้ของ %s[%d] แล้ว",
+}
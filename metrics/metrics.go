@@ -0,0 +1,189 @@
+// Package metrics is a small metrics registry that exposes counters, gauges and histograms
+// in the Prometheus text exposition format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default upper bounds of histogram buckets, in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// contentType is the content type of the Prometheus text exposition format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// collector is a metric family that can write itself in the text format
+type collector interface {
+	name() string
+	write(w io.Writer) error
+}
+
+// Registry holds the metrics exposed by a server
+type Registry struct {
+	mutex      sync.Mutex
+	collectors map[string]collector
+}
+
+// NewRegistry returns a new empty registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// NewCounterVec registers and returns a new counter family with the given label names
+func (registry *Registry) NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	counter := &CounterVec{family: newFamily(name, help, "counter", labelNames)}
+	registry.register(counter)
+	return counter
+}
+
+// NewHistogramVec registers and returns a new histogram family with the given buckets and label names
+func (registry *Registry) NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	histogram := &HistogramVec{family: newFamily(name, help, "histogram", labelNames), buckets: buckets}
+	registry.register(histogram)
+	return histogram
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by value every time the metrics are collected
+func (registry *Registry) NewGaugeFunc(name string, help string, value func() float64) {
+	registry.register(&gaugeFunc{family: newFamily(name, help, "gauge", nil), value: value})
+}
+
+func (registry *Registry) register(c collector) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if registry.collectors[c.name()] != nil {
+		panic(fmt.Sprintf("metrics: %s is already registered", c.name()))
+	}
+	registry.collectors[c.name()] = c
+}
+
+// Write writes all metrics in the text exposition format, sorted by name
+func (registry *Registry) Write(w io.Writer) error {
+	registry.mutex.Lock()
+	collectors := make([]collector, 0, len(registry.collectors))
+	for _, c := range registry.collectors {
+		collectors = append(collectors, c)
+	}
+	registry.mutex.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].name() < collectors[j].name()
+	})
+
+	for _, c := range collectors {
+		err := c.write(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns an HTTP handler that serves the metrics of the registry
+func (registry *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body strings.Builder
+		err := registry.Write(&body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, body.String())
+	})
+}
+
+// family contains what all metric types share: the name, help text, type and label names
+type family struct {
+	metricName string
+	help       string
+	typeName   string
+	labelNames []string
+}
+
+func newFamily(name string, help string, typeName string, labelNames []string) family {
+	return family{metricName: name, help: help, typeName: typeName, labelNames: labelNames}
+}
+
+func (f *family) name() string {
+	return f.metricName
+}
+
+func (f *family) writeHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.metricName, escapeHelp(f.help), f.metricName, f.typeName)
+	return err
+}
+
+// labelKey joins label values into a map key, the values are checked against the label names
+func (f *family) labelKey(labelValues []string) string {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", f.metricName, len(f.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// labels formats label names and values as {name="value",...}, extra pairs are appended at the end
+func (f *family) labels(labelValues []string, extra ...string) string {
+	if len(f.labelNames) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(f.labelNames)+len(extra)/2)
+	for i, labelName := range f.labelNames {
+		pairs = append(pairs, labelName+`="`+escapeLabelValue(labelValues[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabelValue(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// sortedKeys returns the keys of a series map in a stable order
+func sortedKeys[T any](series map[string]T) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func splitKey(key string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	return strings.SplitN(key, "\xff", n)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
@@ -0,0 +1,84 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"grpc-project/metrics"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+
+	requests := registry.NewCounterVec("requests_total", "Total number of requests.", "method", "code")
+	requests.With("Create", "OK").Inc()
+	requests.With("Create", "OK").Add(2)
+	requests.With("Create", "NotFound").Inc()
+	requests.With(`a"b\c`, "OK").Inc()
+
+	latency := registry.NewHistogramVec("latency_seconds", "Request latency.", []float64{1, 0.1}, "method")
+	latency.With("Create").Observe(0.05)
+	latency.With("Create").Observe(0.5)
+	latency.With("Create").Observe(3)
+
+	registry.NewGaugeFunc("items", "Number of items.\nSecond line.", func() float64 { return 42 })
+
+	var out strings.Builder
+	require.NoError(t, registry.Write(&out))
+
+	expected := `# HELP items Number of items.\nSecond line.
+# TYPE items gauge
+items 42
+# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="Create",le="0.1"} 1
+latency_seconds_bucket{method="Create",le="1"} 2
+latency_seconds_bucket{method="Create",le="+Inf"} 3
+latency_seconds_sum{method="Create"} 3.55
+latency_seconds_count{method="Create"} 3
+# HELP requests_total Total number of requests.
+# TYPE requests_total counter
+requests_total{method="Create",code="NotFound"} 1
+requests_total{method="Create",code="OK"} 3
+requests_total{method="a\"b\\c",code="OK"} 1
+`
+	require.Equal(t, expected, out.String())
+}
+
+func TestRegistryMisuse(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	counter := registry.NewCounterVec("requests_total", "Total number of requests.", "method")
+
+	require.Panics(t, func() { registry.NewCounterVec("requests_total", "Again.") })
+	require.Panics(t, func() { counter.With("Create", "OK") })
+	require.Panics(t, func() { counter.With("Create").Add(-1) })
+}
+
+func TestRegistryHandler(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	registry.NewCounterVec("requests_total", "Total number of requests.").With().Inc()
+
+	server := httptest.NewServer(registry.Handler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", res.Header.Get("Content-Type"))
+	require.Contains(t, string(body), "requests_total 1\n")
+}
@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// CounterVec is a family of counters partitioned by label values
+type CounterVec struct {
+	family
+	mutex  sync.Mutex
+	series map[string]*Counter
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	mutex sync.Mutex
+	value float64
+}
+
+// With returns the counter of the given label values, in the order of the label names
+func (vec *CounterVec) With(labelValues ...string) *Counter {
+	key := vec.labelKey(labelValues)
+
+	vec.mutex.Lock()
+	defer vec.mutex.Unlock()
+
+	if vec.series == nil {
+		vec.series = make(map[string]*Counter)
+	}
+	counter := vec.series[key]
+	if counter == nil {
+		counter = &Counter{}
+		vec.series[key] = counter
+	}
+	return counter
+}
+
+// Inc adds one to the counter
+func (counter *Counter) Inc() {
+	counter.Add(1)
+}
+
+// Add adds a non-negative value to the counter
+func (counter *Counter) Add(value float64) {
+	if value < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	counter.value += value
+}
+
+// Value returns the current value of the counter
+func (counter *Counter) Value() float64 {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	return counter.value
+}
+
+func (vec *CounterVec) write(w io.Writer) error {
+	err := vec.writeHeader(w)
+	if err != nil {
+		return err
+	}
+
+	vec.mutex.Lock()
+	defer vec.mutex.Unlock()
+
+	for _, key := range sortedKeys(vec.series) {
+		labels := vec.labels(splitKey(key, len(vec.labelNames)))
+		_, err := fmt.Fprintf(w, "%s%s %s\n", vec.metricName, labels, formatFloat(vec.series[key].Value()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HistogramVec is a family of histograms partitioned by label values
+type HistogramVec struct {
+	family
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]*Histogram
+}
+
+// Histogram counts observed values in buckets
+type Histogram struct {
+	mutex   sync.Mutex
+	buckets []float64
+	counts  []uint64 // counts[i] is the number of values in (buckets[i-1], buckets[i]]
+	count   uint64
+	sum     float64
+}
+
+// With returns the histogram of the given label values, in the order of the label names
+func (vec *HistogramVec) With(labelValues ...string) *Histogram {
+	key := vec.labelKey(labelValues)
+
+	vec.mutex.Lock()
+	defer vec.mutex.Unlock()
+
+	if vec.series == nil {
+		vec.series = make(map[string]*Histogram)
+	}
+	histogram := vec.series[key]
+	if histogram == nil {
+		histogram = &Histogram{buckets: vec.buckets, counts: make([]uint64, len(vec.buckets))}
+		vec.series[key] = histogram
+	}
+	return histogram
+}
+
+// Observe adds a value to the histogram
+func (histogram *Histogram) Observe(value float64) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	for i, upperBound := range histogram.buckets {
+		if value <= upperBound {
+			histogram.counts[i]++
+			break
+		}
+	}
+	histogram.count++
+	histogram.sum += value
+}
+
+func (vec *HistogramVec) write(w io.Writer) error {
+	err := vec.writeHeader(w)
+	if err != nil {
+		return err
+	}
+
+	vec.mutex.Lock()
+	defer vec.mutex.Unlock()
+
+	for _, key := range sortedKeys(vec.series) {
+		labelValues := splitKey(key, len(vec.labelNames))
+		histogram := vec.series[key]
+
+		histogram.mutex.Lock()
+		cumulative := uint64(0)
+		for i, upperBound := range histogram.buckets {
+			cumulative += histogram.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", vec.metricName, vec.labels(labelValues, "le", formatFloat(upperBound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", vec.metricName, vec.labels(labelValues, "le", "+Inf"), histogram.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", vec.metricName, vec.labels(labelValues), formatFloat(histogram.sum))
+		_, err := fmt.Fprintf(w, "%s_count%s %d\n", vec.metricName, vec.labels(labelValues), histogram.count)
+		histogram.mutex.Unlock()
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gaugeFunc is a gauge whose value is computed when the metrics are collected
+type gaugeFunc struct {
+	family
+	value func() float64
+}
+
+func (gauge *gaugeFunc) write(w io.Writer) error {
+	err := gauge.writeHeader(w)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s %s\n", gauge.metricName, formatFloat(gauge.value()))
+	return err
+}
@@ -1,130 +1,522 @@
 package sample
 
 import (
-	"fmt"
 	"grpc-project/example.com/pcbook/pb"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
 
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// Generator generates sample laptops from a seed, the same seed and options always give
+// the same laptops. Only UpdatedAt differs, it is the time a laptop is generated unless
+// WithUpdatedAt fixes it. Laptops are configurations of the models in the embedded product table,
+// with the CPU, GPU, release year and price that go together. A Generator is not safe for
+// concurrent use.
+type Generator struct {
+	rand *rand.Rand
+
+	brands          []BrandWeight
+	minPriceUsd     float64
+	maxPriceUsd     float64
+	minRAMGigabytes int
+	maxRAMGigabytes int
+	minReleaseYear  int
+	maxReleaseYear  int
+	updatedAt       time.Time
+}
+
+// BrandWeight is the share of a brand among the generated laptops
+type BrandWeight struct {
+	Brand  string
+	Weight int
+}
+
+// defaultBrands is the brand mix when WithBrands is not used
+var defaultBrands = []BrandWeight{{"Apple", 1}, {"Dell", 1}, {"Lenovo", 1}}
+
+// GeneratorOption configures a Generator
+type GeneratorOption func(generator *Generator)
+
+// WithBrands sets the brand mix of the laptops, for example Apple twice as often as Dell
+// with WithBrands(BrandWeight{"Apple", 2}, BrandWeight{"Dell", 1}). A brand that is not in the
+// product table gets the models of every brand. Brands with a weight below 1 are ignored, and
+// the default mix is kept when no brand is left.
+func WithBrands(brands ...BrandWeight) GeneratorOption {
+	return func(generator *Generator) {
+		generator.brands = brands
+	}
+}
+
+// WithPriceRange sets the range of the price of the laptops in USD. The laptops are the
+// configurations closest to the range, priced within it. A reversed range is swapped.
+func WithPriceRange(min float64, max float64) GeneratorOption {
+	return func(generator *Generator) {
+		generator.minPriceUsd = min
+		generator.maxPriceUsd = max
+	}
+}
+
+// WithRAMRange sets the range of the RAM of the laptops in gigabytes. Models that have no
+// RAM option in the range are only used when no other model fits. A reversed range is swapped.
+func WithRAMRange(min int, max int) GeneratorOption {
+	return func(generator *Generator) {
+		generator.minRAMGigabytes = min
+		generator.maxRAMGigabytes = max
+	}
+}
+
+// WithReleaseYearRange sets the range of the release year of the laptops, a reversed range is swapped
+func WithReleaseYearRange(min int, max int) GeneratorOption {
+	return func(generator *Generator) {
+		generator.minReleaseYear = min
+		generator.maxReleaseYear = max
+	}
+}
+
+// WithUpdatedAt sets the update time of the laptops, which is the time they are generated by default
+func WithUpdatedAt(updatedAt time.Time) GeneratorOption {
+	return func(generator *Generator) {
+		generator.updatedAt = updatedAt
+	}
+}
+
+// NewGenerator returns a new generator of sample laptops
+func NewGenerator(seed int64, options ...GeneratorOption) *Generator {
+	generator := &Generator{
+		rand:            rand.New(rand.NewSource(seed)),
+		brands:          defaultBrands,
+		minPriceUsd:     500,
+		maxPriceUsd:     4000,
+		minRAMGigabytes: 4,
+		maxRAMGigabytes: 64,
+		minReleaseYear:  2020,
+		maxReleaseYear:  2024,
+	}
+	for _, option := range options {
+		option(generator)
+	}
+
+	// brands with no weight would make the total weight 0, and reversed ranges have no value to pick
+	brands := []BrandWeight{}
+	for _, brand := range generator.brands {
+		if brand.Weight > 0 {
+			brands = append(brands, brand)
+		}
+	}
+	if len(brands) == 0 {
+		brands = defaultBrands
+	}
+	generator.brands = brands
+	if generator.minPriceUsd > generator.maxPriceUsd {
+		generator.minPriceUsd, generator.maxPriceUsd = generator.maxPriceUsd, generator.minPriceUsd
+	}
+	if generator.minRAMGigabytes > generator.maxRAMGigabytes {
+		generator.minRAMGigabytes, generator.maxRAMGigabytes = generator.maxRAMGigabytes, generator.minRAMGigabytes
+	}
+	if generator.minReleaseYear > generator.maxReleaseYear {
+		generator.minReleaseYear, generator.maxReleaseYear = generator.maxReleaseYear, generator.minReleaseYear
+	}
+	return generator
+}
+
+// defaultGenerator ใช้กับฟังก์ชันระดับ package และ seed ด้วยเวลาปัจจุบัน
+var (
+	defaultMutex     sync.Mutex
+	defaultGenerator = NewGenerator(time.Now().UnixNano())
+)
+
 // NewKeyBoard returns a new sample keyboard
 func NewKeyBoard() *pb.Keyboard {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return defaultGenerator.NewKeyBoard()
+}
+
+// NewCPU returns a new sample CPU
+func NewCPU() *pb.CPU {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return defaultGenerator.NewCPU()
+}
+
+// NewGPU returns a new sample GPU
+func NewGPU() *pb.GPU {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return defaultGenerator.NewGPU()
+}
+
+// NewRAM returns a new sample RAM
+func NewRAM() *pb.Memory {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return defaultGenerator.NewRAM()
+}
+
+// NewSSD returns a new sample SSD
+func NewSSD() *pb.Storage {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return defaultGenerator.NewSSD()
+}
+
+// NewHDD returns a new sample HDD
+func NewHDD() *pb.Storage {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return defaultGenerator.NewHDD()
+}
+
+// NewScreen returns a new sample screen
+func NewScreen() *pb.Screen {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return defaultGenerator.NewScreen()
+}
+
+// NewLaptop returns a new sample laptop
+func NewLaptop() *pb.Laptop {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return defaultGenerator.NewLaptop()
+}
+
+// RandomLaptopScore returns a random laptop score
+func RandomLaptopScore() float64 {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return defaultGenerator.RandomLaptopScore()
+}
+
+// NewKeyBoard returns a new sample keyboard
+func (generator *Generator) NewKeyBoard() *pb.Keyboard {
 	keyboard := &pb.Keyboard{
-		Layout:  randomKeyboardLayout(),
-		Backlit: randomBool(),
+		Layout:  generator.randomKeyboardLayout(),
+		Backlit: generator.randomBool(),
 	}
-	fmt.Println(keyboard)
 	return keyboard
 }
 
-func NewCPU() *pb.CPU {
-	brand := randomCPUBrand()
-	name := randomCPUName(brand)
-
-	numberCores := randomInt(2, 8)
-	numberThreads := randomInt(numberCores, 12)
+// NewCPU returns a new sample CPU from the product table
+func (generator *Generator) NewCPU() *pb.CPU {
+	return newCPU(products.CPUs[generator.rand.Intn(len(products.CPUs))])
+}
 
-	minGhz := randomFloat64(2.0, 3.5)
-	maxGhz := randomFloat64(minGhz, 5.0)
+// NewGPU returns a new sample GPU from the product table
+func (generator *Generator) NewGPU() *pb.GPU {
+	return newGPU(products.GPUs[generator.rand.Intn(len(products.GPUs))])
+}
 
-	cpu := &pb.CPU{
-		Brand:         brand,
-		Name:          name,
-		NumberCores:   uint32(numberCores),
-		NumberThreads: uint32(numberThreads),
-		MinGhz:        minGhz,
-		MaxGhz:        maxGhz,
+// NewRAM returns a new sample RAM within the RAM range
+func (generator *Generator) NewRAM() *pb.Memory {
+	sizes := generator.ramSizes([]uint64{4, 8, 16, 32, 64})
+	if len(sizes) == 0 {
+		return gigabytes(uint64(generator.randomInt(generator.minRAMGigabytes, generator.maxRAMGigabytes)))
 	}
-	return cpu
+	return gigabytes(sizes[generator.rand.Intn(len(sizes))])
 }
 
-// NewGPU returns a new sample GPU
+// NewSSD returns a new sample SSD
+func (generator *Generator) NewSSD() *pb.Storage {
+	sizes := []uint64{256, 512, 1024, 2048}
+	return newStorage(pb.Storage_SSD, sizes[generator.rand.Intn(len(sizes))])
+}
 
-func NewGPU() *pb.GPU {
-	brand := randomGPUBrand()
-	name := randomGPUName(brand)
+// NewHDD returns a new sample HDD
+func (generator *Generator) NewHDD() *pb.Storage {
+	sizes := []uint64{500, 1000, 2000}
+	return newStorage(pb.Storage_HDD, sizes[generator.rand.Intn(len(sizes))])
+}
 
-	minGhz := randomFloat64(1.0, 1.5)
-	maxGhz := randomFloat64(minGhz, 2.0)
+// NewScreen returns a new sample screen of one of the laptop models
+func (generator *Generator) NewScreen() *pb.Screen {
+	model := products.Models[generator.rand.Intn(len(products.Models))]
+	screen, _ := generator.newScreen(model)
+	return screen
+}
 
-	memory := &pb.Memory{
-		Value: uint64(randomInt(2, 6)),
-		Unit:  pb.Memory_GIGABYTE,
+// NewLaptop returns a new sample laptop, with a brand picked by the weights of the brand mix
+func (generator *Generator) NewLaptop() *pb.Laptop {
+	target := generator.randomFloat64(generator.minPriceUsd, generator.maxPriceUsd)
+	laptop := generator.newLaptop(generator.randomLaptopBrand(), target)
+	laptop.PriceUsd = math.Min(math.Max(laptop.PriceUsd, generator.minPriceUsd), generator.maxPriceUsd)
+	return laptop
+}
+
+// NewLaptops returns n sample laptops in a random order. Unlike n calls to NewLaptop, each brand
+// gets exactly its share of n, and the prices are spread evenly over the price range: each of
+// n equal parts of the range has the price of one laptop, given to the configuration of the
+// brand closest to it.
+func (generator *Generator) NewLaptops(n int) []*pb.Laptop {
+	if n <= 0 {
+		return nil
 	}
-	gpu := &pb.GPU{
-		Brand:  brand,
-		Name:   name,
-		MinGhz: minGhz,
-		MaxGhz: maxGhz,
-		Memory: memory,
+
+	brands := generator.brandsOf(n)
+	priceStep := (generator.maxPriceUsd - generator.minPriceUsd) / float64(n)
+	prices := make([]float64, n)
+	for i := range prices {
+		min := generator.minPriceUsd + float64(i)*priceStep
+		prices[i] = math.Floor(generator.randomFloat64(min, min+priceStep)*100) / 100
 	}
-	return gpu
+	generator.rand.Shuffle(n, func(i, j int) {
+		prices[i], prices[j] = prices[j], prices[i]
+	})
+
+	laptops := make([]*pb.Laptop, n)
+	for i := range laptops {
+		laptops[i] = generator.newLaptop(brands[i], prices[i])
+		laptops[i].PriceUsd = prices[i]
+	}
+	return laptops
 }
 
-func NewRAM() *pb.Memory {
-	ram := &pb.Memory{
-		Value: uint64(randomInt(4, 64)),
-		Unit:  pb.Memory_GIGABYTE,
+// RandomLaptopScore returns a random laptop score
+func (generator *Generator) RandomLaptopScore() float64 {
+	return float64(generator.randomInt(1, 10))
+}
+
+// candidates คือจำนวนการจัดสเปกที่สุ่มมาเพื่อเลือกอันที่ราคาใกล้เป้าหมายที่สุด
+const candidates = 32
+
+// newLaptop returns the configuration of a model of brand whose price is the closest to priceUsd,
+// out of a few random configurations that are within the RAM and release year ranges
+func (generator *Generator) newLaptop(brand string, priceUsd float64) *pb.Laptop {
+	models := products.modelsOf(brand)
+	if len(models) == 0 {
+		models = products.Models
+	}
+
+	var best *pb.Laptop
+	found := 0
+	for attempt := 0; attempt < 4*candidates && found < candidates; attempt++ {
+		laptop, ok := generator.configure(models[generator.rand.Intn(len(models))], true)
+		if !ok {
+			continue
+		}
+		found++
+		if best == nil || math.Abs(laptop.PriceUsd-priceUsd) < math.Abs(best.PriceUsd-priceUsd) {
+			best = laptop
+		}
 	}
-	return ram
+	if best == nil {
+		// ไม่มีรุ่นใดอยู่ในช่วงที่กำหนด จึงปรับ RAM และปีให้อยู่ในช่วงแทน
+		best, _ = generator.configure(models[generator.rand.Intn(len(models))], false)
+	}
+
+	best.Id = generator.randomID()
+	best.Brand = brand
+	best.UpdatedAt = generator.updatedAtTimestamp()
+	return best
 }
 
-func NewSSD() *pb.Storage {
-	ssd := &pb.Storage{
-		Driver: pb.Storage_SSD,
-		Memory: &pb.Memory{
-			Value: uint64(randomInt(128, 1024)),
-			Unit:  pb.Memory_GIGABYTE,
+// configure returns a random configuration of a model priced from its parts. When strict is set,
+// it fails if the model has no RAM or release year within the ranges, otherwise they are
+// moved into the ranges.
+func (generator *Generator) configure(model *laptopModel, strict bool) (*pb.Laptop, bool) {
+	priceUsd := model.PriceUsd
+
+	cpu := model.cpus[generator.rand.Intn(len(model.cpus))]
+	priceUsd += cpu.PriceUsd - model.cheapestCPU()
+	firstYear := max(model.Years[0], cpu.Year)
+
+	// รุ่นที่มีกราฟิกในตัวมีโอกาสไม่มี GPU แยกเท่ากับ GPU แต่ละรุ่น
+	gpus := []*pb.GPU{}
+	choices := len(model.gpus)
+	if model.IntegratedGraphics {
+		choices++
+	}
+	if i := generator.rand.Intn(choices); i < len(model.gpus) {
+		gpu := model.gpus[i]
+		gpus = append(gpus, newGPU(gpu))
+		priceUsd += gpu.PriceUsd
+		firstYear = max(firstYear, gpu.Year)
+	}
+	priceUsd -= model.cheapestGPU()
+
+	ramSizes := generator.ramSizes(model.RAMGB)
+	if len(ramSizes) == 0 {
+		if strict {
+			return nil, false
+		}
+		ramSizes = []uint64{uint64(generator.minRAMGigabytes)}
+	}
+	ram := ramSizes[generator.rand.Intn(len(ramSizes))]
+	priceUsd += (float64(ram) - float64(model.RAMGB[0])) * model.RAMPricePerGB
+
+	firstYear = max(firstYear, generator.minReleaseYear)
+	lastYear := min(model.Years[1], generator.maxReleaseYear)
+	if firstYear > lastYear {
+		if strict {
+			return nil, false
+		}
+		firstYear, lastYear = generator.minReleaseYear, generator.maxReleaseYear
+	}
+	releaseYear := generator.randomInt(firstYear, lastYear)
+
+	storages, storagePriceUsd := generator.newStorages(model)
+	priceUsd += storagePriceUsd
+
+	screen, screenPriceUsd := generator.newScreen(model)
+	priceUsd += screenPriceUsd
+
+	keyboard := generator.NewKeyBoard()
+	keyboard.Backlit = keyboard.Backlit || model.Backlit
+
+	// ราคาต่างกันเล็กน้อยตามร้านค้า แล้วปัดเป็นราคาขายปลีกเช่น 1249.99
+	priceUsd *= generator.randomFloat64(0.97, 1.03)
+	priceUsd = math.Max(math.Round(priceUsd/50)*50, 50) - 0.01
+
+	laptop := &pb.Laptop{
+		Name:     model.Name,
+		Cpu:      newCPU(cpu),
+		Ram:      gigabytes(ram),
+		Gpus:     gpus,
+		Storages: storages,
+		Screen:   screen,
+		Keyboard: keyboard,
+		Weight: &pb.Laptop_WeightKg{
+			WeightKg: math.Round(generator.randomFloat64(model.WeightKg[0], model.WeightKg[1])*100) / 100,
 		},
+		PriceUsd:    priceUsd,
+		ReleaseYear: uint32(releaseYear),
 	}
-	return ssd
+	return laptop, true
 }
 
-func NewHDD() *pb.Storage {
-	hdd := &pb.Storage{
-		Driver: pb.Storage_HDD,
-		Memory: &pb.Memory{
-			Value: uint64(randomInt(128, 1024)),
-			Unit:  pb.Memory_GIGABYTE,
+// newStorages returns an SSD of the model, sometimes with a second SSD or an HDD,
+// and how much they add to the price of the model
+func (generator *Generator) newStorages(model *laptopModel) ([]*pb.Storage, float64) {
+	ssd := model.SSDGB[generator.rand.Intn(len(model.SSDGB))]
+	storages := []*pb.Storage{newStorage(pb.Storage_SSD, ssd)}
+	priceUsd := float64(ssd-model.SSDGB[0]) * 0.2
+
+	if model.MaxStorages < 2 || generator.rand.Intn(3) > 0 {
+		return storages, priceUsd
+	}
+	if len(model.HDDGB) > 0 {
+		hdd := model.HDDGB[generator.rand.Intn(len(model.HDDGB))]
+		return append(storages, newStorage(pb.Storage_HDD, hdd)), priceUsd + 40 + float64(hdd)*0.02
+	}
+	ssd = model.SSDGB[generator.rand.Intn(len(model.SSDGB))]
+	return append(storages, newStorage(pb.Storage_SSD, ssd)), priceUsd + float64(ssd)*0.12
+}
+
+// newScreen returns one of the screens of the model, and how much it adds to the price of
+// the model. Screens are listed from the cheapest.
+func (generator *Generator) newScreen(model *laptopModel) (*pb.Screen, float64) {
+	i := generator.rand.Intn(len(model.Screens))
+	product := model.Screens[i]
+	panel := product.Panels[generator.rand.Intn(len(product.Panels))]
+
+	screen := &pb.Screen{
+		SizeInch: product.SizeInch,
+		Resolution: &pb.Screen_Resolution{
+			Width:  product.Width,
+			Height: product.Height,
 		},
+		Panal:      pb.Screen_Panal(pb.Screen_Panal_value[panel]),
+		Multitouch: product.Multitouch,
+	}
+
+	priceUsd := float64(i) * 200
+	if panel == "OLED" && product.Panels[0] != "OLED" {
+		priceUsd += 150
 	}
-	return hdd
+	return screen, priceUsd
 }
 
-func NewScreen() *pb.Screen {
-	screen := pb.Screen{
-		SizeInch:   float32(randomInt(13, 17)),
-		Resolution: randomScreenResolution(),
-		Panal:      randomScreenPanal(),
-		Multitouch: randomBool(),
+// ramSizes returns the sizes in gigabytes that are within the RAM range
+func (generator *Generator) ramSizes(sizes []uint64) []uint64 {
+	within := []uint64{}
+	for _, size := range sizes {
+		if size >= uint64(generator.minRAMGigabytes) && size <= uint64(generator.maxRAMGigabytes) {
+			within = append(within, size)
+		}
 	}
-	return &screen
+	return within
 }
 
-func NewLaptop() *pb.Laptop {
-	brand := randomLaptopBrand()
-	name := randomLaptopName(brand)
-	Laptop := &pb.Laptop{
-		Id:       randomID(),
-		Brand:    brand,
-		Name:     name,
-		Cpu:      NewCPU(),
-		Ram:      NewRAM(),
-		Gpus:     []*pb.GPU{NewGPU()},
-		Storages: []*pb.Storage{NewSSD(), NewHDD()},
-		Screen:   NewScreen(),
-		Keyboard: NewKeyBoard(),
-		Weight: &pb.Laptop_WeightKg{
-			WeightKg: randomFloat64(1.0, 3.0),
-		},
-		PriceUsd:    randomFloat64(1500, 3000),
-		ReleaseYear: uint32(randomInt(2017, 2019)),
-		UpdatedAt:   timestamppb.Now(),
+func (generator *Generator) updatedAtTimestamp() *timestamppb.Timestamp {
+	if generator.updatedAt.IsZero() {
+		return timestamppb.Now()
 	}
-	return Laptop
+	return timestamppb.New(generator.updatedAt)
 }
 
-// RandomLaptopScore returns a random laptop score
-func RandomLaptopScore() float64 {
-	return float64(randomInt(1, 10))
-}
\ No newline at end of file
+// brandsOf returns the brands of n laptops in a random order, each brand with its share of n.
+// The laptops left after rounding down the shares go to the brands with the largest remainders.
+func (generator *Generator) brandsOf(n int) []string {
+	total := 0
+	for _, brand := range generator.brands {
+		total += brand.Weight
+	}
+
+	counts := make([]int, len(generator.brands))
+	remainders := make([]int, len(generator.brands))
+	assigned := 0
+	for i, brand := range generator.brands {
+		counts[i] = n * brand.Weight / total
+		remainders[i] = n * brand.Weight % total
+		assigned += counts[i]
+	}
+	for ; assigned < n; assigned++ {
+		largest := 0
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+		counts[largest]++
+		remainders[largest] = -1
+	}
+
+	brands := make([]string, 0, n)
+	for i, brand := range generator.brands {
+		for j := 0; j < counts[i]; j++ {
+			brands = append(brands, brand.Brand)
+		}
+	}
+	generator.rand.Shuffle(n, func(i, j int) {
+		brands[i], brands[j] = brands[j], brands[i]
+	})
+	return brands
+}
+
+func newCPU(product *cpuProduct) *pb.CPU {
+	return &pb.CPU{
+		Brand:         product.Brand,
+		Name:          product.Name,
+		NumberCores:   product.Cores,
+		NumberThreads: product.Threads,
+		MinGhz:        product.MinGhz,
+		MaxGhz:        product.MaxGhz,
+	}
+}
+
+func newGPU(product *gpuProduct) *pb.GPU {
+	return &pb.GPU{
+		Brand:  product.Brand,
+		Name:   product.Name,
+		MinGhz: product.MinGhz,
+		MaxGhz: product.MaxGhz,
+		Memory: gigabytes(product.MemoryGB),
+	}
+}
+
+func gigabytes(value uint64) *pb.Memory {
+	return &pb.Memory{Value: value, Unit: pb.Memory_GIGABYTE}
+}
+
+// newStorage returns a storage of a size in gigabytes, in terabytes from 1000 gigabytes
+// as drives are sold
+func newStorage(driver pb.Storage_Driver, size uint64) *pb.Storage {
+	memory := gigabytes(size)
+	if size >= 1000 {
+		memory = &pb.Memory{Value: size / 1000, Unit: pb.Memory_TERABYTE}
+	}
+	return &pb.Storage{Driver: driver, Memory: memory}
+}
@@ -0,0 +1,174 @@
+package sample_test
+
+import (
+	"testing"
+	"time"
+
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/validation"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGeneratorSeed(t *testing.T) {
+	t.Parallel()
+
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	generator1 := sample.NewGenerator(42, sample.WithUpdatedAt(updatedAt))
+	generator2 := sample.NewGenerator(42, sample.WithUpdatedAt(updatedAt))
+	for i := 0; i < 10; i++ {
+		laptop1 := generator1.NewLaptop()
+		laptop2 := generator2.NewLaptop()
+		require.True(t, proto.Equal(laptop1, laptop2), "%v != %v", laptop1, laptop2)
+	}
+
+	other := sample.NewGenerator(43, sample.WithUpdatedAt(updatedAt)).NewLaptop()
+	require.NotEqual(t, sample.NewGenerator(42).NewLaptop().GetId(), other.GetId())
+}
+
+func TestGeneratorOptions(t *testing.T) {
+	t.Parallel()
+
+	generator := sample.NewGenerator(1,
+		sample.WithBrands(sample.BrandWeight{Brand: "Apple", Weight: 1}),
+		sample.WithPriceRange(500, 600),
+		sample.WithRAMRange(8, 16),
+		sample.WithReleaseYearRange(2023, 2024),
+	)
+	for i := 0; i < 50; i++ {
+		laptop := generator.NewLaptop()
+		require.Equal(t, "Apple", laptop.GetBrand())
+		require.GreaterOrEqual(t, laptop.GetPriceUsd(), 500.0)
+		require.LessOrEqual(t, laptop.GetPriceUsd(), 600.0)
+		require.GreaterOrEqual(t, laptop.GetRam().GetValue(), uint64(8))
+		require.LessOrEqual(t, laptop.GetRam().GetValue(), uint64(16))
+		require.GreaterOrEqual(t, laptop.GetReleaseYear(), uint32(2023))
+		require.LessOrEqual(t, laptop.GetReleaseYear(), uint32(2024))
+	}
+}
+
+func TestGeneratorInvalidOptions(t *testing.T) {
+	t.Parallel()
+
+	// brands without a positive weight are ignored, and the default mix is used when none is left
+	for _, brands := range [][]sample.BrandWeight{
+		{},
+		{{Brand: "Apple", Weight: 0}, {Brand: "Dell", Weight: -1}},
+	} {
+		generator := sample.NewGenerator(1, sample.WithBrands(brands...))
+		require.Contains(t, []string{"Apple", "Dell", "Lenovo"}, generator.NewLaptop().GetBrand())
+		require.Len(t, generator.NewLaptops(5), 5)
+	}
+
+	generator := sample.NewGenerator(1, sample.WithBrands(
+		sample.BrandWeight{Brand: "Apple", Weight: 0},
+		sample.BrandWeight{Brand: "Dell", Weight: 1},
+	))
+	for _, laptop := range generator.NewLaptops(5) {
+		require.Equal(t, "Dell", laptop.GetBrand())
+	}
+
+	// reversed ranges are swapped
+	generator = sample.NewGenerator(1,
+		sample.WithPriceRange(2000, 1000),
+		sample.WithRAMRange(16, 8),
+		sample.WithReleaseYearRange(2024, 2022),
+	)
+	for i := 0; i < 20; i++ {
+		laptop := generator.NewLaptop()
+		require.GreaterOrEqual(t, laptop.GetPriceUsd(), 1000.0)
+		require.LessOrEqual(t, laptop.GetPriceUsd(), 2000.0)
+		require.GreaterOrEqual(t, laptop.GetReleaseYear(), uint32(2022))
+		require.LessOrEqual(t, laptop.GetReleaseYear(), uint32(2024))
+
+		ram := generator.NewRAM()
+		require.GreaterOrEqual(t, ram.GetValue(), uint64(8))
+		require.LessOrEqual(t, ram.GetValue(), uint64(16))
+	}
+
+	// a range with no RAM size of the product table still gives a RAM within it
+	generator = sample.NewGenerator(1, sample.WithRAMRange(12, 10))
+	ram := generator.NewRAM()
+	require.GreaterOrEqual(t, ram.GetValue(), uint64(10))
+	require.LessOrEqual(t, ram.GetValue(), uint64(12))
+	require.NotNil(t, generator.NewLaptop())
+}
+
+func TestGeneratorNewLaptops(t *testing.T) {
+	t.Parallel()
+
+	generator := sample.NewGenerator(7,
+		sample.WithBrands(
+			sample.BrandWeight{Brand: "Apple", Weight: 2},
+			sample.BrandWeight{Brand: "Dell", Weight: 1},
+			sample.BrandWeight{Brand: "Lenovo", Weight: 1},
+		),
+		sample.WithPriceRange(1000, 2000),
+	)
+	laptops := generator.NewLaptops(10)
+	require.Len(t, laptops, 10)
+
+	// 10 แบ่งตามสัดส่วน 2:1:1 ได้ 5, 2.5 และ 2.5 เศษที่เหลือตกเป็นของ Dell ซึ่งมาก่อน Lenovo
+	brands := map[string]int{}
+	prices := make([]bool, 10)
+	for _, laptop := range laptops {
+		brands[laptop.GetBrand()]++
+		prices[int(laptop.GetPriceUsd()-1000)/100] = true
+	}
+	require.Equal(t, map[string]int{"Apple": 5, "Dell": 3, "Lenovo": 2}, brands)
+	for i, found := range prices {
+		require.True(t, found, "no laptop priced between %d and %d", 1000+i*100, 1100+i*100)
+	}
+}
+
+func TestGeneratorConsistentLaptops(t *testing.T) {
+	t.Parallel()
+
+	generator := sample.NewGenerator(3)
+	layouts := map[pb.Keyboard_Layout]int{}
+	gpuCounts := map[int]int{}
+	storageCounts := map[int]int{}
+	for _, laptop := range generator.NewLaptops(300) {
+		require.NoError(t, validation.ValidateLaptop(laptop))
+		require.LessOrEqual(t, laptop.GetScreen().GetResolution().GetHeight(), uint32(2400))
+
+		// แล็ปท็อปของ Apple ใช้ชิปของ Apple เท่านั้นและไม่มี GPU แยก
+		if laptop.GetBrand() == "Apple" {
+			require.Equal(t, "Apple", laptop.GetCpu().GetBrand())
+			require.Empty(t, laptop.GetGpus())
+		} else {
+			require.NotEqual(t, "Apple", laptop.GetCpu().GetBrand())
+		}
+
+		layouts[laptop.GetKeyboard().GetLayout()]++
+		gpuCounts[len(laptop.GetGpus())]++
+		storageCounts[len(laptop.GetStorages())]++
+	}
+
+	require.Len(t, layouts, 3)
+	require.Len(t, gpuCounts, 2)
+	require.Len(t, storageCounts, 2)
+}
+
+func TestGeneratorPriceFollowsSpecs(t *testing.T) {
+	t.Parallel()
+
+	// แล็ปท็อปที่ถูกที่สุดและแพงที่สุดในช่วงราคาต้องมีสเปกต่างกันตามราคา
+	generator := sample.NewGenerator(5,
+		sample.WithBrands(sample.BrandWeight{Brand: "Dell", Weight: 1}),
+		sample.WithPriceRange(500, 700),
+	)
+	cheap := generator.NewLaptops(1)[0]
+	generator = sample.NewGenerator(5,
+		sample.WithBrands(sample.BrandWeight{Brand: "Dell", Weight: 1}),
+		sample.WithPriceRange(3500, 4000),
+	)
+	expensive := generator.NewLaptops(1)[0]
+
+	require.Less(t, cheap.GetPriceUsd(), expensive.GetPriceUsd())
+	require.Less(t, cheap.GetCpu().GetNumberThreads(), expensive.GetCpu().GetNumberThreads())
+	require.Empty(t, cheap.GetGpus())
+	require.NotEmpty(t, expensive.GetGpus())
+}
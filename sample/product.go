@@ -0,0 +1,145 @@
+package sample
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// productsJSON is the table of CPUs, GPUs and laptop models that sample laptops are built from.
+// Frequencies, cores and threads are the real values of each part, and prices are rough list prices.
+//
+//go:embed products.json
+var productsJSON []byte
+
+type cpuProduct struct {
+	Brand    string  `json:"brand"`
+	Name     string  `json:"name"`
+	Cores    uint32  `json:"cores"`
+	Threads  uint32  `json:"threads"`
+	MinGhz   float64 `json:"min_ghz"`
+	MaxGhz   float64 `json:"max_ghz"`
+	Year     int     `json:"year"`
+	PriceUsd float64 `json:"price_usd"`
+}
+
+type gpuProduct struct {
+	Brand    string  `json:"brand"`
+	Name     string  `json:"name"`
+	MemoryGB uint64  `json:"memory_gb"`
+	MinGhz   float64 `json:"min_ghz"`
+	MaxGhz   float64 `json:"max_ghz"`
+	Year     int     `json:"year"`
+	PriceUsd float64 `json:"price_usd"`
+}
+
+type screenProduct struct {
+	SizeInch   float32  `json:"size_inch"`
+	Width      uint32   `json:"width"`
+	Height     uint32   `json:"height"`
+	Panels     []string `json:"panels"`
+	Multitouch bool     `json:"multitouch"`
+}
+
+// laptopModel is a laptop model with the parts it can be configured with.
+// Its price is the price of the cheapest configuration.
+type laptopModel struct {
+	Brand              string          `json:"brand"`
+	Name               string          `json:"name"`
+	PriceUsd           float64         `json:"price_usd"`
+	Years              [2]int          `json:"years"`
+	CPUs               []string        `json:"cpus"`
+	GPUs               []string        `json:"gpus"`
+	IntegratedGraphics bool            `json:"integrated_graphics"` // ไม่มี GPU แยกได้
+	RAMGB              []uint64        `json:"ram_gb"`
+	RAMPricePerGB      float64         `json:"ram_price_per_gb"`
+	SSDGB              []uint64        `json:"ssd_gb"`
+	HDDGB              []uint64        `json:"hdd_gb"`
+	MaxStorages        int             `json:"max_storages"`
+	Screens            []screenProduct `json:"screens"`
+	WeightKg           [2]float64      `json:"weight_kg"`
+	Backlit            bool            `json:"backlit"` // false คือมีทั้งรุ่นที่มีและไม่มีไฟคีย์บอร์ด
+
+	cpus []*cpuProduct
+	gpus []*gpuProduct
+}
+
+type productTable struct {
+	CPUs   []*cpuProduct  `json:"cpus"`
+	GPUs   []*gpuProduct  `json:"gpus"`
+	Models []*laptopModel `json:"models"`
+}
+
+var products = mustLoadProducts()
+
+// mustLoadProducts parses the embedded product table and links each model to its parts
+func mustLoadProducts() *productTable {
+	table := &productTable{}
+	err := json.Unmarshal(productsJSON, table)
+	if err != nil {
+		panic(fmt.Sprintf("cannot parse products.json: %v", err))
+	}
+
+	cpus := map[string]*cpuProduct{}
+	for _, cpu := range table.CPUs {
+		cpus[cpu.Name] = cpu
+	}
+	gpus := map[string]*gpuProduct{}
+	for _, gpu := range table.GPUs {
+		gpus[gpu.Name] = gpu
+	}
+
+	for _, model := range table.Models {
+		for _, name := range model.CPUs {
+			if cpus[name] == nil {
+				panic(fmt.Sprintf("products.json: unknown CPU %q in %s", name, model.Name))
+			}
+			model.cpus = append(model.cpus, cpus[name])
+		}
+		for _, name := range model.GPUs {
+			if gpus[name] == nil {
+				panic(fmt.Sprintf("products.json: unknown GPU %q in %s", name, model.Name))
+			}
+			model.gpus = append(model.gpus, gpus[name])
+		}
+		if len(model.cpus) == 0 || len(model.RAMGB) == 0 || len(model.SSDGB) == 0 || len(model.Screens) == 0 {
+			panic(fmt.Sprintf("products.json: %s needs CPUs, RAM, SSDs and screens", model.Name))
+		}
+		if len(model.gpus) == 0 && !model.IntegratedGraphics {
+			panic(fmt.Sprintf("products.json: %s needs GPUs or integrated graphics", model.Name))
+		}
+	}
+	return table
+}
+
+// modelsOf returns the laptop models of a brand
+func (table *productTable) modelsOf(brand string) []*laptopModel {
+	models := []*laptopModel{}
+	for _, model := range table.Models {
+		if model.Brand == brand {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// cheapestCPU returns the price of the cheapest CPU of the model, which its price includes
+func (model *laptopModel) cheapestCPU() float64 {
+	cheapest := model.cpus[0].PriceUsd
+	for _, cpu := range model.cpus {
+		cheapest = min(cheapest, cpu.PriceUsd)
+	}
+	return cheapest
+}
+
+// cheapestGPU returns the price of the cheapest GPU of the model, 0 with integrated graphics
+func (model *laptopModel) cheapestGPU() float64 {
+	if model.IntegratedGraphics {
+		return 0
+	}
+	cheapest := model.gpus[0].PriceUsd
+	for _, gpu := range model.gpus {
+		cheapest = min(cheapest, gpu.PriceUsd)
+	}
+	return cheapest
+}
@@ -2,131 +2,58 @@ package sample
 
 import (
 	"grpc-project/example.com/pcbook/pb"
-	"math/rand"
-	"time"
 
 	"github.com/google/uuid"
 )
 
-func init(){
-	rand.Seed(time.Now().UnixNano())
-}
-
-func randomKeyboardLayout() pb.Keyboard_Layout {
-	switch rand.Intn(3) {
-	case 1:
-		return pb.Keyboard_QWERTY
-	case 2:
+// randomKeyboardLayout picks QWERTY for most laptops, then QWERTZ and AZERTY
+// for the German and French markets
+func (generator *Generator) randomKeyboardLayout() pb.Keyboard_Layout {
+	switch n := generator.rand.Intn(10); {
+	case n < 7:
 		return pb.Keyboard_QWERTY
+	case n < 9:
+		return pb.Keyboard_QWERTZ
 	default:
 		return pb.Keyboard_AZERTY
 	}
 }
 
-func randomCPUBrand() string {
-	return randomStringFromSet("Intel", "AMD")
+func (generator *Generator) randomBool() bool {
+	return generator.rand.Intn(2) == 1
 }
 
-func randomCPUName(brand string) string {
-	if brand == "Intel" {
-		return randomStringFromSet(
-			"Xeon E-2286M",
-			"Core i9-9980HK",
-			"Core i7-9750H",
-			"Core i5-9400F",
-			"Core i3-1005G1",
-		)
-	}
-	return randomStringFromSet(
-		"Ryzen 7 Pro 2700U",
-		"Ryzen 5 Pro 3500U",
-		"Ryzen 3 Pro 3200GE",
-	)
+func (generator *Generator) randomInt(min int, max int) int {
+	// min +0 = min
+	// min + max -min = max
+	return min + generator.rand.Intn(max-min+1)
 }
 
-func randomGPUBrand() string {
-	return randomStringFromSet("NVIDIA", "AMD")
+func (generator *Generator) randomFloat64(min float64, max float64) float64 {
+	return min + generator.rand.Float64()*(max-min)
 }
 
-func randomGPUName(brand string) string {
-	if brand == "NVIDIA" {
-		return randomStringFromSet(
-			"RTX 2060",
-			"RTX 2070",
-			"RTX 1660-Ti",
-			"RTX 1070",
-		)
+func (generator *Generator) randomID() string{
+	// ใช้ตัวสุ่มของ generator เพื่อให้ seed เดียวกันได้ ID เดียวกัน
+	id, err := uuid.NewRandomFromReader(generator.rand)
+	if err != nil {
+		return uuid.New().String()
 	}
-	return randomStringFromSet(
-		"RX 590",
-		"RX 580",
-		"RX 5700-XT",
-		"RX Vega-56",
-	)
+	return id.String()
 }
 
-func randomScreenResolution() *pb.Screen_Resolution {
-	height := randomInt(1080, 4320)
-	width := height * 16 / 9
 
-	resolution := &pb.Screen_Resolution{
-		Height: uint32(height),
-		Width:  uint32(width),
+func (generator *Generator) randomLaptopBrand() string{
+	total := 0
+	for _, brand := range generator.brands {
+		total += brand.Weight
 	}
-	return resolution
-
-}
-
-func randomScreenPanal() pb.Screen_Panal {
-	if rand.Intn(2) == 1 {
-		return pb.Screen_IPS
-	}
-	return pb.Screen_OLED
-}
-
-func randomStringFromSet(a ...string) string {
-	n := len(a)
-	if n == 0 {
-		return ""
+	n := generator.rand.Intn(total)
+	for _, brand := range generator.brands {
+		if n < brand.Weight {
+			return brand.Brand
+		}
+		n -= brand.Weight
 	}
-	return a[rand.Intn(n)]
-}
-
-func randomBool() bool {
-	return rand.Intn(2) == 1
-}
-
-func randomInt(min int, max int) int {
-	// min +0 = min
-	// min + max -min = max
-	return min + rand.Intn(max-min+1)
-}
-
-func randomFloat64(min float64, max float64) float64 {
-	return min + rand.Float64()*(max-min)
-}
-
-func randomFloat32(min float32, max float32) float32 {
-	return min + rand.Float32()*(max-min)
+	return ""
 }
-
-func randomID() string{
-	
-	return uuid.New().String()
-}
-
-
-func randomLaptopBrand() string{
-	return randomStringFromSet("Apple", "Dell","Lenovo")
-}
-
-func randomLaptopName(brand string) string{
-	switch brand {
-	case "Apple":
-		return randomStringFromSet("Macbook Air","Max")
-	case "Dell":
-		return randomStringFromSet("Latitude","Vostro","XPS","AlienWare")
-	default:
-		return randomStringFromSet("Thinkpad X1","Thinkpad P1", "Thinkpad P53")
-	}
-}
\ No newline at end of file
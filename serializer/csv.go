@@ -0,0 +1,549 @@
+package serializer
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// CsvError is an error in a cell of a CSV file. Row and Column start at 1, and the header is
+// row 1. Column is 0 when the error is about the whole row.
+type CsvError struct {
+	Row    int
+	Column int
+	Header string
+	Err    error
+}
+
+func (err *CsvError) Error() string {
+	if err.Column == 0 {
+		return fmt.Sprintf("row %d: %v", err.Row, err.Err)
+	}
+	return fmt.Sprintf("row %d, column %d (%s): %v", err.Row, err.Column, err.Header, err.Err)
+}
+
+func (err *CsvError) Unwrap() error {
+	return err.Err
+}
+
+// CsvOptions configures the columns of ProtobufToCsv and CsvToProtobuf
+type CsvOptions struct {
+	// Inline is the name of a message field of the messages whose columns have no prefix, like id
+	// instead of laptop.id, so that the other fields add columns to the columns of its message
+	Inline string
+}
+
+// csvStep is one field in the path of a CSV column, with the index of the element for a repeated field
+type csvStep struct {
+	field  protoreflect.FieldDescriptor
+	index  int  // -1 ถ้าไม่ใช่ repeated field
+	inline bool // ชื่อของ field ไม่อยู่ใน header
+}
+
+// csvColumn is the path from the message of a row to the field of a column
+type csvColumn []csvStep
+
+// String returns the header of the column, like cpu.number_cores or storages[1].memory.value
+func (column csvColumn) String() string {
+	var header strings.Builder
+	for _, step := range column {
+		if step.inline {
+			continue
+		}
+		if header.Len() > 0 {
+			header.WriteByte('.')
+		}
+		header.WriteString(string(step.field.Name()))
+		if step.index >= 0 {
+			fmt.Fprintf(&header, "[%d]", step.index)
+		}
+	}
+	return header.String()
+}
+
+// ProtobufToCsv writes protocol buffer messages as rows of a CSV with one column per field.
+// Nested messages are flattened into dotted columns, and repeated fields get one column per
+// element up to the longest list in messages. A Timestamp is written in RFC 3339 in one column.
+func ProtobufToCsv[T proto.Message](w io.Writer, messages []T, options CsvOptions) error {
+	var message T
+	descriptor := message.ProtoReflect().Descriptor()
+	rows := make([]protoreflect.Message, len(messages))
+	for i, message := range messages {
+		rows[i] = message.ProtoReflect()
+	}
+
+	inline, err := csvInlineField(descriptor, options)
+	if err != nil {
+		return err
+	}
+	columns, err := csvColumns(descriptor, rows, nil, inline, map[protoreflect.FullName]bool{})
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	seen := map[string]bool{}
+	for i, column := range columns {
+		header[i] = column.String()
+		if seen[header[i]] {
+			return fmt.Errorf("column %s of inlined field %s is also the column of another field", header[i], options.Inline)
+		}
+		seen[header[i]] = true
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return fmt.Errorf("cannot write CSV header: %w", err)
+	}
+
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			record[i] = csvCell(row, column)
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return fmt.Errorf("cannot write CSV row: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// CsvToProtobuf reads the rows of a CSV written by ProtobufToCsv as protocol buffer messages.
+// Columns can be in any order and any of them can be left out. Empty cells leave their field
+// unset, and a nested message or list element is only set when one of its cells is not empty.
+// Errors are *CsvError with the row and column of the cell.
+func CsvToProtobuf[T proto.Message](r io.Reader, options CsvOptions) ([]T, error) {
+	var message T
+	messageType := message.ProtoReflect().Type()
+	inline, err := csvInlineField(messageType.Descriptor(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, &CsvError{Row: 1, Err: errors.New("header is missing")}
+	}
+	if err != nil {
+		return nil, &CsvError{Row: 1, Err: err}
+	}
+
+	columns := make([]csvColumn, len(header))
+	for i, name := range header {
+		columns[i], err = parseCsvColumn(messageType.Descriptor(), name, inline)
+		if err != nil {
+			return nil, &CsvError{Row: 1, Column: i + 1, Header: name, Err: err}
+		}
+	}
+
+	messages := []T{}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return nil, &CsvError{Row: row, Err: err}
+		}
+
+		message := messageType.New()
+		lists := map[string]*csvList{}
+		for i, cell := range record {
+			if cell == "" {
+				continue
+			}
+			err = setCsvCell(message, columns[i], cell, lists)
+			if err != nil {
+				return nil, &CsvError{Row: row, Column: i + 1, Header: header[i], Err: err}
+			}
+		}
+		for _, list := range lists {
+			list.appendElements()
+		}
+		messages = append(messages, message.Interface().(T))
+	}
+}
+
+// csvInlineField returns the field of options.Inline, or nil if no field is inlined
+func csvInlineField(descriptor protoreflect.MessageDescriptor, options CsvOptions) (protoreflect.FieldDescriptor, error) {
+	if options.Inline == "" {
+		return nil, nil
+	}
+
+	field := descriptor.Fields().ByName(protoreflect.Name(options.Inline))
+	if field == nil || field.IsList() || field.IsMap() || !isCsvMessage(field) {
+		return nil, fmt.Errorf("inlined field %s must be a message field of %s", options.Inline, descriptor.FullName())
+	}
+	return field, nil
+}
+
+// csvColumns returns the columns of the fields of descriptor, with as many columns for a
+// repeated field as the longest list in messages. inline is the field whose name is left
+// out of the headers, it is only looked for in the fields of the messages of the rows.
+func csvColumns(
+	descriptor protoreflect.MessageDescriptor,
+	messages []protoreflect.Message,
+	prefix csvColumn,
+	inline protoreflect.FieldDescriptor,
+	parents map[protoreflect.FullName]bool,
+) ([]csvColumn, error) {
+	parents[descriptor.FullName()] = true
+	defer delete(parents, descriptor.FullName())
+
+	columns := []csvColumn{}
+	fields := descriptor.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field.IsMap() {
+			return nil, fmt.Errorf("map field %s cannot be written to CSV", field.FullName())
+		}
+
+		if !field.IsList() {
+			step := csvStep{field: field, index: -1, inline: len(prefix) == 0 && field == inline}
+			column := append(append(csvColumn{}, prefix...), step)
+			if !isCsvMessage(field) {
+				columns = append(columns, column)
+				continue
+			}
+
+			children := []protoreflect.Message{}
+			for _, message := range messages {
+				if message.Has(field) {
+					children = append(children, message.Get(field).Message())
+				}
+			}
+			if parents[field.Message().FullName()] && len(children) == 0 {
+				continue // message ที่อ้างถึงตัวเองจะมีคอลัมน์เฉพาะเมื่อมีข้อมูล
+			}
+			nested, err := csvColumns(field.Message(), children, column, inline, parents)
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, nested...)
+			continue
+		}
+
+		count := 0
+		for _, message := range messages {
+			count = max(count, message.Get(field).List().Len())
+		}
+		for index := 0; index < count; index++ {
+			column := append(append(csvColumn{}, prefix...), csvStep{field: field, index: index})
+			if !isCsvMessage(field) {
+				columns = append(columns, column)
+				continue
+			}
+
+			elements := []protoreflect.Message{}
+			for _, message := range messages {
+				list := message.Get(field).List()
+				if index < list.Len() {
+					elements = append(elements, list.Get(index).Message())
+				}
+			}
+			nested, err := csvColumns(field.Message(), elements, column, inline, parents)
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, nested...)
+		}
+	}
+	return columns, nil
+}
+
+// isCsvMessage reports whether a field is a message flattened into several columns
+func isCsvMessage(field protoreflect.FieldDescriptor) bool {
+	return field.Message() != nil && !isTimestamp(field)
+}
+
+func isTimestamp(field protoreflect.FieldDescriptor) bool {
+	return field.Message() != nil && field.Message().FullName() == "google.protobuf.Timestamp"
+}
+
+// csvCell returns the cell of a column in the row of message, empty if the field is not set
+func csvCell(message protoreflect.Message, column csvColumn) string {
+	for i, step := range column {
+		field := step.field
+		if step.index < 0 && field.HasPresence() && !message.Has(field) {
+			return ""
+		}
+
+		value := message.Get(field)
+		if step.index >= 0 {
+			list := value.List()
+			if step.index >= list.Len() {
+				return ""
+			}
+			value = list.Get(step.index)
+		}
+
+		if i < len(column)-1 {
+			message = value.Message()
+			continue
+		}
+		return formatCsvValue(field, value)
+	}
+	return ""
+}
+
+// formatCsvValue formats the value of a field that has its own column
+func formatCsvValue(field protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(value.Bool())
+	case protoreflect.EnumKind:
+		enumValue := field.Enum().Values().ByNumber(value.Enum())
+		if enumValue == nil {
+			return strconv.Itoa(int(value.Enum()))
+		}
+		return string(enumValue.Name())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(value.Int(), 10)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(value.Uint(), 10)
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(value.Float(), 'g', -1, 32)
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(value.Float(), 'g', -1, 64)
+	case protoreflect.StringKind:
+		return value.String()
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(value.Bytes())
+	default: // Timestamp
+		timestamp := value.Message().Interface().(*timestamppb.Timestamp)
+		return timestamp.AsTime().Format(time.RFC3339Nano)
+	}
+}
+
+// parseCsvColumn resolves a header like storages[1].memory.value to the path of its field.
+// A header that is not a column of descriptor is looked for in the message of inline.
+func parseCsvColumn(descriptor protoreflect.MessageDescriptor, header string, inline protoreflect.FieldDescriptor) (csvColumn, error) {
+	column, err := parseCsvPath(descriptor, header)
+	if err != nil && inline != nil {
+		inlined, inlineErr := parseCsvPath(inline.Message(), header)
+		if inlineErr == nil {
+			return append(csvColumn{{field: inline, index: -1, inline: true}}, inlined...), nil
+		}
+	}
+	return column, err
+}
+
+// parseCsvPath resolves a header to the path of its field in descriptor
+func parseCsvPath(descriptor protoreflect.MessageDescriptor, header string) (csvColumn, error) {
+	column := csvColumn{}
+	for i, name := range strings.Split(header, ".") {
+		if descriptor == nil {
+			return nil, fmt.Errorf("unknown column %q, %s has no fields", header, column[i-1].field.Name())
+		}
+
+		index := -1
+		if open := strings.IndexByte(name, '['); open >= 0 && strings.HasSuffix(name, "]") {
+			var err error
+			index, err = strconv.Atoi(name[open+1 : len(name)-1])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("unknown column %q, index must be a number from 0", header)
+			}
+			name = name[:open]
+		}
+
+		field := descriptor.Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			return nil, fmt.Errorf("unknown column %q", header)
+		}
+		if field.IsMap() {
+			return nil, fmt.Errorf("unknown column %q, map fields are not supported", header)
+		}
+		if field.IsList() != (index >= 0) {
+			if field.IsList() {
+				return nil, fmt.Errorf("unknown column %q, %s needs an index like %s[0]", header, name, name)
+			}
+			return nil, fmt.Errorf("unknown column %q, %s is not repeated", header, name)
+		}
+
+		column = append(column, csvStep{field: field, index: index})
+		descriptor = nil
+		if isCsvMessage(field) {
+			descriptor = field.Message()
+		}
+	}
+
+	if descriptor != nil {
+		return nil, fmt.Errorf("unknown column %q, %s is a message", header, column[len(column)-1].field.Name())
+	}
+	return column, nil
+}
+
+// csvList keeps the elements of a repeated field read from a row until all its cells are read,
+// so that the elements are added in the order of their index
+type csvList struct {
+	list     protoreflect.List
+	elements map[int]protoreflect.Value
+}
+
+func (list *csvList) appendElements() {
+	indexes := make([]int, 0, len(list.elements))
+	for index := range list.elements {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		list.list.Append(list.elements[index])
+	}
+}
+
+// setCsvCell sets the field of a column from a cell that is not empty
+func setCsvCell(message protoreflect.Message, column csvColumn, cell string, lists map[string]*csvList) error {
+	for i, step := range column {
+		field := step.field
+		last := i == len(column)-1
+
+		if oneof := field.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			if set := message.WhichOneof(oneof); set != nil && set != field {
+				return fmt.Errorf("only one of %s can be set", oneofFieldNames(oneof))
+			}
+		}
+
+		if step.index < 0 {
+			if !last {
+				message = message.Mutable(field).Message()
+				continue
+			}
+			value, err := parseCsvValue(field, cell)
+			if err != nil {
+				return err
+			}
+			message.Set(field, value)
+			return nil
+		}
+
+		key := column[:i+1].String()
+		key = key[:strings.LastIndexByte(key, '[')]
+		list := lists[key]
+		if list == nil {
+			list = &csvList{list: message.Mutable(field).List(), elements: map[int]protoreflect.Value{}}
+			lists[key] = list
+		}
+
+		if !last {
+			element, ok := list.elements[step.index]
+			if !ok {
+				element = list.list.NewElement()
+				list.elements[step.index] = element
+			}
+			message = element.Message()
+			continue
+		}
+		value, err := parseCsvValue(field, cell)
+		if err != nil {
+			return err
+		}
+		list.elements[step.index] = value
+	}
+	return nil
+}
+
+// oneofFieldNames returns the names of the fields of a oneof, like "weight_kg or weight_lb"
+func oneofFieldNames(oneof protoreflect.OneofDescriptor) string {
+	names := make([]string, oneof.Fields().Len())
+	for i := range names {
+		names[i] = string(oneof.Fields().Get(i).Name())
+	}
+	return strings.Join(names, " or ")
+}
+
+// parseCsvValue parses the cell of a field that has its own column
+func parseCsvValue(field protoreflect.FieldDescriptor, cell string) (protoreflect.Value, error) {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		value, err := strconv.ParseBool(cell)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%q is not true or false", cell)
+		}
+		return protoreflect.ValueOfBool(value), nil
+
+	case protoreflect.EnumKind:
+		enumValue := field.Enum().Values().ByName(protoreflect.Name(cell))
+		if enumValue != nil {
+			return protoreflect.ValueOfEnum(enumValue.Number()), nil
+		}
+		number, err := strconv.ParseInt(cell, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("unknown value %q of %s", cell, field.Enum().Name())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(number)), nil
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		value, err := strconv.ParseInt(cell, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%q is not a 32-bit integer", cell)
+		}
+		return protoreflect.ValueOfInt32(int32(value)), nil
+
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		value, err := strconv.ParseInt(cell, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%q is not a 64-bit integer", cell)
+		}
+		return protoreflect.ValueOfInt64(value), nil
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		value, err := strconv.ParseUint(cell, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%q is not a 32-bit unsigned integer", cell)
+		}
+		return protoreflect.ValueOfUint32(uint32(value)), nil
+
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		value, err := strconv.ParseUint(cell, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%q is not a 64-bit unsigned integer", cell)
+		}
+		return protoreflect.ValueOfUint64(value), nil
+
+	case protoreflect.FloatKind:
+		value, err := strconv.ParseFloat(cell, 32)
+		if err != nil && !errors.Is(err, strconv.ErrRange) { // ตัวเลขที่ใหญ่เกินไปจะเป็น infinity
+			return protoreflect.Value{}, fmt.Errorf("%q is not a number", cell)
+		}
+		return protoreflect.ValueOfFloat32(float32(value)), nil
+
+	case protoreflect.DoubleKind:
+		value, err := strconv.ParseFloat(cell, 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) { // ตัวเลขที่ใหญ่เกินไปจะเป็น infinity
+			return protoreflect.Value{}, fmt.Errorf("%q is not a number", cell)
+		}
+		return protoreflect.ValueOfFloat64(value), nil
+
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(cell), nil
+
+	case protoreflect.BytesKind:
+		value, err := base64.StdEncoding.DecodeString(cell)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%q is not base64", cell)
+		}
+		return protoreflect.ValueOfBytes(value), nil
+
+	default: // Timestamp
+		value, err := time.Parse(time.RFC3339Nano, cell)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%q is not a time like 2024-01-02T03:04:05Z", cell)
+		}
+		return protoreflect.ValueOfMessage(timestamppb.New(value).ProtoReflect()), nil
+	}
+}
@@ -0,0 +1,128 @@
+package serializer_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/serializer"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCsvRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	withGPU := sample.NewLaptop()
+	withGPU.Gpus = []*pb.GPU{sample.NewGPU()}
+	withoutGPU := sample.NewLaptop()
+	withoutGPU.Gpus = nil
+	withThreeStorages := sample.NewLaptop()
+	withThreeStorages.Storages = []*pb.Storage{sample.NewSSD(), sample.NewSSD(), sample.NewHDD()}
+	inPounds := sample.NewLaptop()
+	inPounds.Weight = &pb.Laptop_WeightLb{WeightLb: 3.5}
+	minimal := &pb.Laptop{Brand: "Apple", Name: "Macbook, \"Air\""}
+	laptops := []*pb.Laptop{withGPU, withoutGPU, withThreeStorages, inPounds, minimal}
+
+	var buffer bytes.Buffer
+	require.NoError(t, serializer.ProtobufToCsv(&buffer, laptops, serializer.CsvOptions{}))
+
+	header := strings.SplitN(buffer.String(), "\n", 2)[0]
+	for _, column := range []string{"cpu.number_cores", "ram.value", "ram.unit", "gpus[0].memory.value", "storages[2].driver", "screen.resolution.width", "weight_kg", "weight_lb", "updated_at"} {
+		require.Contains(t, strings.Split(header, ","), column)
+	}
+	require.NotContains(t, header, "storages[3]")
+
+	read, err := serializer.CsvToProtobuf[*pb.Laptop](&buffer, serializer.CsvOptions{})
+	require.NoError(t, err)
+	require.Len(t, read, len(laptops))
+	for i, laptop := range laptops {
+		require.True(t, proto.Equal(laptop, read[i]), "%v != %v", laptop, read[i])
+	}
+}
+
+func TestCsvSpreadsheet(t *testing.T) {
+	t.Parallel()
+
+	// columns can be in any order, and an element with only empty cells is left out
+	input := "storages[1].driver,brand,storages[1].memory.value,storages[0].driver,weight_lb,keyboard.layout,gpus[0].name\n" +
+		"HDD,Dell,2,,4.4,QWERTZ,\n"
+	laptops, err := serializer.CsvToProtobuf[*pb.Laptop](strings.NewReader(input), serializer.CsvOptions{})
+	require.NoError(t, err)
+	require.Len(t, laptops, 1)
+	require.Equal(t, "Dell", laptops[0].GetBrand())
+	require.Len(t, laptops[0].GetStorages(), 1)
+	require.Equal(t, pb.Storage_HDD, laptops[0].GetStorages()[0].GetDriver())
+	require.Equal(t, uint64(2), laptops[0].GetStorages()[0].GetMemory().GetValue())
+	require.Equal(t, 4.4, laptops[0].GetWeightLb())
+	require.Equal(t, pb.Keyboard_QWERTZ, laptops[0].GetKeyboard().GetLayout())
+	require.Empty(t, laptops[0].GetGpus())
+	require.Nil(t, laptops[0].GetCpu())
+}
+
+func TestCsvErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		input string
+		err   string
+	}{
+		{"", "row 1: header is missing"},
+		{"brand,color\n", `row 1, column 2 (color): unknown column "color"`},
+		{"brand,gpus.name\n", `row 1, column 2 (gpus.name): unknown column "gpus.name", gpus needs an index like gpus[0]`},
+		{"cpu\n", `row 1, column 1 (cpu): unknown column "cpu", cpu is a message`},
+		{"brand,ram.unit\nApple,GIGABYTE\nDell,PETABYTE\n", `row 3, column 2 (ram.unit): unknown value "PETABYTE" of Unit`},
+		{"brand,release_year\nApple,last year\n", `row 2, column 2 (release_year): "last year" is not a 32-bit unsigned integer`},
+		{"weight_kg,weight_lb\n1,2\n", "row 2, column 2 (weight_lb): only one of weight_kg or weight_lb can be set"},
+		{"brand,name\nApple,Air,extra\n", "row 2: "},
+	}
+	for _, tc := range testCases {
+		_, err := serializer.CsvToProtobuf[*pb.Laptop](strings.NewReader(tc.input), serializer.CsvOptions{})
+		require.ErrorContains(t, err, tc.err, "%q", tc.input)
+
+		var csvErr *serializer.CsvError
+		require.ErrorAs(t, err, &csvErr)
+	}
+}
+
+func TestCsvInline(t *testing.T) {
+	t.Parallel()
+
+	entries := []*pb.CatalogEntry{
+		{Laptop: sample.NewLaptop(), Ratings: []*pb.Review{{Username: "user1", Score: 8}, {Username: "user2", Score: 6.5}}},
+		{Laptop: sample.NewLaptop()},
+	}
+	options := serializer.CsvOptions{Inline: "laptop"}
+
+	var buffer bytes.Buffer
+	require.NoError(t, serializer.ProtobufToCsv(&buffer, entries, options))
+
+	header := strings.Split(strings.SplitN(buffer.String(), "\n", 2)[0], ",")
+	for _, column := range []string{"brand", "cpu.name", "storages[0].driver", "ratings[1].score"} {
+		require.Contains(t, header, column)
+	}
+	require.NotContains(t, strings.Join(header, ","), "laptop.")
+
+	read, err := serializer.CsvToProtobuf[*pb.CatalogEntry](&buffer, options)
+	require.NoError(t, err)
+	require.Len(t, read, len(entries))
+	for i, entry := range entries {
+		require.True(t, proto.Equal(entry, read[i]), "%v != %v", entry, read[i])
+	}
+
+	// a CSV of laptops is read as entries without ratings
+	laptop := sample.NewLaptop()
+	buffer.Reset()
+	require.NoError(t, serializer.ProtobufToCsv(&buffer, []*pb.Laptop{laptop}, serializer.CsvOptions{}))
+	read, err = serializer.CsvToProtobuf[*pb.CatalogEntry](&buffer, options)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(&pb.CatalogEntry{Laptop: laptop}, read[0]))
+
+	for _, inline := range []string{"ratings", "color"} {
+		err := serializer.ProtobufToCsv(&buffer, entries, serializer.CsvOptions{Inline: inline})
+		require.ErrorContains(t, err, "must be a message field", inline)
+	}
+}
@@ -0,0 +1,315 @@
+package serializer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// Compression is the compression of a stream of delimited messages
+type Compression int
+
+const (
+	// NoCompression writes the messages as they are
+	NoCompression Compression = iota
+	// Gzip compresses the stream with gzip
+	Gzip
+	// Zstd compresses the stream with zstandard
+	Zstd
+)
+
+// DefaultMaxMessageSize is the maximum size of a message read by a DelimitedReader,
+// the same as the default of protodelim
+const DefaultMaxMessageSize = 4 << 20
+
+// checksumMarker starts the checksum trailer. It is the varint 0 encoded with two bytes,
+// which never starts a message since message lengths are always encoded with the fewest bytes.
+var checksumMarker = []byte{0x80, 0x00}
+
+// ErrChecksumMismatch is returned when the checksum trailer does not match the messages of a stream
+var ErrChecksumMismatch = errors.New("checksum does not match the messages")
+
+// ErrChecksumMissing is returned when a stream that must have a checksum trailer ends without one,
+// which usually means that it is truncated
+var ErrChecksumMissing = errors.New("checksum trailer is missing")
+
+// DelimitedOptions configures a DelimitedWriter or a DelimitedReader
+type DelimitedOptions struct {
+	Compression Compression
+	// Checksum makes the writer end the stream with a CRC-32C of the messages, and the
+	// reader fail if the stream has no such trailer. A reader always checks a trailer it finds.
+	Checksum bool
+	// MaxMessageSize is the maximum size of a message read, DefaultMaxMessageSize if 0
+	MaxMessageSize int
+}
+
+// DelimitedWriter writes a sequence of messages, each prefixed with its size as a varint.
+// Without compression and checksum, the output can be read with protodelim.UnmarshalFrom.
+type DelimitedWriter struct {
+	options    DelimitedOptions
+	compressor io.WriteCloser // nil without compression
+	writer     *bufio.Writer
+	checksum   hash.Hash32
+	buffer     []byte
+	count      int
+	closed     bool
+}
+
+// NewDelimitedWriter returns a writer of delimited messages to w. Close must be called to write
+// the end of the stream, it does not close w.
+func NewDelimitedWriter(w io.Writer, options DelimitedOptions) (*DelimitedWriter, error) {
+	writer := &DelimitedWriter{
+		options:  options,
+		checksum: crc32.New(crc32.MakeTable(crc32.Castagnoli)),
+	}
+
+	switch options.Compression {
+	case NoCompression:
+	case Gzip:
+		writer.compressor = gzip.NewWriter(w)
+	case Zstd:
+		encoder, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create zstd encoder: %w", err)
+		}
+		writer.compressor = encoder
+	default:
+		return nil, fmt.Errorf("unknown compression %d", options.Compression)
+	}
+
+	if writer.compressor != nil {
+		w = writer.compressor
+	}
+	writer.writer = bufio.NewWriter(w)
+	return writer, nil
+}
+
+// Write writes a message
+func (writer *DelimitedWriter) Write(message proto.Message) error {
+	if writer.closed {
+		return errors.New("cannot write to a closed writer")
+	}
+
+	size := proto.Size(message)
+	buffer := protowire.AppendVarint(writer.buffer[:0], uint64(size))
+	buffer, err := proto.MarshalOptions{UseCachedSize: true}.MarshalAppend(buffer, message)
+	if err != nil {
+		return fmt.Errorf("cannot marshal message %d: %w", writer.count+1, err)
+	}
+	writer.buffer = buffer
+
+	_, err = writer.writer.Write(buffer)
+	if err != nil {
+		return fmt.Errorf("cannot write message %d: %w", writer.count+1, err)
+	}
+	writer.checksum.Write(buffer)
+	writer.count++
+	return nil
+}
+
+// Count returns the number of messages written
+func (writer *DelimitedWriter) Count() int {
+	return writer.count
+}
+
+// Close writes the checksum trailer if enabled and flushes the compressed stream
+func (writer *DelimitedWriter) Close() error {
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+
+	if writer.options.Checksum {
+		trailer := binary.BigEndian.AppendUint32(append([]byte{}, checksumMarker...), writer.checksum.Sum32())
+		_, err := writer.writer.Write(trailer)
+		if err != nil {
+			return fmt.Errorf("cannot write checksum: %w", err)
+		}
+	}
+
+	err := writer.writer.Flush()
+	if err != nil {
+		return fmt.Errorf("cannot flush messages: %w", err)
+	}
+	if writer.compressor != nil {
+		err = writer.compressor.Close()
+		if err != nil {
+			return fmt.Errorf("cannot close compressed stream: %w", err)
+		}
+	}
+	return nil
+}
+
+// DelimitedReader reads a sequence of messages written by a DelimitedWriter or protodelim.MarshalTo
+type DelimitedReader struct {
+	options      DelimitedOptions
+	decompressor io.ReadCloser // nil without compression
+	reader       *bufio.Reader
+	checksum     hash.Hash32
+	buffer       []byte
+	count        int
+	done         bool
+}
+
+// NewDelimitedReader returns a reader of delimited messages from r, compressed as in options.
+// Close releases the decompressor, it does not close r.
+func NewDelimitedReader(r io.Reader, options DelimitedOptions) (*DelimitedReader, error) {
+	if options.MaxMessageSize <= 0 {
+		options.MaxMessageSize = DefaultMaxMessageSize
+	}
+	reader := &DelimitedReader{
+		options:  options,
+		checksum: crc32.New(crc32.MakeTable(crc32.Castagnoli)),
+	}
+
+	switch options.Compression {
+	case NoCompression:
+	case Gzip:
+		decompressor, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read gzip header: %w", err)
+		}
+		reader.decompressor = decompressor
+	case Zstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create zstd decoder: %w", err)
+		}
+		reader.decompressor = decoder.IOReadCloser()
+	default:
+		return nil, fmt.Errorf("unknown compression %d", options.Compression)
+	}
+
+	if reader.decompressor != nil {
+		r = reader.decompressor
+	}
+	reader.reader = bufio.NewReader(r)
+	return reader, nil
+}
+
+// Read reads the next message into message, and returns io.EOF at the end of the stream.
+// A stream that ends in the middle of a message returns io.ErrUnexpectedEOF.
+func (reader *DelimitedReader) Read(message proto.Message) error {
+	if reader.done {
+		return io.EOF
+	}
+
+	size, err := reader.readSize()
+	if err == io.EOF {
+		reader.done = true
+		if reader.options.Checksum {
+			return fmt.Errorf("after message %d: %w", reader.count, ErrChecksumMissing)
+		}
+		return io.EOF
+	}
+	if err != nil {
+		return fmt.Errorf("message %d: %w", reader.count+1, err)
+	}
+	if size < 0 {
+		reader.done = true
+		return reader.readChecksum()
+	}
+	if size > reader.options.MaxMessageSize {
+		return fmt.Errorf("message %d: size %d exceeds the maximum %d", reader.count+1, size, reader.options.MaxMessageSize)
+	}
+
+	if cap(reader.buffer) < size {
+		reader.buffer = make([]byte, size)
+	}
+	buffer := reader.buffer[:size]
+	_, err = io.ReadFull(reader.reader, buffer)
+	if err != nil {
+		return fmt.Errorf("message %d: %w", reader.count+1, unexpectedEOF(err))
+	}
+	reader.checksum.Write(buffer)
+
+	err = proto.Unmarshal(buffer, message)
+	if err != nil {
+		return fmt.Errorf("message %d: %w", reader.count+1, err)
+	}
+	reader.count++
+	return nil
+}
+
+// Count returns the number of messages read
+func (reader *DelimitedReader) Count() int {
+	return reader.count
+}
+
+// Close releases the decompressor
+func (reader *DelimitedReader) Close() error {
+	if reader.decompressor != nil {
+		return reader.decompressor.Close()
+	}
+	return nil
+}
+
+// readSize reads the varint size of the next message, or returns -1 for the checksum marker
+func (reader *DelimitedReader) readSize() (int, error) {
+	var varint []byte
+	for {
+		b, err := reader.reader.ReadByte()
+		if err == io.EOF && len(varint) > 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		varint = append(varint, b)
+		if b < 0x80 {
+			break
+		}
+		if len(varint) == binary.MaxVarintLen64 {
+			return 0, errors.New("message size is not a valid varint")
+		}
+	}
+
+	if string(varint) == string(checksumMarker) {
+		return -1, nil
+	}
+	reader.checksum.Write(varint)
+
+	size, n := protowire.ConsumeVarint(varint)
+	if n < 0 || size > uint64(maxInt) {
+		return 0, errors.New("message size is not a valid varint")
+	}
+	return int(size), nil
+}
+
+// readChecksum checks the checksum trailer, which must end the stream
+func (reader *DelimitedReader) readChecksum() error {
+	trailer := make([]byte, 4)
+	_, err := io.ReadFull(reader.reader, trailer)
+	if err != nil {
+		return fmt.Errorf("cannot read checksum: %w", unexpectedEOF(err))
+	}
+	if binary.BigEndian.Uint32(trailer) != reader.checksum.Sum32() {
+		return fmt.Errorf("after message %d: %w", reader.count, ErrChecksumMismatch)
+	}
+
+	_, err = reader.reader.ReadByte()
+	if err != io.EOF {
+		return errors.New("data found after the checksum trailer")
+	}
+	return io.EOF
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+// unexpectedEOF turns the io.EOF of a stream that ends too early into io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package serializer_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/serializer"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDelimitedRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	laptops := []*pb.Laptop{sample.NewLaptop(), {}, sample.NewLaptop()}
+	for _, compression := range []serializer.Compression{serializer.NoCompression, serializer.Gzip, serializer.Zstd} {
+		for _, checksum := range []bool{false, true} {
+			options := serializer.DelimitedOptions{Compression: compression, Checksum: checksum}
+			data := writeDelimited(t, options, laptops)
+
+			reader, err := serializer.NewDelimitedReader(bytes.NewReader(data), options)
+			require.NoError(t, err)
+			for _, laptop := range laptops {
+				read := &pb.Laptop{}
+				require.NoError(t, reader.Read(read), "%+v", options)
+				require.True(t, proto.Equal(laptop, read), "%+v", options)
+			}
+			require.Equal(t, io.EOF, reader.Read(&pb.Laptop{}), "%+v", options)
+			require.Equal(t, len(laptops), reader.Count())
+			require.NoError(t, reader.Close())
+		}
+	}
+}
+
+func TestDelimitedProtodelimCompatible(t *testing.T) {
+	t.Parallel()
+
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop()}
+	data := writeDelimited(t, serializer.DelimitedOptions{}, laptops)
+
+	var expected bytes.Buffer
+	for _, laptop := range laptops {
+		_, err := protodelim.MarshalTo(&expected, laptop)
+		require.NoError(t, err)
+	}
+	require.Equal(t, expected.Bytes(), data)
+
+	// a reader without the checksum option also reads a stream that has one
+	data = writeDelimited(t, serializer.DelimitedOptions{Checksum: true}, laptops)
+	reader, err := serializer.NewDelimitedReader(bytes.NewReader(data), serializer.DelimitedOptions{})
+	require.NoError(t, err)
+	for range laptops {
+		require.NoError(t, reader.Read(&pb.Laptop{}))
+	}
+	require.Equal(t, io.EOF, reader.Read(&pb.Laptop{}))
+}
+
+func TestDelimitedCorrupted(t *testing.T) {
+	t.Parallel()
+
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop()}
+	options := serializer.DelimitedOptions{Checksum: true}
+	data := writeDelimited(t, options, laptops)
+
+	flipped := bytes.Clone(data)
+	flipped[len(flipped)-1] ^= 0x01
+	withoutTrailer := writeDelimited(t, serializer.DelimitedOptions{}, laptops)
+
+	testCases := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"wrong checksum", flipped, serializer.ErrChecksumMismatch},
+		{"truncated", data[:len(data)-20], io.ErrUnexpectedEOF},
+		{"truncated trailer", data[:len(data)-3], io.ErrUnexpectedEOF},
+		{"missing trailer", withoutTrailer, serializer.ErrChecksumMissing},
+	}
+	for _, tc := range testCases {
+		reader, err := serializer.NewDelimitedReader(bytes.NewReader(tc.data), options)
+		require.NoError(t, err)
+
+		for err == nil {
+			err = reader.Read(&pb.Laptop{})
+		}
+		require.ErrorIs(t, err, tc.err, tc.name)
+	}
+}
+
+func writeDelimited(t *testing.T, options serializer.DelimitedOptions, messages []*pb.Laptop) []byte {
+	var buffer bytes.Buffer
+	writer, err := serializer.NewDelimitedWriter(&buffer, options)
+	require.NoError(t, err)
+	for _, message := range messages {
+		require.NoError(t, writer.Write(message))
+	}
+	require.Equal(t, len(messages), writer.Count())
+	require.NoError(t, writer.Close())
+	return buffer.Bytes()
+}
@@ -3,6 +3,8 @@ package serializer
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"google.golang.org/protobuf/proto"
 )
@@ -43,4 +45,90 @@ func ReadProtobuffFromBinaryFile(filename string, message proto.Message) error{
 		return fmt.Errorf("cannot unmarshal binary to proto message:%w", err)
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// ReadProtobufFromJsonFile read protocal buffer message from Json file
+func ReadProtobufFromJsonFile(filename string, message proto.Message) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("cannot read JSON data from file: %w", err)
+	}
+	err = JsonToProtobuf(string(data), message)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal JSON to proto message: %w", err)
+	}
+	return nil
+}
+
+// WriteProtobufToFile write protocal buffer message to file, in the format of its extension:
+// .bin, .pb or .binpb for binary, .json for JSON, .txtpb, .textproto or .pbtxt for text
+// format and .yaml or .yml for YAML
+func WriteProtobufToFile(message proto.Message, filename string) error {
+	var marshal func(message proto.Message) (string, error)
+	switch fileFormat(filename) {
+	case "binary":
+		return WriteProtobufToBinaryFile(message, filename)
+	case "json":
+		return WriteProtobufToJsonFile(message, filename)
+	case "text":
+		marshal = ProtobufToText
+	case "yaml":
+		marshal = ProtobufToYaml
+	default:
+		return fmt.Errorf("unknown format of file %q", filename)
+	}
+
+	data, err := marshal(message)
+	if err != nil {
+		return fmt.Errorf("cannot marshal proto message to %s: %w", fileFormat(filename), err)
+	}
+	err = os.WriteFile(filename, []byte(data), 0644)
+	if err != nil {
+		return fmt.Errorf("cannot write %s data to file: %w", fileFormat(filename), err)
+	}
+	return nil
+}
+
+// ReadProtobufFromFile read protocal buffer message from file, in the format of its extension
+// as in WriteProtobufToFile
+func ReadProtobufFromFile(filename string, message proto.Message) error {
+	var unmarshal func(data string, message proto.Message) error
+	switch fileFormat(filename) {
+	case "binary":
+		return ReadProtobuffFromBinaryFile(filename, message)
+	case "json":
+		return ReadProtobufFromJsonFile(filename, message)
+	case "text":
+		unmarshal = TextToProtobuf
+	case "yaml":
+		unmarshal = YamlToProtobuf
+	default:
+		return fmt.Errorf("unknown format of file %q", filename)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("cannot read %s data from file: %w", fileFormat(filename), err)
+	}
+	err = unmarshal(string(data), message)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal %s to proto message: %w", fileFormat(filename), err)
+	}
+	return nil
+}
+
+// fileFormat returns the format of a file from its extension, or "" if it is unknown
+func fileFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".bin", ".pb", ".binpb":
+		return "binary"
+	case ".json":
+		return "json"
+	case ".txtpb", ".textproto", ".pbtxt":
+		return "text"
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return ""
+	}
+}
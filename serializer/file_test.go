@@ -1,6 +1,7 @@
 package serializer_test
 
 import (
+	"path/filepath"
 	"grpc-project/sample"
 	"grpc-project/serializer"
 	"testing"
@@ -33,4 +34,64 @@ func TestFileSerializer(t *testing.T) {
 	// เขียน laptop1 ลงไฟล์ JSON
 	err = serializer.WriteProtobufToJsonFile(laptop1, jsonFile)
 	require.NoError(t, err) // ตรวจสอบว่าไม่มีข้อผิดพลาด
+
+	// อ่านไฟล์ JSON กลับมาและตรวจสอบว่าเท่ากับ laptop1
+	laptop3 := &pb.Laptop{}
+	err = serializer.ReadProtobufFromJsonFile(jsonFile, laptop3)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop1, laptop3))
+}
+
+func TestFileFormats(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, extension := range []string{".bin", ".pb", ".json", ".txtpb", ".textproto", ".yaml", ".yml"} {
+		laptop1 := sample.NewLaptop()
+		filename := filepath.Join(dir, "laptop"+extension)
+
+		err := serializer.WriteProtobufToFile(laptop1, filename)
+		require.NoError(t, err, extension)
+
+		laptop2 := &pb.Laptop{}
+		err = serializer.ReadProtobufFromFile(filename, laptop2)
+		require.NoError(t, err, extension)
+		require.True(t, proto.Equal(laptop1, laptop2), "%s: %v != %v", extension, laptop1, laptop2)
+	}
+
+	err := serializer.WriteProtobufToFile(sample.NewLaptop(), filepath.Join(dir, "laptop.xml"))
+	require.ErrorContains(t, err, "unknown format")
+}
+
+func TestYamlFixture(t *testing.T) {
+	t.Parallel()
+
+	// fixture ที่เขียนด้วยมือ ใช้ตัวเลขและชื่อ enum ได้โดยไม่ต้องใส่เครื่องหมายคำพูด
+	fixture := `
+brand: Apple
+name: "Macbook Air"
+ram:
+  value: 16
+  unit: GIGABYTE
+storages:
+  - driver: SSD
+    memory: {value: 512, unit: GIGABYTE}
+weight_kg: 1.29
+price_usd: 1199
+release_year: 2024
+updated_at: 2024-01-02T03:04:05Z
+`
+	laptop := &pb.Laptop{}
+	require.NoError(t, serializer.YamlToProtobuf(fixture, laptop))
+	require.Equal(t, uint64(16), laptop.GetRam().GetValue())
+	require.Equal(t, pb.Storage_SSD, laptop.GetStorages()[0].GetDriver())
+	require.Equal(t, 1.29, laptop.GetWeightKg())
+	require.Equal(t, int64(1704164645), laptop.GetUpdatedAt().GetSeconds())
+
+	text, err := serializer.ProtobufToYaml(laptop)
+	require.NoError(t, err)
+	require.Contains(t, text, "unit: GIGABYTE")
+
+	err = serializer.YamlToProtobuf("brand: [Apple", laptop)
+	require.Error(t, err)
 }
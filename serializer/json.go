@@ -1,35 +1,106 @@
 package serializer
 
 import (
-    "github.com/golang/protobuf/jsonpb"   // นำเข้าpackage jsonpb สำหรับการแปลง Protocol Buffers เป็น JSON
-    "google.golang.org/protobuf/proto"    // นำเข้าpackage proto สำหรับการทำงานกับ Protocol Buffers
-    "google.golang.org/protobuf/types/known/anypb" // นำเข้าพackage anypb สำหรับการแปลงเป็นประเภทที่รองรับ jsonpb
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
-// ProtobufToJson converts protocol buffer message to JSON string
+// JsonMarshalOptions configures how a protocol buffer message is converted to JSON
+type JsonMarshalOptions struct {
+	EnumsAsInts  bool   // แสดง enum เป็นตัวเลขแทนชื่อของ enum
+	EmitDefaults bool   // แสดงฟิลด์ที่ไม่ได้ถูกตั้งค่าด้วยค่า default
+	OrigName     bool   // ใช้ชื่อฟิลด์ตามที่กำหนดใน proto file แทน lowerCamelCase
+	Indent       string // จัดรูปแบบ JSON หลายบรรทัดด้วย indent นี้ ถ้าว่างจะเป็น JSON บรรทัดเดียว
+	// AnyWrapped wraps the message in an anypb.Any with an "@type" field,
+	// the format written by older versions of this package
+	AnyWrapped bool
+}
+
+// JsonUnmarshalOptions configures how JSON is converted to a protocol buffer message
+type JsonUnmarshalOptions struct {
+	// AllowAnyWrapped also accepts JSON written with AnyWrapped, the message inside
+	// the "@type" envelope must have the type of the message being read
+	AllowAnyWrapped bool
+	DiscardUnknown  bool // ข้ามฟิลด์ที่ไม่รู้จักแทนการคืนค่า error
+}
+
+// DefaultJsonMarshalOptions is used by ProtobufToJson and WriteProtobufToJsonFile
+var DefaultJsonMarshalOptions = JsonMarshalOptions{
+	EmitDefaults: true,
+	OrigName:     true,
+	Indent:       "  ",
+}
+
+// DefaultJsonUnmarshalOptions is used by JsonToProtobuf and ReadProtobufFromJsonFile
+var DefaultJsonUnmarshalOptions = JsonUnmarshalOptions{
+	AllowAnyWrapped: true,
+}
+
+// ProtobufToJson converts protocol buffer message to JSON string with DefaultJsonMarshalOptions
 func ProtobufToJson(message proto.Message) (string, error) {
-    // แปลง proto.Message เป็นประเภท anypb.Any ซึ่งเป็นประเภทที่รองรับการใช้กับ jsonpb
-    anyMessage, err := anypb.New(message)
-    if err != nil {
-        // ถ้ามีข้อผิดพลาดในการแปลงเป็น anypb.Any คืนค่า "" และ error
-        return "", err
-    }
-
-    // สร้าง Marshaler ของ jsonpb สำหรับการแปลงเป็น JSON
-    marshaler := jsonpb.Marshaler{
-        EnumsAsInts:  true,   // แปลง enums เป็นตัวเลข (integer) แทนการแสดงชื่อของ enum
-        EmitDefaults: true,  // แสดงค่าที่เป็น default ของฟิลด์ที่ไม่ได้ถูกตั้งค่า
-        Indent:      " ",    // จัดรูปแบบ JSON ให้อ่านง่ายด้วยการเว้นวรรค
-        OrigName:    true,   // ใช้ชื่อฟิลด์ตามที่กำหนดใน proto file แทนการแปลงเป็น camelCase
-    }
-    
-    // แปลง anypb.Any เป็น JSON string โดยใช้ marshaler
-    jsonData, err := marshaler.MarshalToString(anyMessage)
-    if err != nil {
-        // ถ้ามีข้อผิดพลาดในการแปลงเป็น JSON คืนค่า "" และ error
-        return "", err
-    }
-    
-    // คืนค่า JSON string ที่แปลงได้
-    return jsonData, nil
+	return ProtobufToJsonWithOptions(message, DefaultJsonMarshalOptions)
+}
+
+// ProtobufToJsonWithOptions converts protocol buffer message to JSON string
+func ProtobufToJsonWithOptions(message proto.Message, options JsonMarshalOptions) (string, error) {
+	if options.AnyWrapped {
+		anyMessage, err := anypb.New(message)
+		if err != nil {
+			return "", err
+		}
+		message = anyMessage
+	}
+
+	marshaler := protojson.MarshalOptions{
+		Multiline:       options.Indent != "",
+		Indent:          options.Indent,
+		UseEnumNumbers:  options.EnumsAsInts,
+		EmitUnpopulated: options.EmitDefaults,
+		UseProtoNames:   options.OrigName,
+	}
+	data, err := marshaler.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// JsonToProtobuf converts JSON string to protocol buffer message with DefaultJsonUnmarshalOptions
+func JsonToProtobuf(data string, message proto.Message) error {
+	return JsonToProtobufWithOptions(data, message, DefaultJsonUnmarshalOptions)
+}
+
+// JsonToProtobufWithOptions converts JSON string to protocol buffer message.
+// Enums are accepted as names or numbers, and fields by their proto or JSON names.
+func JsonToProtobufWithOptions(data string, message proto.Message, options JsonUnmarshalOptions) error {
+	unmarshaler := protojson.UnmarshalOptions{DiscardUnknown: options.DiscardUnknown}
+
+	// an Any target reads the envelope as it is
+	_, isAny := message.(*anypb.Any)
+	if options.AllowAnyWrapped && !isAny && isAnyWrapped(data) {
+		anyMessage := &anypb.Any{}
+		err := unmarshaler.Unmarshal([]byte(data), anyMessage)
+		if err != nil {
+			return err
+		}
+		if !anyMessage.MessageIs(message) {
+			return fmt.Errorf("JSON contains %s, not %s", anyMessage.MessageName(), message.ProtoReflect().Descriptor().FullName())
+		}
+		return anyMessage.UnmarshalTo(message)
+	}
+
+	return unmarshaler.Unmarshal([]byte(data), message)
+}
+
+// isAnyWrapped reports whether data is a JSON object with an "@type" field
+func isAnyWrapped(data string) bool {
+	var envelope struct {
+		Type string `json:"@type"`
+	}
+	err := json.Unmarshal([]byte(data), &envelope)
+	return err == nil && envelope.Type != ""
 }
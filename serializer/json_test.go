@@ -0,0 +1,97 @@
+package serializer_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/serializer"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestJsonOptions(t *testing.T) {
+	t.Parallel()
+
+	memory := &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}
+	testCases := []struct {
+		options  serializer.JsonMarshalOptions
+		contains []string
+	}{
+		{serializer.JsonMarshalOptions{}, []string{`"unit":"GIGABYTE"`}},
+		{serializer.JsonMarshalOptions{EnumsAsInts: true}, []string{`"unit":5`}},
+		{serializer.JsonMarshalOptions{AnyWrapped: true}, []string{`"@type":"type.googleapis.com/techshcool.pcbook.Memory"`}},
+	}
+	for _, tc := range testCases {
+		data, err := serializer.ProtobufToJsonWithOptions(memory, tc.options)
+		require.NoError(t, err)
+		for _, expected := range tc.contains {
+			require.Contains(t, compact(data), expected, "%+v", tc.options)
+		}
+	}
+
+	laptop := sample.NewLaptop()
+	laptop.Ram = &pb.Memory{Value: 16} // unit เป็นค่า default
+	data, err := serializer.ProtobufToJsonWithOptions(laptop, serializer.JsonMarshalOptions{EmitDefaults: true, OrigName: true})
+	require.NoError(t, err)
+	require.Contains(t, compact(data), `"ram":{"value":"16","unit":"UNKNOWN"}`)
+	require.Contains(t, compact(data), `"updated_at":`)
+}
+
+func TestJsonRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	for _, options := range []serializer.JsonMarshalOptions{
+		serializer.DefaultJsonMarshalOptions,
+		{},
+		{EnumsAsInts: true, EmitDefaults: true, OrigName: true, Indent: " ", AnyWrapped: true},
+	} {
+		data, err := serializer.ProtobufToJsonWithOptions(laptop, options)
+		require.NoError(t, err)
+
+		read := &pb.Laptop{}
+		require.NoError(t, serializer.JsonToProtobuf(data, read), "%+v", options)
+		require.True(t, proto.Equal(laptop, read), "%+v", options)
+	}
+}
+
+func TestJsonAnyWrapped(t *testing.T) {
+	t.Parallel()
+
+	// the format of ProtobufToJson before it moved to protojson
+	legacy := `{
+ "@type": "type.googleapis.com/techshcool.pcbook.Memory",
+ "value": "8",
+ "unit": 5
+}`
+	memory := &pb.Memory{}
+	require.NoError(t, serializer.JsonToProtobuf(legacy, memory))
+	require.True(t, proto.Equal(&pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}, memory))
+
+	err := serializer.JsonToProtobufWithOptions(legacy, &pb.Memory{}, serializer.JsonUnmarshalOptions{})
+	require.Error(t, err)
+
+	err = serializer.JsonToProtobuf(legacy, &pb.CPU{})
+	require.ErrorContains(t, err, "JSON contains techshcool.pcbook.Memory, not techshcool.pcbook.CPU")
+
+	anyMessage := &anypb.Any{}
+	require.NoError(t, serializer.JsonToProtobuf(legacy, anyMessage))
+	memory = &pb.Memory{}
+	require.NoError(t, anyMessage.UnmarshalTo(memory))
+	require.True(t, proto.Equal(&pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}, memory))
+}
+
+// compact removes the whitespace that protojson adds at random
+func compact(data string) string {
+	var buffer bytes.Buffer
+	err := json.Compact(&buffer, []byte(data))
+	if err != nil {
+		return data
+	}
+	return buffer.String()
+}
@@ -0,0 +1,24 @@
+package serializer
+
+import (
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtobufToText converts protocol buffer message to the protobuf text format
+func ProtobufToText(message proto.Message) (string, error) {
+	marshaler := prototext.MarshalOptions{
+		Multiline: true, // หนึ่งฟิลด์ต่อหนึ่งบรรทัด เพื่อให้แก้ไขด้วยมือได้ง่าย
+		Indent:    "  ",
+	}
+	data, err := marshaler.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// TextToProtobuf converts the protobuf text format to protocol buffer message
+func TextToProtobuf(data string, message proto.Message) error {
+	return prototext.Unmarshal([]byte(data), message)
+}
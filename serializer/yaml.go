@@ -0,0 +1,154 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlJsonOptions is how messages are converted to JSON before they are written as YAML
+var yamlJsonOptions = JsonMarshalOptions{OrigName: true}
+
+// ProtobufToYaml converts protocol buffer message to YAML, with the same field names and
+// values as its JSON form
+func ProtobufToYaml(message proto.Message) (string, error) {
+	data, err := ProtobufToJsonWithOptions(message, yamlJsonOptions)
+	if err != nil {
+		return "", err
+	}
+
+	// JSON เป็น YAML อยู่แล้ว จึงอ่านเป็น node ได้โดยไม่เปลี่ยนลำดับของฟิลด์
+	node := &yaml.Node{}
+	err = yaml.Unmarshal([]byte(data), node)
+	if err != nil {
+		return "", err
+	}
+	blockStyle(node)
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	err = encoder.Encode(node)
+	if err != nil {
+		return "", err
+	}
+	err = encoder.Close()
+	if err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// YamlToProtobuf converts YAML to protocol buffer message, the YAML must have the shape of
+// the JSON form of the message
+func YamlToProtobuf(data string, message proto.Message) error {
+	node := &yaml.Node{}
+	err := yaml.Unmarshal([]byte(data), node)
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	if node.Kind == 0 {
+		buffer.WriteString("{}") // เอกสารว่าง
+	} else {
+		err = writeYamlAsJson(&buffer, node)
+		if err != nil {
+			return err
+		}
+	}
+	return JsonToProtobufWithOptions(buffer.String(), message, JsonUnmarshalOptions{})
+}
+
+// blockStyle removes the JSON flow style and quotes so that the node is written as block YAML.
+// The encoder still quotes the strings that would otherwise be read as another type.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// writeYamlAsJson writes a YAML node as JSON
+func writeYamlAsJson(buffer *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		return writeYamlAsJson(buffer, node.Content[0])
+
+	case yaml.AliasNode:
+		return writeYamlAsJson(buffer, node.Alias)
+
+	case yaml.MappingNode:
+		buffer.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			key, _ := json.Marshal(node.Content[i].Value)
+			buffer.Write(key)
+			buffer.WriteByte(':')
+			err := writeYamlAsJson(buffer, node.Content[i+1])
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+		return nil
+
+	case yaml.SequenceNode:
+		buffer.WriteByte('[')
+		for i, child := range node.Content {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			err := writeYamlAsJson(buffer, child)
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+		return nil
+
+	case yaml.ScalarNode:
+		var value interface{}
+		switch node.ShortTag() {
+		case "!!null":
+			value = nil
+		case "!!bool", "!!int", "!!float":
+			err := node.Decode(&value)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", node.Line, err)
+			}
+			if f, ok := value.(float64); ok && (math.IsInf(f, 0) || math.IsNaN(f)) {
+				value = jsonFloat(f) // protojson เขียนค่าพิเศษเป็น string
+			}
+		default:
+			value = node.Value // รวมถึง !!str, !!timestamp และ !!binary
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		buffer.Write(data)
+		return nil
+
+	default:
+		return fmt.Errorf("line %d: unsupported YAML node", node.Line)
+	}
+}
+
+// jsonFloat returns how protojson writes an infinite or NaN float
+func jsonFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case f > 0:
+		return "Infinity"
+	default:
+		return "-Infinity"
+	}
+}
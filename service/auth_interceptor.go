@@ -2,12 +2,12 @@ package service
 
 import (
 	"context"
-	"log"
+
+	"grpc-project/apperror"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
 )
 
 // AuthInterceptor is a server interceptor for authentication and authorization
@@ -29,9 +29,7 @@ func (interceptor *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		log.Println("--> unary interceptor: ", info.FullMethod)
-
-		err := interceptor.authorize(ctx, info.FullMethod)
+		ctx, err := interceptor.authorize(ctx, info.FullMethod)
 		if err != nil {
 			return nil, err
 		}
@@ -48,45 +46,86 @@ func (interceptor *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
 		info *grpc.StreamServerInfo,
 		handler grpc.StreamHandler,
 	) error {
-		log.Println("--> stream interceptor: ", info.FullMethod)
-
-		err := interceptor.authorize(stream.Context(), info.FullMethod)
+		ctx, err := interceptor.authorize(stream.Context(), info.FullMethod)
 		if err != nil {
 			return err
 		}
 
-		return handler(srv, stream)
+		return handler(srv, &serverStreamWithContext{stream, ctx})
 	}
 }
 
-func (interceptor *AuthInterceptor) authorize(ctx context.Context, method string) error {
+// authorize checks the access token of the request and returns a context that carries the user claims
+func (interceptor *AuthInterceptor) authorize(ctx context.Context, method string) (context.Context, error) {
 	accessibleRoles, ok := interceptor.accessibleRoles[method]
 	if !ok {
-		// everyone can access
-		return nil
+		// everyone can access, the claims of a valid token are still attached
+		// so that later interceptors such as the rate limiter know the user
+		return interceptor.attachOptionalClaims(ctx), nil
 	}
 
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return status.Errorf(codes.Unauthenticated, "metadata is not provided")
+		return nil, apperror.New(codes.Unauthenticated, apperror.ReasonUnauthenticated, "metadata is not provided")
 	}
 
 	values := md["authorization"]
 	if len(values) == 0 {
-		return status.Errorf(codes.Unauthenticated, "authorization token is not provided")
+		return nil, apperror.New(codes.Unauthenticated, apperror.ReasonUnauthenticated, "authorization token is not provided")
 	}
 
 	accessToken := values[0]
 	claims, err := interceptor.jwtManager.Verify(accessToken)
 	if err != nil {
-		return status.Errorf(codes.Unauthenticated, "access token is invalid: %v", err)
+		return nil, apperror.New(codes.Unauthenticated, apperror.ReasonUnauthenticated, "access token is invalid").WithCause(err)
 	}
 
+	ctx = ContextWithLogAttrs(ctx, "user", claims.Username, "role", claims.Role)
 	for _, role := range accessibleRoles {
 		if role == claims.Role {
-			return nil
+			return ContextWithUserClaims(ctx, claims), nil
 		}
 	}
 
-	return status.Error(codes.PermissionDenied, "no permission to access this RPC")
+	LoggerFromContext(ctx).Warn("permission denied")
+	return nil, apperror.New(codes.PermissionDenied, apperror.ReasonPermissionDenied, "no permission to access this RPC")
+}
+
+// attachOptionalClaims returns a context that carries the user claims if the request has a valid token
+func (interceptor *AuthInterceptor) attachOptionalClaims(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md["authorization"]
+	if len(values) == 0 {
+		return ctx
+	}
+
+	claims, err := interceptor.jwtManager.Verify(values[0])
+	if err != nil {
+		return ctx
+	}
+
+	return ContextWithUserClaims(ContextWithLogAttrs(ctx, "user", claims.Username, "role", claims.Role), claims)
+}
+
+type userClaimsKey struct{}
+
+// ContextWithUserClaims returns a copy of ctx that carries the claims of the authenticated user
+func ContextWithUserClaims(ctx context.Context, claims *UserClaims) context.Context {
+	return context.WithValue(ctx, userClaimsKey{}, claims)
+}
+
+// UserClaimsFromContext returns the claims of the authenticated user stored in ctx
+func UserClaimsFromContext(ctx context.Context) (*UserClaims, bool) {
+	claims, ok := ctx.Value(userClaimsKey{}).(*UserClaims)
+	return claims, ok && claims != nil
+}
+
+// serverStreamWithContext is a server stream whose context is replaced by an interceptor
+type serverStreamWithContext struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *serverStreamWithContext) Context() context.Context {
+	return stream.ctx
 }
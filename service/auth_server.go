@@ -3,9 +3,10 @@ package service
 import (
 	"context" // เรียกใช้งาน package context เพื่อจัดการกับบริบทของการเรียก RPC
 
+	"grpc-project/apperror" // เรียกใช้งาน package apperror เพื่อแปลงข้อผิดพลาดเป็นสถานะของ gRPC พร้อมรายละเอียด
 	"grpc-project/example.com/pcbook/pb" // import protobuf generated code
+	"grpc-project/tracing" // เรียกใช้งาน package tracing เพื่อสร้าง span รอบการตรวจรหัสผ่าน
 	"google.golang.org/grpc/codes" // เรียกใช้งาน package codes เพื่อใช้รหัสสถานะของ gRPC
-	"google.golang.org/grpc/status" // เรียกใช้งาน package status เพื่อสร้างและจัดการกับสถานะของ gRPC
 )
 
 // AuthServer is the server for authentication
@@ -26,22 +27,31 @@ func NewAuthServer(userStore UserStore, jwtManager *JWTManager) pb.AuthServiceSe
 // Login เป็น unary RPC สำหรับการเข้าสู่ระบบของผู้ใช้
 func (server *AuthServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
 	// ค้นหาผู้ใช้โดยใช้ชื่อผู้ใช้จาก request
-	user, err := server.userStore.Find(req.GetUsername())
+	user, err := server.userStore.Find(ctx, req.GetUsername())
 	if err != nil {
 		// ถ้าค้นหาไม่สำเร็จ ให้คืนค่า error พร้อมกับรหัสสถานะ Internal
-		return nil, status.Errorf(codes.Internal, "cannot find user: %v", err)
+		return nil, apperror.Wrap(err, "cannot find user")
 	}
 
-	if user == nil || !user.IsCorrectPassword(req.GetPassword()) {
+	// ตรวจรหัสผ่านด้วย bcrypt ซึ่งใช้เวลานาน จึงแยกเป็น span ของตัวเอง
+	correctPassword := false
+	if user != nil {
+		_, span := tracing.Start(ctx, "User.IsCorrectPassword")
+		correctPassword = user.IsCorrectPassword(req.GetPassword())
+		span.End()
+	}
+
+	if !correctPassword {
 		// ถ้าผู้ใช้ไม่พบหรือรหัสผ่านไม่ถูกต้อง ให้คืนค่า error พร้อมกับรหัสสถานะ NotFound
-		return nil, status.Errorf(codes.NotFound, "incorrect username/password")
+		LoggerFromContext(ctx).Warn("login failed", "user", req.GetUsername())
+		return nil, apperror.New(codes.NotFound, apperror.ReasonIncorrectCredentials, "incorrect username/password")
 	}
 
 	// สร้าง token ใหม่สำหรับผู้ใช้
 	token, err := server.jwtManager.Generate(user)
 	if err != nil {
 		// ถ้าการสร้าง token ล้มเหลว ให้คืนค่า error พร้อมกับรหัสสถานะ Internal
-		return nil, status.Errorf(codes.Internal, "cannot generate access token")
+		return nil, apperror.Internal(err, "cannot generate access token")
 	}
 
 	// สร้าง response ที่มี access token
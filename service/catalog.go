@@ -0,0 +1,215 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"grpc-project/example.com/pcbook/pb"
+	"sync"
+)
+
+// ErrHasDependents is returned when a laptop cannot be deleted because images or ratings still refer to it
+var ErrHasDependents = errors.New("record has dependent records")
+
+// Catalog coordinates the laptop, image and rating stores so that images and ratings
+// never refer to a laptop that does not exist
+type Catalog struct {
+	// mutex serializes deletes with the operations that attach images and ratings to a laptop
+	mutex       sync.RWMutex
+	laptopStore LaptopStore
+	imageStore  ImageStore
+	ratingStore RatingStore
+}
+
+// DeleteResult reports what was removed by Catalog.DeleteLaptop
+type DeleteResult struct {
+	DeletedImages  int
+	DeletedRatings int
+}
+
+// NewCatalog returns a new catalog on top of the given stores, imageStore and ratingStore may be nil
+func NewCatalog(laptopStore LaptopStore, imageStore ImageStore, ratingStore RatingStore) *Catalog {
+	return &Catalog{
+		laptopStore: laptopStore,
+		imageStore:  imageStore,
+		ratingStore: ratingStore,
+	}
+}
+
+// SaveImage saves an image of an existing laptop
+func (catalog *Catalog) SaveImage(ctx context.Context, laptopID string, imageType string, imageData bytes.Buffer) (string, error) {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	err := catalog.requireLaptop(ctx, laptopID)
+	if err != nil {
+		return "", err
+	}
+
+	return catalog.imageStore.Save(ctx, laptopID, imageType, imageData)
+}
+
+// AddRating adds or replaces the rating of a user for an existing laptop
+func (catalog *Catalog) AddRating(ctx context.Context, laptopID string, username string, score float64, review string) (*Rating, error) {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	err := catalog.requireLaptop(ctx, laptopID)
+	if err != nil {
+		return nil, err
+	}
+
+	return catalog.ratingStore.Add(ctx, laptopID, username, score, review)
+}
+
+// ImportLaptop saves a new laptop together with the ratings of its users, keeping their timestamps.
+// If the ratings cannot be saved, the laptop is deleted again, so that it is imported completely or not at all.
+func (catalog *Catalog) ImportLaptop(ctx context.Context, laptop *pb.Laptop, ratings []*UserRating) error {
+	catalog.mutex.RLock()
+	defer catalog.mutex.RUnlock()
+
+	if catalog.ratingStore == nil && len(ratings) > 0 {
+		return errors.New("cannot import ratings without a rating store")
+	}
+
+	err := catalog.laptopStore.Save(ctx, laptop)
+	if err != nil {
+		return fmt.Errorf("cannot save laptop: %w", err)
+	}
+	if len(ratings) == 0 {
+		return nil
+	}
+
+	err = catalog.ratingStore.Restore(ctx, ratings)
+	if err != nil {
+		catalog.removeImported(ctx, laptop.GetId())
+		return fmt.Errorf("cannot save ratings: %w", err)
+	}
+	return nil
+}
+
+// removeImported compensates a failed import: Restore may have saved some of the ratings before it failed.
+func (catalog *Catalog) removeImported(ctx context.Context, laptopID string) {
+	ctx = context.WithoutCancel(ctx)
+	_, err := catalog.ratingStore.RemoveAll(ctx, laptopID)
+	if err != nil {
+		LoggerFromContext(ctx).Error("catalog: cannot remove imported ratings", "laptop_id", laptopID, "error", err)
+	}
+	err = catalog.laptopStore.Delete(ctx, laptopID)
+	if err != nil {
+		LoggerFromContext(ctx).Error("catalog: cannot remove imported laptop", "laptop_id", laptopID, "error", err)
+	}
+}
+
+// DeleteLaptop deletes a laptop. If cascade is false and the laptop still has images or ratings,
+// ErrHasDependents is returned and nothing is deleted. If cascade is true, its images and ratings
+// are deleted as well; when a step fails, the steps already done are compensated.
+func (catalog *Catalog) DeleteLaptop(ctx context.Context, laptopID string, cascade bool) (*DeleteResult, error) {
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+
+	laptop, err := catalog.laptopStore.Find(ctx, laptopID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find laptop: %w", err)
+	}
+	if laptop == nil {
+		return nil, fmt.Errorf("laptop %s: %w", laptopID, ErrNotFound)
+	}
+
+	imageIDs, ratings, err := catalog.dependents(ctx, laptopID)
+	if err != nil {
+		return nil, err
+	}
+	if !cascade && (len(imageIDs) > 0 || len(ratings) > 0) {
+		return nil, fmt.Errorf(
+			"laptop %s has %d images and %d ratings: %w",
+			laptopID, len(imageIDs), len(ratings), ErrHasDependents,
+		)
+	}
+
+	// ลบแล็ปท็อปก่อน แล้วจึงลบ rating และภาพตามลำดับ
+	// ภาพถูกลบเป็นขั้นตอนสุดท้ายเพราะเป็นขั้นตอนเดียวที่ย้อนกลับไม่ได้
+	err = catalog.laptopStore.Delete(ctx, laptopID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot delete laptop: %w", err)
+	}
+
+	result := &DeleteResult{}
+
+	var removedRatings []*UserRating
+	if catalog.ratingStore != nil && len(ratings) > 0 {
+		removedRatings, err = catalog.ratingStore.RemoveAll(ctx, laptopID)
+		if err != nil {
+			catalog.restoreLaptop(ctx, laptop)
+			return nil, fmt.Errorf("cannot delete ratings: %w", err)
+		}
+		result.DeletedRatings = len(removedRatings)
+	}
+
+	if catalog.imageStore != nil && len(imageIDs) > 0 {
+		result.DeletedImages, err = catalog.imageStore.DeleteByLaptop(ctx, laptopID)
+		if err != nil {
+			if result.DeletedImages > 0 {
+				// some images are gone for good, so keep the laptop deleted and report the partial result
+				return result, fmt.Errorf("cannot delete all images, %d of %d deleted: %w", result.DeletedImages, len(imageIDs), err)
+			}
+			catalog.restoreRatings(ctx, removedRatings)
+			catalog.restoreLaptop(ctx, laptop)
+			return nil, fmt.Errorf("cannot delete images: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// dependents returns the images and ratings that refer to a laptop
+func (catalog *Catalog) dependents(ctx context.Context, laptopID string) ([]string, []*UserRating, error) {
+	var imageIDs []string
+	var ratings []*UserRating
+	var err error
+
+	if catalog.imageStore != nil {
+		imageIDs, err = catalog.imageStore.ListByLaptop(ctx, laptopID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot list images: %w", err)
+		}
+	}
+	if catalog.ratingStore != nil {
+		ratings, err = catalog.ratingStore.ListRatings(ctx, laptopID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot list ratings: %w", err)
+		}
+	}
+	return imageIDs, ratings, nil
+}
+
+func (catalog *Catalog) requireLaptop(ctx context.Context, laptopID string) error {
+	laptop, err := catalog.laptopStore.Find(ctx, laptopID)
+	if err != nil {
+		return fmt.Errorf("cannot find laptop: %w", err)
+	}
+	if laptop == nil {
+		return fmt.Errorf("laptop %s: %w", laptopID, ErrNotFound)
+	}
+	return nil
+}
+
+// restoreLaptop and restoreRatings compensate a failed delete. They run even when ctx is canceled,
+// since giving up half way would leave images or ratings without their laptop.
+func (catalog *Catalog) restoreLaptop(ctx context.Context, laptop *pb.Laptop) {
+	err := catalog.laptopStore.Save(context.WithoutCancel(ctx), laptop)
+	if err != nil {
+		LoggerFromContext(ctx).Error("catalog: cannot restore laptop", "laptop_id", laptop.GetId(), "error", err)
+	}
+}
+
+func (catalog *Catalog) restoreRatings(ctx context.Context, ratings []*UserRating) {
+	if len(ratings) == 0 {
+		return
+	}
+	err := catalog.ratingStore.Restore(context.WithoutCancel(ctx), ratings)
+	if err != nil {
+		LoggerFromContext(ctx).Error("catalog: cannot restore ratings", "error", err)
+	}
+}
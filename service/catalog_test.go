@@ -0,0 +1,124 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCatalogDeleteLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	imageStore := service.NewDiskImageStore(t.TempDir())
+	ratingStore := service.NewInMemoryRatingStore()
+	catalog := service.NewCatalog(laptopStore, imageStore, ratingStore)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(context.Background(), laptop))
+
+	_, err := catalog.SaveImage(context.Background(), laptop.GetId(), ".jpg", *bytes.NewBufferString("image"))
+	require.NoError(t, err)
+	_, err = catalog.AddRating(context.Background(), laptop.GetId(), "user1", 8, "good")
+	require.NoError(t, err)
+
+	// images and ratings still refer to the laptop
+	_, err = catalog.DeleteLaptop(context.Background(), laptop.GetId(), false)
+	require.ErrorIs(t, err, service.ErrHasDependents)
+	found, err := laptopStore.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.NotNil(t, found)
+
+	result, err := catalog.DeleteLaptop(context.Background(), laptop.GetId(), true)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.DeletedImages)
+	require.Equal(t, 1, result.DeletedRatings)
+
+	found, err = laptopStore.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found)
+	imageIDs, err := imageStore.ListByLaptop(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Empty(t, imageIDs)
+	rating, err := ratingStore.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Nil(t, rating)
+
+	// nothing can be attached to a deleted laptop
+	_, err = catalog.AddRating(context.Background(), laptop.GetId(), "user1", 8, "")
+	require.ErrorIs(t, err, service.ErrNotFound)
+	_, err = catalog.SaveImage(context.Background(), laptop.GetId(), ".jpg", *bytes.NewBufferString("image"))
+	require.ErrorIs(t, err, service.ErrNotFound)
+	_, err = catalog.DeleteLaptop(context.Background(), laptop.GetId(), true)
+	require.ErrorIs(t, err, service.ErrNotFound)
+}
+
+func TestCatalogDeleteLaptopCompensatesFailure(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	imageStore := &failingImageStore{ImageStore: service.NewDiskImageStore(t.TempDir())}
+	ratingStore := service.NewInMemoryRatingStore()
+	catalog := service.NewCatalog(laptopStore, imageStore, ratingStore)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(context.Background(), laptop))
+	_, err := catalog.SaveImage(context.Background(), laptop.GetId(), ".jpg", *bytes.NewBufferString("image"))
+	require.NoError(t, err)
+	_, err = catalog.AddRating(context.Background(), laptop.GetId(), "user1", 8, "good")
+	require.NoError(t, err)
+
+	_, err = catalog.DeleteLaptop(context.Background(), laptop.GetId(), true)
+	require.Error(t, err)
+
+	// the laptop and its ratings are restored
+	found, err := laptopStore.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	rating, err := ratingStore.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.NotNil(t, rating)
+	require.Equal(t, uint32(1), rating.Count)
+	require.Equal(t, 8.0, rating.Sum)
+}
+
+func TestServerDeleteLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	ratingStore := service.NewInMemoryRatingStore()
+	server := service.NewLaptopServer(laptopStore, nil, ratingStore, nil)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(context.Background(), laptop))
+	_, err := ratingStore.Add(context.Background(), laptop.GetId(), "user1", 5, "")
+	require.NoError(t, err)
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.GetId()})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	res, err := server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.GetId(), Cascade: true})
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), res.GetDeletedRatings())
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// failingImageStore is an image store that cannot delete images
+type failingImageStore struct {
+	service.ImageStore
+}
+
+func (store *failingImageStore) DeleteByLaptop(ctx context.Context, laptopID string) (int, error) {
+	return 0, errors.New("disk is read-only")
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"grpc-project/apperror"
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/validation"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ExportCatalog streams every laptop of the store, ordered by ID, with the ratings of all its users
+func (server *LaptopServer) ExportCatalog(req *pb.ExportCatalogRequest, stream pb.LaptopService_ExportCatalogServer) error {
+	ctx := stream.Context()
+	logger := LoggerFromContext(ctx)
+	logger.Info("received export-catalog request")
+
+	count := 0
+	err := server.laptopStore.List(ctx, func(laptop *pb.Laptop) error {
+		entry := &pb.CatalogEntry{Laptop: laptop}
+		if server.ratingStore != nil {
+			ratings, err := server.ratingStore.ListRatings(ctx, laptop.GetId())
+			if err != nil {
+				return apperror.Wrap(err, "cannot list ratings")
+			}
+			sort.Slice(ratings, func(i, j int) bool {
+				return ratings[i].Username < ratings[j].Username
+			})
+			for _, rating := range ratings {
+				entry.Ratings = append(entry.Ratings, &pb.Review{
+					Username:  rating.Username,
+					Score:     rating.Score,
+					Review:    rating.Review,
+					UpdatedAt: timestamppb.New(rating.UpdatedAt),
+					CreatedAt: timestamppb.New(rating.CreatedAt),
+				})
+			}
+		}
+
+		err := stream.Send(entry)
+		if err != nil {
+			return streamBroken(err, "cannot send stream response")
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return logError(ctx, apperror.Wrap(err, "cannot list laptops"))
+	}
+
+	logger.Info("exported catalog", "count", count)
+	return nil
+}
+
+// ImportCatalog saves the laptops and ratings of a stream of catalog entries. Like CreateLaptops,
+// the responses are sent in the order of the requests and an entry that cannot be imported gets
+// its own status without stopping the stream. An entry with validate_only is checked, not saved.
+func (server *LaptopServer) ImportCatalog(stream pb.LaptopService_ImportCatalogServer) error {
+	ctx := stream.Context()
+	logger := LoggerFromContext(ctx)
+	locale := LocaleFromContext(ctx)
+
+	for index := uint32(0); ; index++ {
+		err := contextError(ctx)
+		if err != nil {
+			return err
+		}
+
+		req, err := stream.Recv()
+		if err == io.EOF {
+			logger.Info("no more import-catalog requests", "count", index)
+			return nil
+		}
+		if err != nil {
+			return logError(ctx, streamBroken(err, "cannot receive stream request"))
+		}
+
+		res := &pb.ImportCatalogResponse{Index: index}
+		id, err := server.importEntry(ctx, req.GetEntry(), req.GetValidateOnly())
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			res.Status = itemStatus(apperror.From(err), locale)
+		} else {
+			res.Id = id
+			res.ImportedRatings = uint32(len(req.GetEntry().GetRatings()))
+		}
+
+		err = stream.Send(res)
+		if err != nil {
+			return logError(ctx, streamBroken(err, "cannot send stream response"))
+		}
+	}
+}
+
+// importEntry validates a catalog entry and saves it unless validateOnly is set.
+// It returns the ID of the laptop, which is generated if the entry has none.
+func (server *LaptopServer) importEntry(ctx context.Context, entry *pb.CatalogEntry, validateOnly bool) (string, error) {
+	laptop := entry.GetLaptop()
+	violations := validation.LaptopViolations("entry.laptop", laptop)
+	violations = append(violations, ratingViolations("entry.ratings", entry.GetRatings())...)
+	if len(violations) > 0 {
+		return "", logError(ctx, apperror.InvalidArgument("catalog entry is invalid", violations...))
+	}
+
+	if laptop.GetId() == "" {
+		laptop.Id = uuid.NewString()
+	}
+	alreadyExists := apperror.New(
+		codes.AlreadyExists, apperror.ReasonLaptopAlreadyExists, "laptop %s already exists", laptop.GetId(),
+	).WithMetadata("laptop_id", laptop.GetId())
+
+	if validateOnly {
+		other, err := server.laptopStore.Find(ctx, laptop.GetId())
+		if err != nil {
+			return "", logError(ctx, apperror.Wrap(err, "cannot find laptop"))
+		}
+		if other != nil {
+			return "", logError(ctx, alreadyExists)
+		}
+		return laptop.GetId(), nil
+	}
+
+	now := time.Now()
+	ratings := make([]*UserRating, len(entry.GetRatings()))
+	for i, review := range entry.GetRatings() {
+		ratings[i] = &UserRating{
+			LaptopID:  laptop.GetId(),
+			Username:  review.GetUsername(),
+			Score:     review.GetScore(),
+			Review:    review.GetReview(),
+			CreatedAt: importedTime(review.GetCreatedAt(), review.GetUpdatedAt(), now),
+			UpdatedAt: importedTime(review.GetUpdatedAt(), nil, now),
+		}
+	}
+
+	err := server.catalog.ImportLaptop(ctx, laptop, ratings)
+	if errors.Is(err, ErrAlreadyExists) && ctx.Err() == nil {
+		return "", logError(ctx, alreadyExists)
+	}
+	if err != nil {
+		return "", logError(ctx, apperror.Wrap(err, "cannot import laptop"))
+	}
+
+	LoggerFromContext(ctx).Info("imported laptop", "laptop_id", laptop.GetId(), "ratings", len(ratings))
+	return laptop.GetId(), nil
+}
+
+// ratingViolations checks the ratings of a catalog entry, a user can rate a laptop only once
+func ratingViolations(path string, reviews []*pb.Review) []apperror.FieldViolation {
+	var violations []apperror.FieldViolation
+	users := map[string]int{}
+	for i, review := range reviews {
+		field := fmt.Sprintf("%s[%d]", path, i)
+		if review.GetUsername() == "" {
+			violations = append(violations, apperror.Violation(field+".username", "is required"))
+		} else if first, ok := users[review.GetUsername()]; ok {
+			violations = append(violations, apperror.Violation(
+				field+".username", "must be unique, %s is also the username of %s[%d]", review.GetUsername(), path, first,
+			))
+		} else {
+			users[review.GetUsername()] = i
+		}
+
+		if score := review.GetScore(); !(score >= MinRatingScore && score <= MaxRatingScore) {
+			violations = append(violations, apperror.Violation(
+				field+".score", "must be between %d and %d, got %.2f", MinRatingScore, MaxRatingScore, score,
+			))
+		}
+	}
+	return violations
+}
+
+// importedTime returns the first timestamp that is set, or now
+func importedTime(timestamp *timestamppb.Timestamp, fallback *timestamppb.Timestamp, now time.Time) time.Time {
+	switch {
+	case timestamp != nil:
+		return timestamp.AsTime()
+	case fallback != nil:
+		return fallback.AsTime()
+	default:
+		return now
+	}
+}
@@ -0,0 +1,166 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grpc-project/apperror"
+	"grpc-project/client"
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestClientExportImportCatalog(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	sourceLaptops := service.NewInMemoryLaptopStore()
+	sourceRatings := service.NewInMemoryRatingStore()
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop(), sample.NewLaptop()}
+	for _, laptop := range laptops {
+		require.NoError(t, sourceLaptops.Save(ctx, laptop))
+	}
+	_, err := sourceRatings.Add(ctx, laptops[0].GetId(), "user2", 9, "fast")
+	require.NoError(t, err)
+	_, err = sourceRatings.Add(ctx, laptops[0].GetId(), "user1", 6, "")
+	require.NoError(t, err)
+
+	source := client.NewLaptopClient(newTestClientConn(t, startTestLaptopServer(t, sourceLaptops, nil, sourceRatings)))
+	exported := []*pb.CatalogEntry{}
+	err = source.ExportCatalog(ctx, func(entry *pb.CatalogEntry) error {
+		exported = append(exported, entry)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, exported, len(laptops))
+
+	// laptops are exported by ID, ratings by username
+	ratings := map[string][]*pb.Review{}
+	for i, entry := range exported {
+		if i > 0 {
+			require.Less(t, exported[i-1].GetLaptop().GetId(), entry.GetLaptop().GetId())
+		}
+		ratings[entry.GetLaptop().GetId()] = entry.GetRatings()
+	}
+	require.Len(t, ratings[laptops[0].GetId()], 2)
+	require.Equal(t, "user1", ratings[laptops[0].GetId()][0].GetUsername())
+	require.Equal(t, "fast", ratings[laptops[0].GetId()][1].GetReview())
+
+	targetLaptops := service.NewInMemoryLaptopStore()
+	targetRatings := service.NewInMemoryRatingStore()
+	target := client.NewLaptopClient(newTestClientConn(t, startTestLaptopServer(t, targetLaptops, nil, targetRatings)))
+	importCatalog := func(entries []*pb.CatalogEntry, validateOnly bool) []client.ImportCatalogResult {
+		channel := make(chan *pb.CatalogEntry, len(entries))
+		for _, entry := range entries {
+			channel <- entry
+		}
+		close(channel)
+
+		results := []client.ImportCatalogResult{}
+		err := target.ImportCatalog(ctx, channel, validateOnly, 2, func(result client.ImportCatalogResult) {
+			results = append(results, result)
+		})
+		require.NoError(t, err)
+		return results
+	}
+
+	// a dry run checks the entries without saving them
+	for _, result := range importCatalog(exported, true) {
+		require.NoError(t, result.Err())
+	}
+	count, err := targetLaptops.Count(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	for _, result := range importCatalog(exported, false) {
+		require.NoError(t, result.Err())
+		require.Equal(t, exported[result.Index].GetLaptop().GetId(), result.ID)
+	}
+	for _, entry := range exported {
+		laptop, err := targetLaptops.Find(ctx, entry.GetLaptop().GetId())
+		require.NoError(t, err)
+		require.True(t, proto.Equal(entry.GetLaptop(), laptop))
+	}
+	imported, err := targetRatings.ListRatings(ctx, laptops[0].GetId())
+	require.NoError(t, err)
+	require.Len(t, imported, 2)
+	for _, rating := range imported {
+		for _, review := range ratings[laptops[0].GetId()] {
+			if review.GetUsername() == rating.Username {
+				require.Equal(t, review.GetScore(), rating.Score)
+				require.True(t, review.GetUpdatedAt().AsTime().Equal(rating.UpdatedAt))
+				require.True(t, review.GetCreatedAt().AsTime().Equal(rating.CreatedAt))
+			}
+		}
+	}
+
+	// entries that already exist or are invalid fail one by one
+	invalid := &pb.CatalogEntry{
+		Laptop: sample.NewLaptop(),
+		Ratings: []*pb.Review{
+			{Username: "user1", Score: 5},
+			{Username: "user1", Score: 11},
+		},
+	}
+	noID := &pb.CatalogEntry{Laptop: sample.NewLaptop()}
+	noID.Laptop.Id = ""
+	results := importCatalog([]*pb.CatalogEntry{exported[0], invalid, noID}, false)
+	require.Len(t, results, 3)
+	require.Equal(t, int32(codes.AlreadyExists), results[0].Status.GetCode())
+	require.Equal(t, apperror.ReasonLaptopAlreadyExists, results[0].Status.GetReason())
+	require.Equal(t, int32(codes.InvalidArgument), results[1].Status.GetCode())
+	violations := map[string]string{}
+	for _, violation := range results[1].Status.GetFieldViolations() {
+		violations[violation.GetField()] = violation.GetDescription()
+	}
+	require.Equal(t, map[string]string{
+		"entry.ratings[1].username": "must be unique, user1 is also the username of entry.ratings[0]",
+		"entry.ratings[1].score":    "must be between 1 and 10, got 11.00",
+	}, violations)
+	require.NoError(t, results[2].Err())
+	require.NotEmpty(t, results[2].ID)
+
+	count, err = targetLaptops.Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, len(laptops)+1, count)
+}
+
+func TestCatalogImportLaptop(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	laptopStore := service.NewInMemoryLaptopStore()
+	ratingStore := service.NewInMemoryRatingStore()
+	catalog := service.NewCatalog(laptopStore, nil, ratingStore)
+
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	laptop := sample.NewLaptop()
+	err := catalog.ImportLaptop(ctx, laptop, []*service.UserRating{
+		{LaptopID: laptop.GetId(), Username: "user1", Score: 8, CreatedAt: updatedAt, UpdatedAt: updatedAt},
+	})
+	require.NoError(t, err)
+	ratings, err := ratingStore.ListRatings(ctx, laptop.GetId())
+	require.NoError(t, err)
+	require.Len(t, ratings, 1)
+	require.Equal(t, updatedAt, ratings[0].UpdatedAt)
+
+	// a laptop whose ratings cannot be saved is not imported
+	other := sample.NewLaptop()
+	err = catalog.ImportLaptop(ctx, other, []*service.UserRating{
+		{LaptopID: other.GetId(), Username: "user1", Score: 8},
+		{LaptopID: other.GetId(), Username: "user1", Score: 9},
+	})
+	require.ErrorIs(t, err, service.ErrAlreadyExists)
+	found, err := laptopStore.Find(ctx, other.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found)
+	rating, err := ratingStore.Find(ctx, other.GetId())
+	require.NoError(t, err)
+	require.Nil(t, rating)
+}
@@ -0,0 +1,223 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-project/client"
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestClientLaptopMethods(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	imageStore := service.NewDiskImageStore(t.TempDir())
+	ratingStore := service.NewInMemoryRatingStore()
+	jwtManager := service.NewJWTManager("secret", time.Minute)
+	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, ratingStore, testAuthServerOptions(jwtManager)...)
+	laptopClient := client.NewLaptopClient(newTestClientConn(t, serverAddress))
+	ctx := testContextWithToken(t, jwtManager, "user1")
+
+	laptop := sample.NewLaptop()
+	laptop.Id = ""
+	id, err := laptopClient.CreateLaptop(ctx, laptop)
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+
+	laptop.Id = id
+	_, err = laptopClient.CreateLaptop(ctx, laptop)
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+	require.ErrorContains(t, err, "cannot create laptop")
+
+	// the image is larger than a chunk, and its size is not a multiple of the chunk size
+	image := bytes.Repeat([]byte("image"), 1000)
+	upload, err := laptopClient.UploadImage(ctx, id, ".jpg", bytes.NewReader(image))
+	require.NoError(t, err)
+	require.NotEmpty(t, upload.GetId())
+	require.Equal(t, uint32(len(image)), upload.GetSize())
+
+	_, err = laptopClient.UploadImage(ctx, "unknown", ".jpg", bytes.NewReader(image))
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	responses, err := laptopClient.RateLaptop(ctx, []*pb.RateLaptopRequest{
+		{LaptopId: id, Score: 8},
+		{LaptopId: id, Score: 6, Review: "good keyboard"},
+	})
+	require.NoError(t, err)
+	require.Len(t, responses, 2)
+	require.Equal(t, 6.0, responses[1].GetAverageScore())
+
+	_, err = laptopClient.RateLaptop(ctx, []*pb.RateLaptopRequest{{LaptopId: id, Score: 11}})
+	require.Error(t, err)
+	_, err = laptopClient.RateLaptop(context.Background(), []*pb.RateLaptopRequest{{LaptopId: id, Score: 8}})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ratings, err := laptopClient.GetLaptopRatings(ctx, id, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), ratings.GetRatedCount())
+	require.Equal(t, "good keyboard", ratings.GetReviews()[0].GetReview())
+
+	got, err := laptopClient.GetLaptop(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, id, got.GetLaptop().GetId())
+	require.Equal(t, 6.0, got.GetScore().GetAverageScore())
+
+	found := []string{}
+	req := &pb.SearchLaptopRequest{
+		Filter: &pb.Filter{MaxPriceUsd: laptop.GetPriceUsd()},
+		SortBy: pb.SearchLaptopRequest_AVERAGE_SCORE,
+	}
+	err = laptopClient.SearchLaptop(ctx, req, func(res *pb.SearchLaptopResponse) error {
+		found = append(found, res.GetLaptop().GetId())
+		require.Equal(t, 6.0, res.GetScore().GetAverageScore())
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{id}, found)
+
+	// an error of handle stops the search
+	stop := errors.New("stop")
+	err = laptopClient.SearchLaptop(ctx, req, func(res *pb.SearchLaptopResponse) error {
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+
+	// a laptop with images and ratings is only deleted with cascade
+	_, err = laptopClient.DeleteLaptop(ctx, id, false)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	deleted, err := laptopClient.DeleteLaptop(ctx, id, true)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), deleted.GetDeletedImages())
+	_, err = laptopClient.GetLaptop(ctx, id)
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestClientRetriesIdempotentCalls(t *testing.T) {
+	t.Parallel()
+
+	// the first attempt of every RPC fails as if the server is restarting
+	var mutex sync.Mutex
+	attempts := map[string]int{}
+	keys := map[string][]string{}
+	unavailable := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		mutex.Lock()
+		attempts[info.FullMethod]++
+		keys[info.FullMethod] = append(keys[info.FullMethod], md.Get(client.IdempotencyKeyKey)...)
+		first := attempts[info.FullMethod] == 1
+		mutex.Unlock()
+		if first {
+			return nil, status.Error(codes.Unavailable, "restarting")
+		}
+		return handler(ctx, req)
+	}
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	ratingStore := service.NewInMemoryRatingStore()
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, ratingStore, grpc.UnaryInterceptor(unavailable))
+
+	policy := client.DefaultRetryPolicy
+	policy.InitialBackoff = 10 * time.Millisecond
+	conn, err := grpc.Dial(serverAddress, grpc.WithInsecure(), client.WithRetryPolicy(policy))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	laptopClient := client.NewLaptopClient(conn)
+
+	laptop := sample.NewLaptop()
+	id, err := laptopClient.CreateLaptop(context.Background(), laptop)
+	require.NoError(t, err)
+	require.Equal(t, laptop.GetId(), id)
+
+	// every attempt is sent with the same idempotency key
+	createKeys := keys["/techshcool.pcbook.LaptopService/CreateLaptop"]
+	require.Len(t, createKeys, 2)
+	require.NotEmpty(t, createKeys[0])
+	require.Equal(t, createKeys[0], createKeys[1])
+
+	// a key of the caller is kept
+	_, err = laptopClient.CreateLaptop(client.WithIdempotencyKey(context.Background(), "key-1"), sample.NewLaptop())
+	require.NoError(t, err)
+	require.Equal(t, "key-1", keys["/techshcool.pcbook.LaptopService/CreateLaptop"][2])
+
+	_, err = laptopClient.GetLaptopRatings(context.Background(), id, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts["/techshcool.pcbook.LaptopService/GetLaptopRatings"])
+
+	// a call that is not idempotent is not retried
+	_, err = pb.NewLaptopServiceClient(conn).DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: id})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, 1, attempts["/techshcool.pcbook.LaptopService/DeleteLaptop"])
+}
+
+func TestAuthInterceptorClose(t *testing.T) {
+	t.Parallel()
+
+	userStore := service.NewInMemoryUserStore()
+	user, err := service.NewUser("admin1", "secret", "admin")
+	require.NoError(t, err)
+	require.NoError(t, userStore.Save(context.Background(), user))
+
+	var logins atomic.Int32
+	countLogins := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		logins.Add(1)
+		return handler(ctx, req)
+	}
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(countLogins))
+	pb.RegisterAuthServiceServer(grpcServer, service.NewAuthServer(userStore, service.NewJWTManager("secret", time.Minute)))
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	// the context of the first login ends right away, the refresh goes on until Close
+	authClient := client.NewAuthClient(newTestClientConn(t, listener.Addr().String()), "admin1", "secret")
+	ctx, cancel := context.WithCancel(context.Background())
+	interceptor, err := client.NewAuthInterceptor(ctx, authClient, nil, 200*time.Millisecond)
+	cancel()
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return logins.Load() >= 2 }, 3*time.Second, 10*time.Millisecond)
+	interceptor.Close()
+	interceptor.Close()
+
+	stopped := logins.Load()
+	time.Sleep(500 * time.Millisecond)
+	require.Equal(t, stopped, logins.Load())
+}
+
+func TestRetryPolicyServiceConfig(t *testing.T) {
+	t.Parallel()
+
+	policy := client.RetryPolicy{
+		MaxAttempts:          4,
+		InitialBackoff:       100 * time.Millisecond,
+		MaxBackoff:           2 * time.Second,
+		BackoffMultiplier:    1.5,
+		RetryableStatusCodes: []codes.Code{codes.DeadlineExceeded, codes.Canceled, codes.OK},
+	}
+	config := policy.ServiceConfig()
+	require.Contains(t, config, `"maxAttempts":4,"initialBackoff":"0.1s","maxBackoff":"2s","backoffMultiplier":1.5`)
+	require.Contains(t, config, `"retryableStatusCodes":["DEADLINE_EXCEEDED","CANCELLED","OK"]`)
+	require.Contains(t, config, `{"service":"techshcool.pcbook.LaptopService","method":"SearchLaptop"}`)
+	require.NotContains(t, config, "RateLaptop")
+
+	// gRPC accepts the config
+	conn, err := grpc.Dial("localhost:0", grpc.WithInsecure(), client.WithRetryPolicy(policy))
+	require.NoError(t, err)
+	conn.Close()
+}
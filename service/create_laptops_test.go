@@ -0,0 +1,151 @@
+package service_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-project/apperror"
+	"grpc-project/client"
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClientCreateLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	existing := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(context.Background(), existing))
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil,
+		grpc.StreamInterceptor(service.NewLocaleInterceptor().Stream()),
+	)
+	laptopClient := client.NewLaptopClient(newTestClientConn(t, serverAddress))
+
+	noID := sample.NewLaptop()
+	noID.Id = ""
+	invalid := sample.NewLaptop()
+	invalid.PriceUsd = -1
+	valid := sample.NewLaptop()
+	sent := []*pb.Laptop{noID, existing, invalid, valid}
+
+	laptops := make(chan *pb.Laptop)
+	go func() {
+		defer close(laptops)
+		for _, laptop := range sent {
+			laptops <- laptop
+		}
+	}()
+
+	results := []client.CreateLaptopsResult{}
+	err := laptopClient.CreateLaptops(client.WithAcceptLanguage(context.Background(), "th"), laptops, 2, func(result client.CreateLaptopsResult) {
+		results = append(results, result)
+	})
+	require.NoError(t, err)
+	require.Len(t, results, len(sent))
+
+	// a failed laptop does not stop the others, and the results keep the order of the requests
+	for i, result := range results {
+		require.Equal(t, i, result.Index)
+		require.Same(t, sent[i], result.Laptop)
+	}
+	require.NotEmpty(t, results[0].ID)
+	require.Nil(t, results[0].Status)
+	require.Equal(t, valid.GetId(), results[3].ID)
+
+	require.Empty(t, results[1].ID)
+	require.Equal(t, codes.AlreadyExists, status.Code(results[1].Err()))
+	require.Equal(t, apperror.ReasonLaptopAlreadyExists, results[1].Status.GetReason())
+
+	require.Equal(t, codes.InvalidArgument, status.Code(results[2].Err()))
+	require.Equal(t, "ข้อมูลแล็ปท็อปไม่ถูกต้อง", results[2].Status.GetMessage())
+	require.Len(t, results[2].Status.GetFieldViolations(), 1)
+	require.Equal(t, "laptop.price_usd", results[2].Status.GetFieldViolations()[0].GetField())
+
+	count, err := laptopStore.Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+}
+
+func TestClientCreateLaptopsBackpressure(t *testing.T) {
+	t.Parallel()
+
+	serverAddress := startTestLaptopServer(t, service.NewInMemoryLaptopStore(), nil, nil)
+	laptopClient := client.NewLaptopClient(newTestClientConn(t, serverAddress))
+
+	const total = 20
+	const window = 3
+	var read atomic.Int32
+	laptops := make(chan *pb.Laptop)
+	go func() {
+		defer close(laptops)
+		for i := 0; i < total; i++ {
+			laptops <- sample.NewLaptop()
+			read.Add(1)
+		}
+	}()
+
+	unblock := make(chan struct{})
+	created := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- laptopClient.CreateLaptops(context.Background(), laptops, window, func(result client.CreateLaptopsResult) {
+			if result.Index == 0 {
+				<-unblock
+			}
+			require.NoError(t, result.Err())
+			created++
+		})
+	}()
+
+	// while the first result is handled, only the window and the laptop the sender waits with are read
+	time.Sleep(200 * time.Millisecond)
+	require.LessOrEqual(t, int(read.Load()), window+2)
+
+	close(unblock)
+	require.NoError(t, <-done)
+	require.Equal(t, total, created)
+}
+
+func TestClientStreamsStopWhenRequestCannotBeSent(t *testing.T) {
+	t.Parallel()
+
+	serverAddress := startTestLaptopServer(t, service.NewInMemoryLaptopStore(), nil, service.NewInMemoryRatingStore())
+	laptopClient := client.NewLaptopClient(newTestClientConn(t, serverAddress))
+
+	// a string field with invalid UTF-8 cannot be marshaled
+	invalid := sample.NewLaptop()
+	invalid.Brand = "\xff"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	laptops := make(chan *pb.Laptop, 2)
+	laptops <- sample.NewLaptop()
+	laptops <- invalid
+	close(laptops)
+	err := laptopClient.CreateLaptops(ctx, laptops, 2, func(result client.CreateLaptopsResult) {})
+	require.ErrorContains(t, err, "cannot send stream request")
+
+	entries := make(chan *pb.CatalogEntry, 1)
+	entries <- &pb.CatalogEntry{Laptop: invalid}
+	close(entries)
+	err = laptopClient.ImportCatalog(ctx, entries, false, 2, func(result client.ImportCatalogResult) {})
+	require.ErrorContains(t, err, "cannot send stream request")
+	require.NoError(t, ctx.Err())
+}
+
+// newTestClientConn returns a connection to the test server
+func newTestClientConn(t *testing.T, serverAddress string) *grpc.ClientConn {
+	conn, err := grpc.Dial(serverAddress, grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
@@ -0,0 +1,102 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grpc-project/apperror"
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServerErrorDetails(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	laptop := sample.NewLaptop()
+	laptop.Id = "invalid-uuid"
+	_, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	info, badRequest, localized := testErrorDetails(t, st)
+	require.Equal(t, apperror.ReasonInvalidArgument, info.GetReason())
+	require.Equal(t, "laptop.id", badRequest.GetFieldViolations()[0].GetField())
+	require.Equal(t, st.Message(), localized.GetMessage())
+
+	laptop = sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(context.Background(), laptop))
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+
+	st = status.Convert(err)
+	require.Equal(t, codes.AlreadyExists, st.Code())
+	info, badRequest, _ = testErrorDetails(t, st)
+	require.Equal(t, apperror.ReasonLaptopAlreadyExists, info.GetReason())
+	require.Equal(t, laptop.GetId(), info.GetMetadata()["laptop_id"])
+	require.Nil(t, badRequest)
+
+	_, err = laptopClient.GetLaptop(context.Background(), &pb.GetLaptopRequest{Id: "unknown"})
+	st = status.Convert(err)
+	require.Equal(t, codes.NotFound, st.Code())
+	info, _, _ = testErrorDetails(t, st)
+	require.Equal(t, apperror.ReasonLaptopNotFound, info.GetReason())
+}
+
+func TestAuthServerIncorrectCredentials(t *testing.T) {
+	t.Parallel()
+
+	userStore := service.NewInMemoryUserStore()
+	user, err := service.NewUser("admin1", "secret", "admin")
+	require.NoError(t, err)
+	require.NoError(t, userStore.Save(context.Background(), user))
+
+	authServer := service.NewAuthServer(userStore, service.NewJWTManager("secret", time.Minute))
+
+	res, err := authServer.Login(context.Background(), &pb.LoginRequest{Username: "admin1", Password: "secret"})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.GetAccessToken())
+
+	for _, req := range []*pb.LoginRequest{
+		{Username: "admin1", Password: "wrong"},
+		{Username: "unknown", Password: "secret"},
+	} {
+		_, err = authServer.Login(context.Background(), req)
+		st := status.Convert(err)
+		require.Equal(t, codes.NotFound, st.Code())
+		info, _, localized := testErrorDetails(t, st)
+		require.Equal(t, apperror.ReasonIncorrectCredentials, info.GetReason())
+		require.Equal(t, "incorrect username/password", localized.GetMessage())
+	}
+}
+
+// testErrorDetails returns the details of an error status, the BadRequest is nil if it is missing
+func testErrorDetails(t *testing.T, st *status.Status) (*errdetails.ErrorInfo, *errdetails.BadRequest, *errdetails.LocalizedMessage) {
+	var info *errdetails.ErrorInfo
+	var badRequest *errdetails.BadRequest
+	var localized *errdetails.LocalizedMessage
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			info = detail
+		case *errdetails.BadRequest:
+			badRequest = detail
+		case *errdetails.LocalizedMessage:
+			localized = detail
+		}
+	}
+
+	require.NotNil(t, info, "error info not found")
+	require.Equal(t, apperror.Domain, info.GetDomain())
+	require.NotNil(t, localized, "localized message not found")
+	return info, badRequest, localized
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Pinger is implemented by stores that depend on a resource which can become unusable,
+// such as a folder on disk or a remote bucket
+type Pinger interface {
+	// Ping returns an error if the store cannot currently be used
+	Ping(ctx context.Context) error
+}
+
+// ReadinessCheck is a named check of a dependency the server needs to serve requests
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// StoreReadinessChecks returns a readiness check for each store that implements Pinger.
+// Stores that do not implement it are kept in memory and are always usable.
+func StoreReadinessChecks(stores map[string]interface{}) []ReadinessCheck {
+	checks := []ReadinessCheck{}
+	for name, store := range stores {
+		if pinger, ok := store.(Pinger); ok {
+			checks = append(checks, ReadinessCheck{Name: name, Check: pinger.Ping})
+		}
+	}
+	return checks
+}
+
+// HealthChecker keeps the serving status of services in a grpc health server
+// in line with the result of readiness checks
+type HealthChecker struct {
+	mutex        sync.Mutex
+	healthServer *health.Server
+	services     []string
+	checks       []ReadinessCheck
+	timeout      time.Duration
+	draining     bool
+}
+
+// NewHealthChecker returns a new health checker that reports the given services,
+// the overall server status "" is always reported as well
+func NewHealthChecker(healthServer *health.Server, services []string, checks []ReadinessCheck, timeout time.Duration) *HealthChecker {
+	return &HealthChecker{
+		healthServer: healthServer,
+		services:     append([]string{""}, services...),
+		checks:       checks,
+		timeout:      timeout,
+	}
+}
+
+// CheckOnce runs all readiness checks and updates the serving status of the services.
+// It returns the first failed check, or nil if the server is ready.
+func (checker *HealthChecker) CheckOnce(ctx context.Context) error {
+	var checkErr error
+	for _, check := range checker.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checker.timeout)
+		err := check.Check(checkCtx)
+		cancel()
+		if err != nil {
+			checkErr = fmt.Errorf("%s is not ready: %w", check.Name, err)
+			break
+		}
+	}
+
+	servingStatus := healthpb.HealthCheckResponse_SERVING
+	if checkErr != nil {
+		servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+
+	if checker.draining {
+		return checkErr
+	}
+	for _, service := range checker.services {
+		checker.healthServer.SetServingStatus(service, servingStatus)
+	}
+	return checkErr
+}
+
+// Run checks readiness every interval until the context is done or the checker is drained
+func (checker *HealthChecker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := checker.CheckOnce(ctx)
+		if err != nil {
+			slog.Warn("health: server is not ready", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if checker.isDraining() {
+			return
+		}
+	}
+}
+
+// Drain marks all services as NOT_SERVING for good, so that clients and load balancers
+// stop sending new requests while in-flight requests are being finished
+func (checker *HealthChecker) Drain() {
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+
+	checker.draining = true
+	checker.healthServer.Shutdown()
+}
+
+func (checker *HealthChecker) isDraining() bool {
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	return checker.draining
+}
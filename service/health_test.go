@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthCheckerReadiness(t *testing.T) {
+	t.Parallel()
+
+	var storeErr error
+	healthServer := health.NewServer()
+	checker := service.NewHealthChecker(
+		healthServer,
+		[]string{"techshcool.pcbook.LaptopService"},
+		[]service.ReadinessCheck{{
+			Name:  "image store",
+			Check: func(ctx context.Context) error { return storeErr },
+		}},
+		time.Second,
+	)
+
+	require.NoError(t, checker.CheckOnce(context.Background()))
+	requireServingStatus(t, healthServer, "", healthpb.HealthCheckResponse_SERVING)
+	requireServingStatus(t, healthServer, "techshcool.pcbook.LaptopService", healthpb.HealthCheckResponse_SERVING)
+
+	storeErr = errors.New("disk is full")
+	err := checker.CheckOnce(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "image store")
+	requireServingStatus(t, healthServer, "techshcool.pcbook.LaptopService", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	storeErr = nil
+	require.NoError(t, checker.CheckOnce(context.Background()))
+	requireServingStatus(t, healthServer, "techshcool.pcbook.LaptopService", healthpb.HealthCheckResponse_SERVING)
+
+	checker.Drain()
+	requireServingStatus(t, healthServer, "", healthpb.HealthCheckResponse_NOT_SERVING)
+	requireServingStatus(t, healthServer, "techshcool.pcbook.LaptopService", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	// a passing check must not bring a draining server back to SERVING
+	require.NoError(t, checker.CheckOnce(context.Background()))
+	requireServingStatus(t, healthServer, "techshcool.pcbook.LaptopService", healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+func TestStoreReadinessChecks(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := t.TempDir()
+	imageStore, _, err := service.OpenDiskImageStore(imageFolder)
+	require.NoError(t, err)
+
+	s3 := newFakeS3()
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	s3Store, err := service.NewS3ImageStore(service.S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "pcbook",
+		AccessKeyID:     "test-access-key",
+		SecretAccessKey: "test-secret-key",
+	})
+	require.NoError(t, err)
+
+	checks := service.StoreReadinessChecks(map[string]interface{}{
+		"laptop store": service.NewInMemoryLaptopStore(),
+		"image store":  imageStore,
+		"s3 store":     s3Store,
+	})
+	require.Len(t, checks, 2)
+	for _, check := range checks {
+		require.NoError(t, check.Check(context.Background()), check.Name)
+	}
+
+	entries, err := os.ReadDir(imageFolder)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	unknownBucket, err := service.NewS3ImageStore(service.S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "unknown",
+		AccessKeyID:     "test-access-key",
+		SecretAccessKey: "test-secret-key",
+	})
+	require.NoError(t, err)
+	require.Error(t, unknownBucket.Ping(context.Background()))
+
+	require.NoError(t, os.RemoveAll(imageFolder))
+	require.Error(t, imageStore.Ping(context.Background()))
+}
+
+func requireServingStatus(
+	t *testing.T,
+	healthServer *health.Server,
+	service string,
+	expected healthpb.HealthCheckResponse_ServingStatus,
+) {
+	res, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	require.Equal(t, expected, res.GetStatus(), service)
+}
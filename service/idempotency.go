@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"grpc-project/apperror"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// IdempotencyKeyKey is the metadata key of the idempotency key a client sends to retry a request safely
+const IdempotencyKeyKey = "idempotency-key"
+
+// IdempotentReplayedKey is the header the server sets when it replays the response of an earlier request
+const IdempotentReplayedKey = "idempotent-replayed"
+
+// maxIdempotencyKeyLength bounds the idempotency keys accepted from clients
+const maxIdempotencyKeyLength = 255
+
+// replayedFlagKey is the context key of a flag that replay sets, so that an outer interceptor
+// can tell that the response of the request is replayed, such as to not count an upload twice
+type replayedFlagKey struct{}
+
+// contextWithReplayedFlag returns a copy of ctx that carries a flag set when the response is replayed
+func contextWithReplayedFlag(ctx context.Context) (context.Context, *atomic.Bool) {
+	replayed := &atomic.Bool{}
+	return context.WithValue(ctx, replayedFlagKey{}, replayed), replayed
+}
+
+// SetIdempotencyStore enables idempotency keys for CreateLaptop and UploadImage,
+// the response to a key is replayed for ttl
+func (server *LaptopServer) SetIdempotencyStore(store IdempotencyStore, ttl time.Duration) {
+	server.idempotencyStore = store
+	server.idempotencyTTL = ttl
+}
+
+// idempotent calls call once per idempotency key of the caller. A retry with the same key and
+// fingerprint gets the stored response unmarshaled into res, a retry with another fingerprint
+// is rejected. Without a key, or without an idempotency store, call is always called.
+func (server *LaptopServer) idempotent(
+	ctx context.Context,
+	method string,
+	fingerprint []byte,
+	res proto.Message,
+	call func() (proto.Message, error),
+) (proto.Message, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(IdempotencyKeyKey)
+	if server.idempotencyStore == nil || len(values) == 0 || values[0] == "" {
+		return call()
+	}
+
+	key := values[0]
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, logError(ctx, apperror.InvalidArgument("idempotency key is invalid", apperror.Violation(
+			IdempotencyKeyKey, "must be at most %d characters", maxIdempotencyKeyLength,
+		)))
+	}
+
+	// keys are scoped to the caller, so that users cannot replay the responses of each other
+	caller, _ := rateLimitCaller(ctx)
+	storeKey := strings.Join([]string{caller, method, key}, "\x00")
+
+	record, err := server.idempotencyStore.Reserve(ctx, caller, storeKey, fingerprint, server.idempotencyTTL)
+	if errors.Is(err, ErrTooManyIdempotencyKeys) {
+		return nil, logError(ctx, apperror.New(
+			codes.ResourceExhausted, apperror.ReasonTooManyIdempotencyKeys,
+			"too many requests with an idempotency key are in progress, retry later",
+		).WithMetadata(IdempotencyKeyKey, key))
+	}
+	if err != nil {
+		return nil, logError(ctx, apperror.Wrap(err, "cannot reserve idempotency key"))
+	}
+	if record != nil {
+		return server.replay(ctx, key, fingerprint, record, res)
+	}
+
+	result, err := call()
+	if err != nil {
+		// the request can be retried with the same key
+		if releaseErr := server.idempotencyStore.Release(ctx, storeKey); releaseErr != nil {
+			LoggerFromContext(ctx).Error("cannot release idempotency key", "error", releaseErr)
+		}
+		return nil, err
+	}
+
+	data, err := proto.Marshal(result)
+	if err == nil {
+		// an empty response is not nil, nil means that the request is in progress
+		if data == nil {
+			data = []byte{}
+		}
+		err = server.idempotencyStore.Complete(ctx, storeKey, data)
+	}
+	if err != nil {
+		// the request succeeded, a retry will run it again
+		LoggerFromContext(ctx).Error("cannot store idempotent response", "error", err)
+		if releaseErr := server.idempotencyStore.Release(ctx, storeKey); releaseErr != nil {
+			LoggerFromContext(ctx).Error("cannot release idempotency key", "error", releaseErr)
+		}
+	}
+	return result, nil
+}
+
+// replay returns the stored response of an idempotency key
+func (server *LaptopServer) replay(
+	ctx context.Context,
+	key string,
+	fingerprint []byte,
+	record *IdempotencyRecord,
+	res proto.Message,
+) (proto.Message, error) {
+	if !bytes.Equal(record.Fingerprint, fingerprint) {
+		return nil, logError(ctx, apperror.New(
+			codes.FailedPrecondition, apperror.ReasonIdempotencyKeyReused,
+			"idempotency key %s was already used with a different request", key,
+		).WithMetadata(IdempotencyKeyKey, key))
+	}
+	if record.Response == nil {
+		return nil, logError(ctx, apperror.New(
+			codes.Aborted, apperror.ReasonIdempotencyKeyInProgress,
+			"a request with idempotency key %s is in progress, retry later", key,
+		).WithMetadata(IdempotencyKeyKey, key))
+	}
+
+	err := proto.Unmarshal(record.Response, res)
+	if err != nil {
+		return nil, logError(ctx, apperror.Internal(err, "cannot read idempotent response"))
+	}
+
+	if replayed, ok := ctx.Value(replayedFlagKey{}).(*atomic.Bool); ok {
+		replayed.Store(true)
+	}
+	err = grpc.SetHeader(ctx, metadata.Pairs(IdempotentReplayedKey, "true"))
+	if err != nil {
+		LoggerFromContext(ctx).Debug("cannot set idempotent header", "error", err)
+	}
+	LoggerFromContext(ctx).Info("replayed idempotent response", "idempotency_key", key)
+	return res, nil
+}
+
+// requestFingerprint returns the hash of the deterministic encoding of the messages of a request
+func requestFingerprint(messages ...proto.Message) []byte {
+	hash := sha256.New()
+	options := proto.MarshalOptions{Deterministic: true}
+	for _, message := range messages {
+		data, err := options.Marshal(message)
+		if err != nil {
+			// such a message cannot be received, the fingerprint only has to differ
+			data = []byte(err.Error())
+		}
+		hash.Write(data)
+	}
+	return hash.Sum(nil)
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"grpc-project/tracing"
+)
+
+// ErrTooManyIdempotencyKeys is returned by Reserve when the caller cannot reserve another key
+var ErrTooManyIdempotencyKeys = errors.New("too many idempotency keys")
+
+// IdempotencyStore is an interface to store the responses of requests sent with an idempotency key
+type IdempotencyStore interface {
+	// Reserve marks the key of the caller as in progress for a request with the given fingerprint and
+	// returns nil, or returns the record of the key if it was already reserved and has not expired
+	Reserve(ctx context.Context, caller string, key string, fingerprint []byte, ttl time.Duration) (*IdempotencyRecord, error)
+	// Complete stores the response of the request of a reserved key, the key expires ttl later
+	Complete(ctx context.Context, key string, response []byte) error
+	// Release removes a reserved key whose request failed, so that the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyRecord is the state of an idempotency key
+type IdempotencyRecord struct {
+	// Fingerprint is the hash of the first request sent with the key
+	Fingerprint []byte
+	// Response is the marshaled response of the first request, nil while it is in progress
+	Response  []byte
+	ExpiresAt time.Time
+}
+
+// InMemoryIdempotencyStore stores idempotency records in memory. Each caller keeps at most
+// maxKeysPerCaller keys: at the limit, its completed key that expires first is removed to make
+// room, and a key is rejected with ErrTooManyIdempotencyKeys if all of them are in progress.
+type InMemoryIdempotencyStore struct {
+	mutex            sync.Mutex
+	records          map[string]*idempotencyEntry
+	callerKeys       map[string]map[string]*idempotencyEntry // the records of every caller
+	maxKeysPerCaller int
+	lastCleanup      time.Time
+	now              func() time.Time
+}
+
+// idempotencyEntry is a record with the caller and the TTL given when it was reserved
+type idempotencyEntry struct {
+	record IdempotencyRecord
+	caller string
+	ttl    time.Duration
+}
+
+// idempotencyCleanupInterval is how often expired records are removed
+const idempotencyCleanupInterval = time.Minute
+
+// NewInMemoryIdempotencyStore returns a new InMemoryIdempotencyStore that keeps at most
+// maxKeysPerCaller keys of every caller, or any number of keys if it is 0
+func NewInMemoryIdempotencyStore(maxKeysPerCaller int) *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		records:          make(map[string]*idempotencyEntry),
+		callerKeys:       make(map[string]map[string]*idempotencyEntry),
+		maxKeysPerCaller: maxKeysPerCaller,
+		lastCleanup:      time.Now(),
+		now:              time.Now,
+	}
+}
+
+// Reserve marks the key of the caller as in progress for a request with the given fingerprint and
+// returns nil, or returns the record of the key if it was already reserved and has not expired
+func (store *InMemoryIdempotencyStore) Reserve(ctx context.Context, caller string, key string, fingerprint []byte, ttl time.Duration) (*IdempotencyRecord, error) {
+	_, span := tracing.Start(ctx, "InMemoryIdempotencyStore.Reserve")
+	defer span.End()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.now()
+	store.cleanup(now)
+
+	entry := store.records[key]
+	if entry != nil && now.Before(entry.record.ExpiresAt) {
+		record := entry.record
+		return &record, nil
+	}
+	if entry != nil {
+		store.delete(key)
+	}
+
+	keys := store.callerKeys[caller]
+	if store.maxKeysPerCaller > 0 && len(keys) >= store.maxKeysPerCaller {
+		oldest := ""
+		for key, entry := range keys {
+			if !now.Before(entry.record.ExpiresAt) {
+				store.delete(key)
+				continue
+			}
+			if entry.record.Response != nil && (oldest == "" || entry.record.ExpiresAt.Before(keys[oldest].record.ExpiresAt)) {
+				oldest = key
+			}
+		}
+		if len(keys) >= store.maxKeysPerCaller {
+			if oldest == "" {
+				return nil, ErrTooManyIdempotencyKeys
+			}
+			store.delete(oldest)
+		}
+	}
+
+	entry = &idempotencyEntry{
+		record: IdempotencyRecord{Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)},
+		caller: caller,
+		ttl:    ttl,
+	}
+	store.records[key] = entry
+	if store.callerKeys[caller] == nil {
+		store.callerKeys[caller] = make(map[string]*idempotencyEntry)
+	}
+	store.callerKeys[caller][key] = entry
+	return nil, nil
+}
+
+// Complete stores the response of the request of a reserved key, the key expires ttl later
+func (store *InMemoryIdempotencyStore) Complete(ctx context.Context, key string, response []byte) error {
+	_, span := tracing.Start(ctx, "InMemoryIdempotencyStore.Complete")
+	defer span.End()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	entry := store.records[key]
+	if entry == nil {
+		return ErrNotFound
+	}
+	entry.record.Response = response
+	entry.record.ExpiresAt = store.now().Add(entry.ttl)
+	return nil
+}
+
+// Release removes a reserved key whose request failed, so that the request can be retried
+func (store *InMemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	_, span := tracing.Start(ctx, "InMemoryIdempotencyStore.Release")
+	defer span.End()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.delete(key)
+	return nil
+}
+
+// delete removes the record of a key from the records and from the keys of its caller
+func (store *InMemoryIdempotencyStore) delete(key string) {
+	entry := store.records[key]
+	if entry == nil {
+		return
+	}
+
+	delete(store.records, key)
+	keys := store.callerKeys[entry.caller]
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(store.callerKeys, entry.caller)
+	}
+}
+
+// cleanup removes the expired records, at most once per idempotencyCleanupInterval
+func (store *InMemoryIdempotencyStore) cleanup(now time.Time) {
+	if now.Sub(store.lastCleanup) < idempotencyCleanupInterval {
+		return
+	}
+	store.lastCleanup = now
+
+	for key, entry := range store.records {
+		if !now.Before(entry.record.ExpiresAt) {
+			store.delete(key)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package service_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"grpc-project/client"
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestServerCreateLaptopIdempotent(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	laptopServer := service.NewLaptopServer(laptopStore, nil, nil, nil)
+	laptopServer.SetIdempotencyStore(service.NewInMemoryIdempotencyStore(0), time.Hour)
+
+	laptop := sample.NewLaptop()
+	laptop.Id = ""
+	admin := service.ContextWithUserClaims(context.Background(), &service.UserClaims{Username: "admin1", Role: "admin"})
+	withKey := func(ctx context.Context, key string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(service.IdempotencyKeyKey, key))
+	}
+	// the server receives a new copy of the request every time, and fills in the ID
+	create := func(ctx context.Context, laptop *pb.Laptop) (*pb.CreateLaptopResponse, error) {
+		return laptopServer.CreateLaptop(ctx, &pb.CreateLaptopRequest{Laptop: proto.Clone(laptop).(*pb.Laptop)})
+	}
+
+	res1, err := create(withKey(admin, "key-1"), laptop)
+	require.NoError(t, err)
+
+	// a retry gets the first response and does not create another laptop
+	res2, err := create(withKey(admin, "key-1"), laptop)
+	require.NoError(t, err)
+	require.Equal(t, res1.GetId(), res2.GetId())
+	count, err := laptopStore.Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// the same key with another request is rejected
+	other := sample.NewLaptop()
+	other.Id = ""
+	_, err = create(withKey(admin, "key-1"), other)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// keys are scoped to the user
+	admin2 := service.ContextWithUserClaims(context.Background(), &service.UserClaims{Username: "admin2", Role: "admin"})
+	res3, err := create(withKey(admin2, "key-1"), laptop)
+	require.NoError(t, err)
+	require.NotEqual(t, res1.GetId(), res3.GetId())
+
+	// without a key, every request creates a laptop
+	res4, err := create(admin, laptop)
+	require.NoError(t, err)
+	res5, err := create(admin, laptop)
+	require.NoError(t, err)
+	require.NotEqual(t, res4.GetId(), res5.GetId())
+
+	// failed requests are not stored, so that they can be fixed and retried with the same key
+	invalid := proto.Clone(laptop).(*pb.Laptop)
+	invalid.PriceUsd = -1
+	_, err = create(withKey(admin, "key-2"), invalid)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = create(withKey(admin, "key-2"), laptop)
+	require.NoError(t, err)
+}
+
+func TestClientUploadImageIdempotent(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	imageStore := service.NewDiskImageStore(t.TempDir())
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(context.Background(), laptop))
+
+	laptopServer := service.NewLaptopServer(laptopStore, imageStore, nil, nil)
+	laptopServer.SetIdempotencyStore(service.NewInMemoryIdempotencyStore(0), time.Hour)
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	laptopClient := newTestLaptopClient(t, listener.Addr().String())
+	upload := func(ctx context.Context, data []byte) (*pb.UploadImageResponse, metadata.MD, error) {
+		stream, err := laptopClient.UploadImage(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.UploadImageRequest{
+			Data: &pb.UploadImageRequest_Info{Info: &pb.ImageInfo{LaptopId: laptop.GetId(), ImageType: ".jpg"}},
+		}))
+		// the chunks are not the same in every attempt, only the image data matters
+		for len(data) > 0 {
+			n := min(len(data), 3)
+			require.NoError(t, stream.Send(&pb.UploadImageRequest{
+				Data: &pb.UploadImageRequest_ChunkData{ChunkData: data[:n]},
+			}))
+			data = data[n:]
+		}
+		res, err := stream.CloseAndRecv()
+		header, _ := stream.Header()
+		return res, header, err
+	}
+
+	ctx := client.WithIdempotencyKey(context.Background(), "upload-1")
+	res1, header, err := upload(ctx, []byte("laptop image"))
+	require.NoError(t, err)
+	require.Empty(t, header.Get(service.IdempotentReplayedKey))
+
+	res2, header, err := upload(ctx, []byte("laptop image"))
+	require.NoError(t, err)
+	require.Equal(t, res1.GetId(), res2.GetId())
+	require.Equal(t, []string{"true"}, header.Get(service.IdempotentReplayedKey))
+	count, err := imageStore.Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	_, _, err = upload(ctx, []byte("another image"))
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestInMemoryIdempotencyStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := service.NewInMemoryIdempotencyStore(0)
+
+	record, err := store.Reserve(ctx, "user1", "key", []byte("request"), 50*time.Millisecond)
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	// the first request is in progress
+	record, err = store.Reserve(ctx, "user1", "key", []byte("request"), 50*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, []byte("request"), record.Fingerprint)
+	require.Nil(t, record.Response)
+
+	require.NoError(t, store.Complete(ctx, "key", []byte("response")))
+	record, err = store.Reserve(ctx, "user1", "key", []byte("other request"), 50*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, []byte("request"), record.Fingerprint)
+	require.Equal(t, []byte("response"), record.Response)
+
+	// an expired key can be reserved again
+	time.Sleep(60 * time.Millisecond)
+	record, err = store.Reserve(ctx, "user1", "key", []byte("other request"), time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	require.NoError(t, store.Release(ctx, "key"))
+	record, err = store.Reserve(ctx, "user1", "key", []byte("request"), time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	require.ErrorIs(t, store.Complete(ctx, "missing", []byte("response")), service.ErrNotFound)
+}
+
+func TestInMemoryIdempotencyStoreMaxKeysPerCaller(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := service.NewInMemoryIdempotencyStore(2)
+
+	_, err := store.Reserve(ctx, "user1", "key1", []byte("request"), time.Minute)
+	require.NoError(t, err)
+	_, err = store.Reserve(ctx, "user1", "key2", []byte("request"), 2*time.Minute)
+	require.NoError(t, err)
+
+	// every key of the caller is in progress
+	_, err = store.Reserve(ctx, "user1", "key3", []byte("request"), time.Minute)
+	require.ErrorIs(t, err, service.ErrTooManyIdempotencyKeys)
+
+	// other callers are not limited by user1
+	record, err := store.Reserve(ctx, "user2", "key4", []byte("request"), time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	// the completed key that expires first makes room for a new key
+	require.NoError(t, store.Complete(ctx, "key2", []byte("response")))
+	require.NoError(t, store.Complete(ctx, "key1", []byte("response")))
+	record, err = store.Reserve(ctx, "user1", "key3", []byte("request"), time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	record, err = store.Reserve(ctx, "user1", "key2", []byte("other request"), time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []byte("response"), record.Response)
+
+	// a released key makes room too, and key1 was removed so it is reserved again
+	require.NoError(t, store.Release(ctx, "key3"))
+	record, err = store.Reserve(ctx, "user1", "key1", []byte("request"), time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, record)
+}
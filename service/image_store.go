@@ -2,23 +2,46 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
+	"grpc-project/tracing"
+
 	"github.com/google/uuid"
 )
 
+const (
+	// imageManifestFile is the sidecar file that keeps the metadata of the images in the image folder
+	imageManifestFile = "images.json"
+	// imageQuarantineFolder is the sub folder where unknown files of the image folder are moved to
+	imageQuarantineFolder = "quarantine"
+)
+
 // ImageStore is an interface to store laptop images
 type ImageStore interface {
 	// Save saves a new laptop image to the store
-	Save(laptopID string, imageType string, imageData bytes.Buffer) (string, error)
+	Save(ctx context.Context, laptopID string, imageType string, imageData bytes.Buffer) (string, error)
+	// ListByLaptop returns the IDs of the images of a laptop
+	ListByLaptop(ctx context.Context, laptopID string) ([]string, error)
+	// DeleteByLaptop deletes all images of a laptop and returns how many were deleted
+	DeleteByLaptop(ctx context.Context, laptopID string) (int, error)
+	// Count returns the number of images in the store
+	Count(ctx context.Context) (int, error)
 }
 
 type DiskImageStore struct {
-	mutex       sync.RWMutex
-	imageFolder string
-	images      map[string]*ImageInfo
+	mutex        sync.RWMutex
+	imageFolder  string
+	manifestPath string
+	images       map[string]*ImageInfo
 }
 
 type ImageInfo struct {
@@ -27,6 +50,26 @@ type ImageInfo struct {
 	Path     string
 }
 
+// ReconcileReport describes how the image manifest was matched with the image folder on startup
+type ReconcileReport struct {
+	Adopted     []string // image IDs found both in the manifest and in the folder
+	Missing     []string // image IDs in the manifest whose file is gone
+	Quarantined []string // image files in the folder that are not in the manifest
+	Ignored     []string // files in the folder that are not named like images of the store
+}
+
+// imageManifest is the on-disk format of the image manifest
+type imageManifest struct {
+	Images map[string]imageManifestEntry `json:"images"`
+}
+
+type imageManifestEntry struct {
+	LaptopID string `json:"laptop_id"`
+	Type     string `json:"type"`
+	File     string `json:"file"`
+}
+
+// NewDiskImageStore returns a disk image store that keeps image metadata in memory only
 func NewDiskImageStore(imageFolder string) *DiskImageStore {
 	return &DiskImageStore{
 		imageFolder: imageFolder,
@@ -34,11 +77,41 @@ func NewDiskImageStore(imageFolder string) *DiskImageStore {
 	}
 }
 
+// OpenDiskImageStore returns a disk image store that persists image metadata in a manifest file
+// inside the image folder. The manifest is reconciled with the folder content before it is returned.
+func OpenDiskImageStore(imageFolder string) (*DiskImageStore, *ReconcileReport, error) {
+	store := NewDiskImageStore(imageFolder)
+	store.manifestPath = filepath.Join(imageFolder, imageManifestFile)
+
+	err := os.MkdirAll(imageFolder, 0755)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create image folder: %w", err)
+	}
+
+	found, err := store.loadManifest()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// without a manifest the folder comes from a version that kept the metadata in memory,
+	// so its images are adopted instead of quarantined
+	report, err := store.reconcile(!found)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return store, report, nil
+}
+
 func (store *DiskImageStore) Save(
+	ctx context.Context,
 	laptopID string,
 	imageType string,
 	imageData bytes.Buffer,
 ) (string, error) {
+	_, span := tracing.Start(ctx, "DiskImageStore.Save", tracing.WithAttributes(tracing.String("laptop.id", laptopID)))
+	defer span.End()
+
 	imageID, err := uuid.NewRandom()
 	if err != nil {
 		return "", fmt.Errorf("cannot generate image id: %w", err)
@@ -46,12 +119,7 @@ func (store *DiskImageStore) Save(
 
 	imagePath := fmt.Sprintf("%s/%s%s", store.imageFolder, imageID, imageType)
 
-	file, err := os.Create(imagePath)
-	if err != nil {
-		return "", fmt.Errorf("cannot create image file: %w", err)
-	}
-
-	_, err = imageData.WriteTo(file)
+	err = writeFileAtomic(imagePath, imageData.Bytes())
 	if err != nil {
 		return "", fmt.Errorf("cannot write image to file: %w", err)
 	}
@@ -65,5 +133,302 @@ func (store *DiskImageStore) Save(
 		Path:     imagePath,
 	}
 
+	err = store.saveManifest()
+	if err != nil {
+		delete(store.images, imageID.String())
+		os.Remove(imagePath)
+		return "", err
+	}
+
 	return imageID.String(), nil
 }
+
+// Find returns the information of an image, or nil if it is not in the store
+func (store *DiskImageStore) Find(imageID string) (*ImageInfo, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	image := store.images[imageID]
+	if image == nil {
+		return nil, nil
+	}
+
+	other := *image
+	return &other, nil
+}
+
+// ListByLaptop returns the IDs of the images of a laptop
+func (store *DiskImageStore) ListByLaptop(ctx context.Context, laptopID string) ([]string, error) {
+	_, span := tracing.Start(ctx, "DiskImageStore.ListByLaptop", tracing.WithAttributes(tracing.String("laptop.id", laptopID)))
+	defer span.End()
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return imageIDsOfLaptop(store.images, laptopID), nil
+}
+
+// DeleteByLaptop deletes all images of a laptop. The metadata is removed first, so a file
+// that cannot be removed is only left behind as an unknown file for the next reconciliation.
+func (store *DiskImageStore) DeleteByLaptop(ctx context.Context, laptopID string) (int, error) {
+	ctx, span := tracing.Start(ctx, "DiskImageStore.DeleteByLaptop", tracing.WithAttributes(tracing.String("laptop.id", laptopID)))
+	defer span.End()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	removed := make(map[string]*ImageInfo)
+	for _, imageID := range imageIDsOfLaptop(store.images, laptopID) {
+		removed[imageID] = store.images[imageID]
+		delete(store.images, imageID)
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+
+	err := store.saveManifest()
+	if err != nil {
+		for imageID, image := range removed {
+			store.images[imageID] = image
+		}
+		return 0, err
+	}
+
+	for imageID, image := range removed {
+		err := os.Remove(image.Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			LoggerFromContext(ctx).Warn("image store: cannot remove image file", "image_id", imageID, "error", err)
+		}
+	}
+	return len(removed), nil
+}
+
+// Count returns the number of images in the store
+func (store *DiskImageStore) Count(ctx context.Context) (int, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return len(store.images), nil
+}
+
+// Ping checks that a new image can be written to the image folder
+func (store *DiskImageStore) Ping(ctx context.Context) error {
+	tmp, err := os.CreateTemp(store.imageFolder, ".ping.*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot write to image folder: %w", err)
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+// loadManifest loads the manifest of the store and tells whether it exists
+func (store *DiskImageStore) loadManifest() (bool, error) {
+	data, err := os.ReadFile(store.manifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot read image manifest: %w", err)
+	}
+
+	manifest := imageManifest{}
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return false, fmt.Errorf("cannot parse image manifest: %w", err)
+	}
+
+	for imageID, entry := range manifest.Images {
+		store.images[imageID] = &ImageInfo{
+			LaptopID: entry.LaptopID,
+			Type:     entry.Type,
+			Path:     filepath.Join(store.imageFolder, entry.File),
+		}
+	}
+	return true, nil
+}
+
+// saveManifest writes the manifest of the store, the caller must hold the write lock
+func (store *DiskImageStore) saveManifest() error {
+	if store.manifestPath == "" {
+		return nil
+	}
+
+	manifest := imageManifest{Images: make(map[string]imageManifestEntry, len(store.images))}
+	for imageID, image := range store.images {
+		manifest.Images[imageID] = imageManifestEntry{
+			LaptopID: image.LaptopID,
+			Type:     image.Type,
+			File:     filepath.Base(image.Path),
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal image manifest: %w", err)
+	}
+
+	err = writeFileAtomic(store.manifestPath, data)
+	if err != nil {
+		return fmt.Errorf("cannot write image manifest: %w", err)
+	}
+	return nil
+}
+
+// reconcile matches the loaded manifest with the files in the image folder:
+// files in the manifest are adopted, manifest entries without a file are dropped,
+// and image files that are not in the manifest are moved to the quarantine folder,
+// or adopted with an unknown laptop if adoptUnknown is true. Files that are not named
+// like the images of the store, <image ID><type>, are left in place.
+func (store *DiskImageStore) reconcile(adoptUnknown bool) (*ReconcileReport, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	entries, err := os.ReadDir(store.imageFolder)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read image folder: %w", err)
+	}
+
+	known := make(map[string]string, len(store.images))
+	for imageID, image := range store.images {
+		known[filepath.Base(image.Path)] = imageID
+	}
+
+	report := &ReconcileReport{}
+	found := make(map[string]bool)
+	changed := false
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == imageManifestFile {
+			continue
+		}
+
+		if imageID, ok := known[name]; ok {
+			found[imageID] = true
+			report.Adopted = append(report.Adopted, imageID)
+			continue
+		}
+
+		imageID, ok := imageIDOfFile(name)
+		if !ok {
+			report.Ignored = append(report.Ignored, name)
+			slog.Info("image store: ignored file that is not an image of the store", "folder", store.imageFolder, "file", name)
+			continue
+		}
+
+		if adoptUnknown && store.images[imageID] == nil {
+			store.images[imageID] = &ImageInfo{
+				Type: filepath.Ext(name),
+				Path: filepath.Join(store.imageFolder, name),
+			}
+			found[imageID] = true
+			changed = true
+			report.Adopted = append(report.Adopted, imageID)
+			slog.Warn("image store: adopted image without manifest entry", "image_id", imageID, "file", name)
+			continue
+		}
+
+		err := store.quarantine(name)
+		if err != nil {
+			return nil, err
+		}
+		report.Quarantined = append(report.Quarantined, name)
+		slog.Warn("image store: quarantined unknown file", "folder", store.imageFolder, "file", name)
+	}
+
+	for imageID, image := range store.images {
+		if !found[imageID] {
+			delete(store.images, imageID)
+			changed = true
+			report.Missing = append(report.Missing, imageID)
+			slog.Warn("image store: image file is missing", "image_id", imageID, "file", filepath.Base(image.Path))
+		}
+	}
+
+	sort.Strings(report.Adopted)
+	sort.Strings(report.Missing)
+	sort.Strings(report.Quarantined)
+	sort.Strings(report.Ignored)
+
+	if changed {
+		err = store.saveManifest()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info(
+		"image store: reconciled image folder",
+		"folder", store.imageFolder,
+		"adopted", len(report.Adopted),
+		"missing", len(report.Missing),
+		"quarantined", len(report.Quarantined),
+		"ignored", len(report.Ignored),
+	)
+	return report, nil
+}
+
+func (store *DiskImageStore) quarantine(name string) error {
+	folder := filepath.Join(store.imageFolder, imageQuarantineFolder)
+	err := os.MkdirAll(folder, 0755)
+	if err != nil {
+		return fmt.Errorf("cannot create quarantine folder: %w", err)
+	}
+
+	err = os.Rename(filepath.Join(store.imageFolder, name), filepath.Join(folder, name))
+	if err != nil {
+		return fmt.Errorf("cannot quarantine file %s: %w", name, err)
+	}
+	return nil
+}
+
+// imageIDOfFile returns the image ID of a file named like the images of the store
+func imageIDOfFile(name string) (string, bool) {
+	imageID := strings.TrimSuffix(name, filepath.Ext(name))
+	id, err := uuid.Parse(imageID)
+	if err != nil || id.String() != imageID {
+		return "", false
+	}
+	return imageID, true
+}
+
+func imageIDsOfLaptop(images map[string]*ImageInfo, laptopID string) []string {
+	imageIDs := []string{}
+	for imageID, image := range images {
+		if image.LaptopID == laptopID {
+			imageIDs = append(imageIDs, imageID)
+		}
+	}
+	sort.Strings(imageIDs)
+	return imageIDs
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to filename,
+// so a crash never leaves a half-written file behind under the final name
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Chmod(0644)
+	if err == nil {
+		_, err = tmp.Write(data)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), filename)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
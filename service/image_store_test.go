@@ -0,0 +1,113 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskImageStorePersistsManifest(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+
+	store, report, err := service.OpenDiskImageStore(folder)
+	require.NoError(t, err)
+	require.Empty(t, report.Adopted)
+
+	imageID, err := store.Save(context.Background(), "laptop-id", ".jpg", *bytes.NewBufferString("image data"))
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(folder, "images.json"))
+
+	// simulate a restart
+	reopened, report, err := service.OpenDiskImageStore(folder)
+	require.NoError(t, err)
+	require.Equal(t, []string{imageID}, report.Adopted)
+	require.Empty(t, report.Missing)
+	require.Empty(t, report.Quarantined)
+
+	image, err := reopened.Find(imageID)
+	require.NoError(t, err)
+	require.NotNil(t, image)
+	require.Equal(t, "laptop-id", image.LaptopID)
+	require.Equal(t, ".jpg", image.Type)
+	require.Equal(t, filepath.Join(folder, imageID+".jpg"), image.Path)
+}
+
+func TestDiskImageStoreReconcile(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+
+	store, _, err := service.OpenDiskImageStore(folder)
+	require.NoError(t, err)
+
+	keptID, err := store.Save(context.Background(), "laptop-1", ".jpg", *bytes.NewBufferString("kept"))
+	require.NoError(t, err)
+	removedID, err := store.Save(context.Background(), "laptop-2", ".png", *bytes.NewBufferString("removed"))
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(filepath.Join(folder, removedID+".png")))
+	unknownFile := "0b5b5f39-5c56-4e42-9c4c-6a0f1d2b7c11.jpg"
+	require.NoError(t, os.WriteFile(filepath.Join(folder, unknownFile), []byte("unknown"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(folder, "laptop.jpg"), []byte("other"), 0644))
+
+	reopened, report, err := service.OpenDiskImageStore(folder)
+	require.NoError(t, err)
+	require.Equal(t, []string{keptID}, report.Adopted)
+	require.Equal(t, []string{removedID}, report.Missing)
+	require.Equal(t, []string{unknownFile}, report.Quarantined)
+	require.Equal(t, []string{"laptop.jpg"}, report.Ignored)
+
+	require.NoFileExists(t, filepath.Join(folder, unknownFile))
+	require.FileExists(t, filepath.Join(folder, "quarantine", unknownFile))
+	require.FileExists(t, filepath.Join(folder, "laptop.jpg"))
+
+	image, err := reopened.Find(removedID)
+	require.NoError(t, err)
+	require.Nil(t, image)
+
+	// the missing image is dropped from the manifest as well
+	_, report, err = service.OpenDiskImageStore(folder)
+	require.NoError(t, err)
+	require.Equal(t, []string{keptID}, report.Adopted)
+	require.Empty(t, report.Missing)
+	require.Empty(t, report.Quarantined)
+}
+
+func TestDiskImageStoreAdoptsImagesWithoutManifest(t *testing.T) {
+	t.Parallel()
+
+	// a folder written before the manifest existed
+	folder := t.TempDir()
+	imageID := "19caf862-701c-4861-b186-283a275980d9"
+	require.NoError(t, os.WriteFile(filepath.Join(folder, imageID+".jpg"), []byte("image"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(folder, "laptop.jpg"), []byte("other"), 0644))
+
+	store, report, err := service.OpenDiskImageStore(folder)
+	require.NoError(t, err)
+	require.Equal(t, []string{imageID}, report.Adopted)
+	require.Empty(t, report.Quarantined)
+	require.Equal(t, []string{"laptop.jpg"}, report.Ignored)
+	require.FileExists(t, filepath.Join(folder, imageID+".jpg"))
+	require.FileExists(t, filepath.Join(folder, "laptop.jpg"))
+	require.FileExists(t, filepath.Join(folder, "images.json"))
+
+	image, err := store.Find(imageID)
+	require.NoError(t, err)
+	require.Equal(t, "", image.LaptopID)
+	require.Equal(t, ".jpg", image.Type)
+
+	// the adopted image is in the manifest, and nothing is quarantined after a restart
+	_, report, err = service.OpenDiskImageStore(folder)
+	require.NoError(t, err)
+	require.Equal(t, []string{imageID}, report.Adopted)
+	require.Empty(t, report.Quarantined)
+	require.Equal(t, []string{"laptop.jpg"}, report.Ignored)
+}
@@ -9,14 +9,19 @@ import (
 	"grpc-project/serializer"
 	"grpc-project/service"
 	"io"
+	"math"
 	"net"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // ทดสอบการสร้างแล็ปท็อปโดยใช้ client
@@ -36,7 +41,7 @@ func TestClientCreateLaptop(t *testing.T) {
 	require.NotNil(t, res) // ตรวจสอบว่าผลลัพธ์ไม่เป็น nil
 	require.Equal(t, expectedID, res.Id) // ตรวจสอบว่า ID ที่สร้างตรงกับที่คาดหวัง
 
-	other, err := laptopStore.Find(res.Id) // ค้นหาแล็ปท็อปใน store ตาม ID
+	other, err := laptopStore.Find(context.Background(), res.Id) // ค้นหาแล็ปท็อปใน store ตาม ID
 	require.NoError(t, err) // ตรวจสอบว่าการค้นหาสำเร็จ
 	require.NotNil(t, other) // ตรวจสอบว่าผลลัพธ์ไม่เป็น nil
 
@@ -67,7 +72,7 @@ func TestClientSearchLaptop(t *testing.T) {
 		case 2:
 			laptop.Cpu.MinGhz = 2.0
 		case 3:
-			laptop.Ram = &pb.Memory{Value: 4096, Unit: pb.Memory_GIGABYTE}
+			laptop.Ram = &pb.Memory{Value: 4096, Unit: pb.Memory_MEGABYTE}
 		case 4:
 			laptop.PriceUsd = 1999
 			laptop.Cpu.NumberCores = 4
@@ -83,7 +88,7 @@ func TestClientSearchLaptop(t *testing.T) {
 			laptop.Ram = &pb.Memory{Value: 64, Unit: pb.Memory_GIGABYTE}
 			expectedIDs[laptop.Id] = true // เก็บ ID ของแล็ปท็อปที่ตรงตามเงื่อนไข
 		}
-		err := laptopStore.Save(laptop) // บันทึกแล็ปท็อปใน store
+		err := laptopStore.Save(context.Background(), laptop) // บันทึกแล็ปท็อปใน store
 		require.NoError(t, err) // ตรวจสอบว่าการบันทึกสำเร็จ
 	}
 	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil) // เริ่มเซิร์ฟเวอร์ทดสอบ
@@ -116,7 +121,7 @@ func TestClientUploadImage(t *testing.T) {
 	imageStore := service.NewDiskImageStore(testImageFolder) // สร้าง store สำหรับเก็บภาพในดิสก์
 
 	laptop := sample.NewLaptop() // สร้างแล็ปท็อปใหม่
-	err := laptopStore.Save(laptop) // บันทึกแล็ปท็อปใน store
+	err := laptopStore.Save(context.Background(), laptop) // บันทึกแล็ปท็อปใน store
 	require.NoError(t, err) // ตรวจสอบว่าการบันทึกสำเร็จ
 
 	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil) // เริ่มเซิร์ฟเวอร์ทดสอบ
@@ -184,48 +189,102 @@ func TestClientRateLaptop(t *testing.T) {
 	ratingStore := service.NewInMemoryRatingStore() // สร้าง store ในหน่วยความจำสำหรับเก็บคะแนน
 
 	laptop := sample.NewLaptop() // สร้างแล็ปท็อปใหม่
-	err := laptopStore.Save(laptop) // บันทึกแล็ปท็อปใน store
+	err := laptopStore.Save(context.Background(), laptop) // บันทึกแล็ปท็อปใน store
 	require.NoError(t, err) // ตรวจสอบว่าการบันทึกสำเร็จ
 
-	serverAddress := startTestLaptopServer(t, laptopStore, nil, ratingStore) // เริ่มเซิร์ฟเวอร์ทดสอบ
+	jwtManager := service.NewJWTManager("secret", time.Minute)
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, ratingStore, testAuthServerOptions(jwtManager)...) // เริ่มเซิร์ฟเวอร์ทดสอบ
 	laptopClient := newTestLaptopClient(t, serverAddress) // สร้าง client สำหรับทดสอบ
 
-	stream, err := laptopClient.RateLaptop(context.Background()) // สร้าง stream สำหรับให้คะแนนแล็ปท็อป
-	require.NoError(t, err) // ตรวจสอบว่าการสร้าง stream สำเร็จ
-
+	// user1 ให้คะแนนซ้ำ คะแนนใหม่จะแทนที่คะแนนเดิม ส่วน user2 ให้คะแนนเพิ่มอีกหนึ่งคน
+	usernames := []string{"user1", "user1", "user2"}
 	scores := []float64{8, 7.5, 10} // คะแนนที่ต้องการให้
-	averages := []float64{8, 7.75, 8.5} // คะแนนเฉลี่ยที่คาดหวัง
-	n := len(scores)
-	for i := 0; i < n; i++ {
+	counts := []uint32{1, 1, 2} // จำนวนผู้ใช้ที่ให้คะแนนที่คาดหวัง
+	averages := []float64{8, 7.5, 8.75} // คะแนนเฉลี่ยที่คาดหวัง
+
+	for i := range scores {
+		ctx := testContextWithToken(t, jwtManager, usernames[i])
+		stream, err := laptopClient.RateLaptop(ctx) // สร้าง stream สำหรับให้คะแนนแล็ปท็อป
+		require.NoError(t, err) // ตรวจสอบว่าการสร้าง stream สำเร็จ
+
 		req := &pb.RateLaptopRequest{
 			LaptopId: laptop.GetId(),
 			Score:    scores[i],
+			Review:   fmt.Sprintf("review %d", i),
 		}
-		err := stream.Send(req) // ส่งคะแนนให้เซิร์ฟเวอร์
+		err = stream.Send(req) // ส่งคะแนนให้เซิร์ฟเวอร์
 		require.NoError(t, err) // ตรวจสอบว่าการส่งคะแนนสำเร็จ
-	}
 
-	err = stream.CloseSend() // ปิด stream การส่งคะแนน
-	require.NoError(t, err) // ตรวจสอบว่าการปิด stream สำเร็จ
-	
-	for idx := 0; ; idx++ {
 		res, err := stream.Recv() // รับการตอบสนองจากเซิร์ฟเวอร์
-		if err == io.EOF {
-			require.Equal(t, n, idx) // ตรวจสอบว่าจำนวนคะแนนที่ได้รับตรงกับที่คาดหวัง
-			return
-		}
 		require.NoError(t, err) // ตรวจสอบว่าไม่มีข้อผิดพลาด
 		require.Equal(t, laptop.GetId(), res.GetLaptopId()) // ตรวจสอบ ID ของแล็ปท็อป
-		require.Equal(t, uint32(idx+1), res.GetRatedCount()) // ตรวจสอบจำนวนคะแนนที่ได้รับ
-		require.Equal(t, averages[idx], res.GetAverageScore()) // ตรวจสอบคะแนนเฉลี่ยที่คาดหวัง
+		require.Equal(t, counts[i], res.GetRatedCount()) // ตรวจสอบจำนวนผู้ใช้ที่ให้คะแนน
+		require.Equal(t, averages[i], res.GetAverageScore()) // ตรวจสอบคะแนนเฉลี่ยที่คาดหวัง
+
+		err = stream.CloseSend() // ปิด stream การส่งคะแนน
+		require.NoError(t, err) // ตรวจสอบว่าการปิด stream สำเร็จ
+		_, err = stream.Recv()
+		require.Equal(t, io.EOF, err)
+	}
+
+	// คะแนนที่อยู่นอกช่วง 1 ถึง 10 หรือไม่ใช่ตัวเลขจะถูกปฏิเสธ
+	for _, score := range []float64{11, math.NaN()} {
+		stream, err := laptopClient.RateLaptop(testContextWithToken(t, jwtManager, "user3"))
+		require.NoError(t, err)
+		err = stream.Send(&pb.RateLaptopRequest{LaptopId: laptop.GetId(), Score: score})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Equal(t, codes.InvalidArgument, status.Code(err), "%v", score)
 	}
+
+	// การให้คะแนนโดยไม่มี access token จะถูกปฏิเสธ
+	stream, err := laptopClient.RateLaptop(context.Background())
+	require.NoError(t, err)
+	err = stream.Send(&pb.RateLaptopRequest{LaptopId: laptop.GetId(), Score: 5})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// ดึงคะแนนรวม histogram และ review แบบแบ่งหน้า
+	res, err := laptopClient.GetLaptopRatings(context.Background(), &pb.GetLaptopRatingsRequest{
+		LaptopId: laptop.GetId(),
+		PageSize: 1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), res.GetRatedCount())
+	require.Equal(t, 8.75, res.GetAverageScore())
+	require.Equal(t, uint32(1), res.GetHistogram()[7])
+	require.Equal(t, uint32(1), res.GetHistogram()[10])
+	require.Equal(t, uint32(0), res.GetHistogram()[8])
+	require.Len(t, res.GetReviews(), 1)
+	require.Equal(t, "user2", res.GetReviews()[0].GetUsername())
+	require.Equal(t, "review 2", res.GetReviews()[0].GetReview())
+	require.NotEmpty(t, res.GetNextPageToken())
+
+	res, err = laptopClient.GetLaptopRatings(context.Background(), &pb.GetLaptopRatingsRequest{
+		LaptopId:  laptop.GetId(),
+		PageSize:  1,
+		PageToken: res.GetNextPageToken(),
+	})
+	require.NoError(t, err)
+	require.Len(t, res.GetReviews(), 1)
+	require.Equal(t, "user1", res.GetReviews()[0].GetUsername())
+	require.Equal(t, 7.5, res.GetReviews()[0].GetScore())
+	require.Equal(t, "review 1", res.GetReviews()[0].GetReview())
+	require.Empty(t, res.GetNextPageToken())
 }
 
 // ฟังก์ชันสำหรับเริ่มเซิร์ฟเวอร์ทดสอบ
-func startTestLaptopServer(t *testing.T, laptopStore service.LaptopStore, imageStore service.ImageStore, ratingStore service.RatingStore) string {
-	laptopServer := service.NewLaptopServer(laptopStore, imageStore, ratingStore) // สร้างเซิร์ฟเวอร์แล็ปท็อป
-
-	grpcServer := grpc.NewServer() // สร้าง gRPC server
+func startTestLaptopServer(
+	t *testing.T,
+	laptopStore service.LaptopStore,
+	imageStore service.ImageStore,
+	ratingStore service.RatingStore,
+	opts ...grpc.ServerOption,
+) string {
+	laptopServer := service.NewLaptopServer(laptopStore, imageStore, ratingStore, nil) // สร้างเซิร์ฟเวอร์แล็ปท็อป
+
+	grpcServer := grpc.NewServer(opts...) // สร้าง gRPC server
 	pb.RegisterLaptopServiceServer(grpcServer, laptopServer) // ลงทะเบียนบริการแล็ปท็อปกับ gRPC server
 
 	listener, err := net.Listen("tcp", ":0") // ฟังบนพอร์ตที่ว่าง
@@ -235,13 +294,37 @@ func startTestLaptopServer(t *testing.T, laptopStore service.LaptopStore, imageS
 
 	go func() {
 		if err := grpcServer.Serve(listener); err != nil { // เริ่มเซิร์ฟเวอร์
-			t.Fatalf("Failed to serve gRPC server: %v", err)
+			t.Errorf("Failed to serve gRPC server: %v", err)
 		}
 	}()
 
 	return listener.Addr().String() // คืนค่า address ของเซิร์ฟเวอร์
 }
 
+// testAuthServerOptions คืนค่า server option ที่ใช้ auth interceptor สำหรับ RPC ที่ต้องยืนยันตัวตน
+func testAuthServerOptions(jwtManager *service.JWTManager) []grpc.ServerOption {
+	const laptopServicePath = "/techshcool.pcbook.LaptopService/"
+
+	interceptor := service.NewAuthInterceptor(jwtManager, map[string][]string{
+		laptopServicePath + "RateLaptop": {"admin", "user"},
+	})
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(interceptor.Unary()),
+		grpc.StreamInterceptor(interceptor.Stream()),
+	}
+}
+
+// testContextWithToken คืนค่า context ที่แนบ access token ของผู้ใช้ไว้ใน metadata
+func testContextWithToken(t *testing.T, jwtManager *service.JWTManager, username string) context.Context {
+	user, err := service.NewUser(username, "secret", "user")
+	require.NoError(t, err)
+
+	token, err := jwtManager.Generate(user)
+	require.NoError(t, err)
+
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", token)
+}
+
 // ฟังก์ชันสำหรับสร้าง client ทดสอบ
 func newTestLaptopClient(t *testing.T, serverAddress string) pb.LaptopServiceClient {
 	conn, err := grpc.Dial(serverAddress, grpc.WithInsecure()) // สร้างการเชื่อมต่อกับเซิร์ฟเวอร์
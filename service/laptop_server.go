@@ -4,74 +4,115 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"grpc-project/apperror"
 	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/validation"
 	"io"
-	"log"
+	"sort"
+	"strconv"
+	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/google/uuid"
 )
 
-const maxImageSize = 1 << 20 // ขนาดสูงสุดของภาพที่สามารถอัปโหลดได้ (1MB)
+const DefaultMaxImageSize = 1 << 20 // ขนาดสูงสุดของภาพที่สามารถอัปโหลดได้โดยค่าเริ่มต้น (1MB)
+
+const (
+	defaultReviewPageSize = 10  // จำนวน review ต่อหน้าเมื่อไม่ได้ระบุ page_size
+	maxReviewPageSize     = 100 // จำนวน review สูงสุดต่อหน้า
+)
 
 // LaptopServer เป็นการ implement เมธอดของ pb.LaptopServiceServer
 type LaptopServer struct {
-	pb.UnimplementedLaptopServiceServer             // ฝัง struct ที่ไม่ได้ implement เพื่อไม่ต้อง implement ทุกเมธอด
-	laptopStore                         LaptopStore // ตัวแปรสำหรับเก็บข้อมูลแล็ปท็อป
-	imageStore                          ImageStore  // ตัวแปรสำหรับเก็บข้อมูลภาพ
-	ratingStore                         RatingStore // ตัวแปรสำหรับเก็บข้อมูลการจัดอันดับ
+	pb.UnimplementedLaptopServiceServer                  // ฝัง struct ที่ไม่ได้ implement เพื่อไม่ต้อง implement ทุกเมธอด
+	laptopStore                         LaptopStore      // ตัวแปรสำหรับเก็บข้อมูลแล็ปท็อป
+	imageStore                          ImageStore       // ตัวแปรสำหรับเก็บข้อมูลภาพ
+	ratingStore                         RatingStore      // ตัวแปรสำหรับเก็บข้อมูลการจัดอันดับ
+	ranker                              *Ranker          // ตัวคำนวณคะแนนสำหรับจัดอันดับแล็ปท็อป
+	catalog                             *Catalog         // ตัวประสานงานระหว่าง stores เพื่อไม่ให้มีภาพหรือคะแนนของแล็ปท็อปที่ถูกลบ
+	maxImageSize                        int              // ขนาดสูงสุดของภาพที่สามารถอัปโหลดได้
+	idempotencyStore                    IdempotencyStore // เก็บการตอบสนองของคำขอที่มี idempotency key, nil เมื่อปิดใช้งาน
+	idempotencyTTL                      time.Duration    // ระยะเวลาที่เก็บการตอบสนองของแต่ละ key
 }
 
 // NewLaptopServer สร้าง instance ใหม่ของ LaptopServer
-func NewLaptopServer(store LaptopStore, image ImageStore, rating RatingStore) *LaptopServer {
+// ถ้า ranker เป็น nil จะใช้ ranker ที่ตั้งค่าด้วย DefaultRankingConfig บน rating store
+func NewLaptopServer(store LaptopStore, image ImageStore, rating RatingStore, ranker *Ranker) *LaptopServer {
+	if ranker == nil && rating != nil {
+		ranker = NewRanker(rating, DefaultRankingConfig())
+	}
 	return &LaptopServer{
-		laptopStore: store,
-		imageStore:  image,
-		ratingStore: rating,
+		laptopStore:  store,
+		imageStore:   image,
+		ratingStore:  rating,
+		ranker:       ranker,
+		catalog:      NewCatalog(store, image, rating),
+		maxImageSize: DefaultMaxImageSize,
 	}
 }
 
+// SetMaxImageSize กำหนดขนาดสูงสุดของภาพที่สามารถอัปโหลดได้ (หน่วยเป็น byte)
+func (server *LaptopServer) SetMaxImageSize(size int) {
+	server.maxImageSize = size
+}
+
 // CreateLaptop เป็นฟังก์ชันที่จัดการการสร้างแล็ปท็อปใหม่
+// คำขอที่ส่งซ้ำด้วย idempotency key เดิมจะได้รับการตอบสนองเดิม แทนที่จะสร้างแล็ปท็อปใหม่ที่มี ID ต่างกัน
 func (server *LaptopServer) CreateLaptop(ctx context.Context, req *pb.CreateLaptopRequest) (*pb.CreateLaptopResponse, error) {
+	res, err := server.idempotent(ctx, "CreateLaptop", requestFingerprint(req), &pb.CreateLaptopResponse{}, func() (proto.Message, error) {
+		return server.createLaptop(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.CreateLaptopResponse), nil
+}
+
+// createLaptop ตรวจสอบและบันทึกแล็ปท็อปใหม่ลงใน store
+func (server *LaptopServer) createLaptop(ctx context.Context, req *pb.CreateLaptopRequest) (*pb.CreateLaptopResponse, error) {
 	laptop := req.GetLaptop() // ดึงข้อมูลแล็ปท็อปจากคำขอ
-	log.Printf("ได้รับคำขอการสร้างแล็ปท็อปพร้อม ID: %s", laptop.Id)
+	logger := LoggerFromContext(ctx)
+	logger.Info("received create-laptop request", "laptop_id", laptop.GetId())
 
-	// ตรวจสอบว่ามีการให้ ID ของแล็ปท็อปหรือไม่
-	if len(laptop.Id) > 0 {
-		_, err := uuid.Parse(laptop.Id) // ตรวจสอบว่า ID ที่ให้มานั้นเป็น UUID ที่ถูกต้องหรือไม่
-		if err != nil {
-			return nil, status.Errorf(codes.InvalidArgument, "ID ของแล็ปท็อปไม่ใช่ UUID ที่ถูกต้อง: %v", err)
-		}
-	} else {
+	// ตรวจสอบข้อมูลทุกช่องของแล็ปท็อป รวมถึง ID ที่ต้องเป็น UUID ที่ถูกต้อง และรายงานข้อผิดพลาดทั้งหมดในครั้งเดียว
+	err := validation.ValidateLaptop(laptop)
+	if err != nil {
+		return nil, logError(ctx, err)
+	}
+
+	// สร้าง ID ใหม่หากไม่มีการให้ ID
+	if len(laptop.GetId()) == 0 {
 		id, err := uuid.NewRandom() // สร้าง UUID ใหม่หากไม่มีการให้ ID
 		if err != nil {
-			return nil, status.Errorf(codes.Internal, "ไม่สามารถสร้าง ID ใหม่สำหรับแล็ปท็อป: %v", err)
+			return nil, logError(ctx, apperror.Internal(err, "cannot generate a new laptop ID"))
 		}
 		laptop.Id = id.String() // ตั้งค่า ID ใหม่ให้กับแล็ปท็อป
 	}
 
 	// ตรวจสอบ context ว่าถูกยกเลิกหรือหมดเวลาหรือไม่
-	if ctx.Err() == context.Canceled {
-		return nil, status.Error(codes.Canceled, "request is Canceled")
-	}
-	if ctx.Err() == context.DeadlineExceeded {
-		log.Print("deadline is exceeded")
-		return nil, status.Error(codes.DeadlineExceeded, "deadline is exceeded")
+	err = contextError(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// บันทึกแล็ปท็อปลงใน store
-	err := server.laptopStore.Save(laptop)
-	code := codes.Internal
+	err = server.laptopStore.Save(ctx, laptop)
 	if errors.Is(err, ErrAlreadyExists) {
-		code = codes.AlreadyExists // ตั้งค่า error code เป็น AlreadyExists หากแล็ปท็อปมีอยู่แล้ว
+		// คืนค่า AlreadyExists หากแล็ปท็อปมีอยู่แล้ว
+		return nil, logError(ctx, apperror.New(
+			codes.AlreadyExists, apperror.ReasonLaptopAlreadyExists, "laptop %s already exists", laptop.GetId(),
+		).WithMetadata("laptop_id", laptop.GetId()))
 	}
 	if err != nil {
-		return nil, status.Errorf(code, "ไม่สามารถบันทึกแล็ปท็อปลงใน store: %v", err)
+		return nil, logError(ctx, apperror.Wrap(err, "cannot save laptop to the store"))
 	}
 
-	log.Printf("บันทึกแล็ปท็อปด้วย ID: %s", laptop.Id)
+	logger.Info("saved laptop", "laptop_id", laptop.GetId())
 
 	// คืนค่าการตอบสนองที่ประสบความสำเร็จพร้อม ID ของแล็ปท็อป
 	res := &pb.CreateLaptopResponse{
@@ -80,57 +121,150 @@ func (server *LaptopServer) CreateLaptop(ctx context.Context, req *pb.CreateLapt
 	return res, nil
 }
 
+// CreateLaptops เป็นฟังก์ชันที่จัดการการสร้างแล็ปท็อปหลายเครื่องผ่าน bidirectional stream
+// การตอบสนองถูกส่งกลับตามลำดับของคำขอ แล็ปท็อปที่สร้างไม่สำเร็จ เช่น ข้อมูลไม่ถูกต้องหรือมีอยู่แล้ว
+// จะได้รับสถานะของตัวเองโดยไม่ยกเลิกแล็ปท็อปเครื่องอื่นใน stream
+func (server *LaptopServer) CreateLaptops(stream pb.LaptopService_CreateLaptopsServer) error {
+	ctx := stream.Context()
+	logger := LoggerFromContext(ctx)
+	locale := LocaleFromContext(ctx)
+
+	for index := uint32(0); ; index++ {
+		err := contextError(ctx) // ตรวจสอบ context ว่าถูกยกเลิกหรือหมดเวลาหรือไม่
+		if err != nil {
+			return err
+		}
+
+		req, err := stream.Recv() // รับคำขอจากไคลเอนต์
+		if err == io.EOF {
+			logger.Info("no more create-laptops requests", "count", index)
+			return nil
+		}
+		if err != nil {
+			return logError(ctx, streamBroken(err, "cannot receive stream request"))
+		}
+
+		res := &pb.CreateLaptopsResponse{Index: index}
+		created, err := server.createLaptop(ctx, &pb.CreateLaptopRequest{Laptop: req.GetLaptop()})
+		if err != nil {
+			// context ที่ถูกยกเลิกหรือหมดเวลาทำให้ทั้ง stream ล้มเหลว ไม่ใช่เฉพาะแล็ปท็อปเครื่องนี้
+			if ctx.Err() != nil {
+				return err
+			}
+			res.Status = itemStatus(apperror.From(err), locale)
+		} else {
+			res.Id = created.GetId()
+		}
+
+		err = stream.Send(res) // ส่งการตอบสนองไปยังไคลเอนต์
+		if err != nil {
+			return logError(ctx, streamBroken(err, "cannot send stream response"))
+		}
+	}
+}
+
 // SearchLaptop เป็นฟังก์ชันที่จัดการการค้นหาแล็ปท็อปตามเงื่อนไขที่ระบุ
 func (server *LaptopServer) SearchLaptop(
 	req *pb.SearchLaptopRequest,
 	stream pb.LaptopService_SearchLaptopServer,
 ) error {
+	ctx := stream.Context()
+	logger := LoggerFromContext(ctx)
 	filter := req.GetFilter() // ดึงข้อมูลเงื่อนไขในการค้นหาแล็ปท็อปจากคำขอ
-	log.Printf("Received a search-laptop request with filter: %v", filter)
+	sortBy := req.GetSortBy() // ดึงลำดับการเรียงผลลัพธ์จากคำขอ
+	logger.Info("received search-laptop request", "filter", filter.String(), "sort_by", sortBy.String())
+
+	send := func(laptop *pb.Laptop, score *pb.LaptopScore) error {
+		res := &pb.SearchLaptopResponse{Laptop: laptop, Score: score}
+		err := stream.Send(res) // ส่งข้อมูลแล็ปท็อปที่พบไปยังไคลเอนต์
+		if err != nil {
+			return err
+		}
+		logger.Debug("sent laptop", "laptop_id", laptop.GetId())
+		return nil
+	}
+
+	// ถ้าต้องเรียงตามคะแนน ต้องเก็บผลลัพธ์ทั้งหมดก่อนแล้วจึงส่ง
+	var results []*pb.SearchLaptopResponse
 
 	// เรียกใช้ฟังก์ชัน Search ของ laptopStore เพื่อค้นหาแล็ปท็อปตามเงื่อนไขที่ระบุ
 	err := server.laptopStore.Search(
-		stream.Context(),
+		ctx,
 		filter,
 		func(laptop *pb.Laptop) error {
 			// ตรวจสอบ context ก่อนการส่งข้อมูล
-			if stream.Context().Err() != nil {
-				return stream.Context().Err()
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
 
-			res := &pb.SearchLaptopResponse{Laptop: laptop}
-			err := stream.Send(res) // ส่งข้อมูลแล็ปท็อปที่พบไปยังไคลเอนต์
+			score, err := server.laptopScore(ctx, laptop.GetId())
 			if err != nil {
 				return err
 			}
-			log.Printf("Sent laptop with id: %s", laptop.GetId())
-			return nil
+
+			if sortBy != pb.SearchLaptopRequest_NONE {
+				results = append(results, &pb.SearchLaptopResponse{Laptop: laptop, Score: score})
+				return nil
+			}
+			return send(laptop, score)
 		},
 	)
 	if err != nil {
-		return status.Errorf(codes.Internal, "unexpected error: %v", err)
+		return logError(ctx, apperror.Wrap(err, "cannot search laptops"))
+	}
+
+	sortByScore(results, sortBy)
+	for _, res := range results {
+		err := send(res.GetLaptop(), res.GetScore())
+		if err != nil {
+			return logError(ctx, apperror.Wrap(err, "cannot send laptop"))
+		}
 	}
 	return nil
 }
 
+// GetLaptop เป็นฟังก์ชันที่คืนค่าแล็ปท็อปตาม ID พร้อมคะแนนสำหรับจัดอันดับ
+func (server *LaptopServer) GetLaptop(ctx context.Context, req *pb.GetLaptopRequest) (*pb.GetLaptopResponse, error) {
+	laptopID := req.GetId()
+	LoggerFromContext(ctx).Info("received get-laptop request", "laptop_id", laptopID)
+
+	laptop, err := server.laptopStore.Find(ctx, laptopID)
+	if err != nil {
+		return nil, logError(ctx, apperror.Wrap(err, "cannot find laptop"))
+	}
+	if laptop == nil {
+		return nil, logError(ctx, laptopNotFound(laptopID))
+	}
+
+	score, err := server.laptopScore(ctx, laptopID)
+	if err != nil {
+		return nil, logError(ctx, apperror.Wrap(err, "cannot compute laptop score"))
+	}
+
+	return &pb.GetLaptopResponse{Laptop: laptop, Score: score}, nil
+}
+
 // UploadImage เป็นฟังก์ชันที่จัดการการอัปโหลดภาพของแล็ปท็อป
 func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServer) error {
+	ctx := stream.Context()
+	logger := LoggerFromContext(ctx)
+
 	req, err := stream.Recv() // รับคำขอแรกจากไคลเอนต์
 	if err != nil {
-		return logError(status.Errorf(codes.Unknown, "cannot receive image info"))
+		return logError(ctx, streamBroken(err, "cannot receive image info"))
 	}
 
 	laptopID := req.GetInfo().GetLaptopId()   // ดึงข้อมูล ID ของแล็ปท็อปจากคำขอ
 	imageType := req.GetInfo().GetImageType() // ดึงประเภทของภาพจากคำขอ
-	log.Printf("receive an upload-image request for laptop %s with image type %s", laptopID, imageType)
+	logger.Info("received upload-image request", "laptop_id", laptopID, "image_type", imageType)
 
 	// ตรวจสอบว่าแล็ปท็อปมีอยู่ใน store หรือไม่
-	laptop, err := server.laptopStore.Find(laptopID)
+	laptop, err := server.laptopStore.Find(ctx, laptopID)
 	if err != nil {
-		return logError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+		return logError(ctx, apperror.Wrap(err, "cannot find laptop"))
 	}
 	if laptop == nil {
-		return logError(status.Errorf(codes.InvalidArgument, "laptop id %s doesn't exist", laptopID))
+		return logError(ctx, laptopNotFound(laptopID))
 	}
 
 	imageData := bytes.Buffer{} // ตัวแปรสำหรับเก็บข้อมูลภาพ
@@ -138,126 +272,322 @@ func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServe
 
 	// รับข้อมูลภาพเป็นชิ้นๆ จากไคลเอนต์
 	for {
-		err := contextError(stream.Context()) // ตรวจสอบ context ว่าถูกยกเลิกหรือหมดเวลาหรือไม่
+		err := contextError(ctx) // ตรวจสอบ context ว่าถูกยกเลิกหรือหมดเวลาหรือไม่
 		if err != nil {
 			return err
 		}
 
-		log.Print("waiting to receive more data")
-
 		req, err := stream.Recv() // รับข้อมูลภาพเป็นชิ้นๆ
 		if err == io.EOF {
-			log.Print("no more data")
+			logger.Debug("no more image data")
 			break
 		}
 		if err != nil {
-			return logError(status.Errorf(codes.Unknown, "cannot receive chunk data: %v", err))
+			return logError(ctx, streamBroken(err, "cannot receive chunk data"))
 		}
 
 		chunk := req.GetChunkData() // ดึงข้อมูลชิ้นภาพจากคำขอ
 		size := len(chunk)
 
-		log.Printf("received a chunk with size: %d", size)
+		logger.Debug("received image chunk", "size", size)
 
 		imageSize += size
-		if imageSize > maxImageSize {
-			return logError(status.Errorf(codes.InvalidArgument, "image is too large: %d > %d", imageSize, maxImageSize))
+		if imageSize > server.maxImageSize {
+			return logError(ctx, apperror.New(
+				codes.InvalidArgument, apperror.ReasonImageTooLarge, "image is too large: %d > %d bytes", imageSize, server.maxImageSize,
+			).WithViolations(
+				apperror.Violation("chunk_data", "the image must be at most %d bytes", server.maxImageSize),
+			).WithMetadata("max_image_size", strconv.Itoa(server.maxImageSize)))
 		}
 
 		_, err = imageData.Write(chunk) // เขียนข้อมูลชิ้นภาพลงในตัวแปร imageData
 		if err != nil {
-			return logError(status.Errorf(codes.Internal, "cannot write chunk data: %v", err))
+			return logError(ctx, apperror.Internal(err, "cannot write chunk data"))
 		}
 	}
 
-	// บันทึกภาพลงใน store
-	imageID, err := server.imageStore.Save(laptopID, imageType, imageData)
-	if err != nil {
-		return logError(status.Errorf(codes.Internal, "cannot save image to the store: %v", err))
-	}
+	// ลายนิ้วมือของคำขอประกอบด้วยข้อมูลของภาพและข้อมูลภาพทั้งหมด ไม่ขึ้นกับการแบ่งชิ้น
+	fingerprint := requestFingerprint(req, &pb.UploadImageRequest{
+		Data: &pb.UploadImageRequest_ChunkData{ChunkData: imageData.Bytes()},
+	})
+	res, err := server.idempotent(ctx, "UploadImage", fingerprint, &pb.UploadImageResponse{}, func() (proto.Message, error) {
+		// บันทึกภาพลงใน store ผ่าน catalog ซึ่งตรวจสอบอีกครั้งว่าแล็ปท็อปยังไม่ถูกลบระหว่างการอัปโหลด
+		imageID, err := server.catalog.SaveImage(ctx, laptopID, imageType, imageData)
+		if errors.Is(err, ErrNotFound) {
+			return nil, logError(ctx, laptopNotFound(laptopID))
+		}
+		if err != nil {
+			return nil, logError(ctx, apperror.Wrap(err, "cannot save image to the store"))
+		}
 
-	res := &pb.UploadImageResponse{
-		Id:   imageID,
-		Size: uint32(imageSize),
+		logger.Info("saved image", "laptop_id", laptopID, "image_id", imageID, "size", imageSize)
+		return &pb.UploadImageResponse{
+			Id:   imageID,
+			Size: uint32(imageSize),
+		}, nil
+	})
+	if err != nil {
+		return err
 	}
 
-	err = stream.SendAndClose(res) // ส่งการตอบสนองและปิดสตรีม
+	err = stream.SendAndClose(res.(*pb.UploadImageResponse)) // ส่งการตอบสนองและปิดสตรีม
 	if err != nil {
-		return logError(status.Errorf(codes.Unknown, "cannot send response: %v", err))
+		return logError(ctx, streamBroken(err, "cannot send response"))
 	}
-
-	log.Printf("saved image with id: %s, size: %d", imageID, imageSize)
 	return nil
 }
 
 // RateLaptop เป็นฟังก์ชันที่จัดการการให้คะแนนแล็ปท็อป
 func (server *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer) error {
+	ctx := stream.Context()
+	logger := LoggerFromContext(ctx)
+
 	for {
-		err := contextError(stream.Context()) // ตรวจสอบ context ว่าถูกยกเลิกหรือหมดเวลาหรือไม่
+		err := contextError(ctx) // ตรวจสอบ context ว่าถูกยกเลิกหรือหมดเวลาหรือไม่
 		if err != nil {
 			return err
 		}
 
 		req, err := stream.Recv() // รับคำขอจากไคลเอนต์
 		if err == io.EOF {
-			log.Print("no more data")
+			logger.Debug("no more rate-laptop requests")
 			break
 		}
 		if err != nil {
-			return logError(status.Errorf(codes.Unknown, "cannot receive stream request: %v", err))
+			return logError(ctx, streamBroken(err, "cannot receive stream request"))
 		}
 
 		laptopID := req.GetLaptopId() // ดึงข้อมูล ID ของแล็ปท็อปจากคำขอ
 		score := req.GetScore()       // ดึงคะแนนจากคำขอ
 
-		log.Printf("received a rate-laptop request: id = %s, score = %.2f", laptopID, score)
+		logger.Info("received rate-laptop request", "laptop_id", laptopID, "score", score)
 
-		// ตรวจสอบว่าแล็ปท็อปมีอยู่ใน store หรือไม่
-		found, err := server.laptopStore.Find(laptopID)
-		if err != nil {
-			return logError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+		// คะแนนของผู้ใช้ถูกเก็บตาม username ใน JWT claims เพื่อให้การให้คะแนนซ้ำแทนที่คะแนนเดิม
+		claims, ok := UserClaimsFromContext(ctx)
+		if !ok {
+			return logError(ctx, apperror.New(codes.Unauthenticated, apperror.ReasonUnauthenticated, "user is not authenticated"))
 		}
-		if found == nil {
-			return logError(status.Errorf(codes.NotFound, "laptopID %s is not found", laptopID))
+
+		// NaN ไม่เท่ากับค่าใดเลย จึงต้องตรวจว่าคะแนนอยู่ในช่วง แทนการตรวจว่าอยู่นอกช่วง
+		if !(score >= MinRatingScore && score <= MaxRatingScore) {
+			return logError(ctx, apperror.InvalidArgument("score is out of range", apperror.Violation(
+				"score", "must be between %d and %d, got %.2f", MinRatingScore, MaxRatingScore, score,
+			)))
+		}
+
+		// เพิ่มคะแนนลงใน store ผ่าน catalog ซึ่งตรวจสอบว่าแล็ปท็อปมีอยู่ใน store หรือไม่
+		rating, err := server.catalog.AddRating(ctx, laptopID, claims.Username, score, req.GetReview())
+		if errors.Is(err, ErrNotFound) {
+			return logError(ctx, laptopNotFound(laptopID))
+		}
+		if err != nil {
+			return logError(ctx, apperror.Wrap(err, "cannot add rating to the store"))
 		}
 
-		// เพิ่มคะแนนลงใน store
-		rating, err := server.ratingStore.Add(laptopID, score)
+		laptopScore, err := server.laptopScore(ctx, laptopID)
 		if err != nil {
-			return logError(status.Errorf(codes.Internal, "cannot add rating to the store: %v", err))
+			return logError(ctx, apperror.Wrap(err, "cannot compute laptop score"))
 		}
 
 		res := &pb.RateLaptopResponse{
 			LaptopId:     laptopID,
 			RatedCount:   rating.Count,
-			AverageScore: rating.Sum / float64(rating.Count), // คำนวณคะแนนเฉลี่ย
+			AverageScore: rating.Average(), // คำนวณคะแนนเฉลี่ย
+			Score:        laptopScore,
 		}
 
 		err = stream.Send(res) // ส่งการตอบสนองไปยังไคลเอนต์
 		if err != nil {
-			return logError(status.Errorf(codes.Unknown, "cannot send stream response: %v", err))
+			return logError(ctx, streamBroken(err, "cannot send stream response"))
 		}
 	}
 
 	return nil
 }
 
+// GetLaptopRatings เป็นฟังก์ชันที่คืนค่าคะแนนเฉลี่ย histogram และ review ของแล็ปท็อปแบบแบ่งหน้า
+func (server *LaptopServer) GetLaptopRatings(
+	ctx context.Context,
+	req *pb.GetLaptopRatingsRequest,
+) (*pb.GetLaptopRatingsResponse, error) {
+	laptopID := req.GetLaptopId()
+	LoggerFromContext(ctx).Info("received get-laptop-ratings request", "laptop_id", laptopID)
+
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = defaultReviewPageSize
+	}
+	if pageSize > maxReviewPageSize {
+		pageSize = maxReviewPageSize
+	}
+
+	offset := 0
+	if req.GetPageToken() != "" {
+		var err error
+		offset, err = strconv.Atoi(req.GetPageToken())
+		if err != nil || offset < 0 {
+			return nil, logError(ctx, apperror.InvalidArgument("page token is invalid", apperror.Violation(
+				"page_token", "must be the next_page_token of a previous response",
+			)))
+		}
+	}
+
+	found, err := server.laptopStore.Find(ctx, laptopID)
+	if err != nil {
+		return nil, logError(ctx, apperror.Wrap(err, "cannot find laptop"))
+	}
+	if found == nil {
+		return nil, logError(ctx, laptopNotFound(laptopID))
+	}
+
+	rating, err := server.ratingStore.Find(ctx, laptopID)
+	if err != nil {
+		return nil, logError(ctx, apperror.Wrap(err, "cannot find rating"))
+	}
+	if rating == nil {
+		rating = &Rating{}
+	}
+
+	reviews, total, err := server.ratingStore.ListReviews(ctx, laptopID, offset, pageSize)
+	if err != nil {
+		return nil, logError(ctx, apperror.Wrap(err, "cannot list reviews"))
+	}
+
+	res := &pb.GetLaptopRatingsResponse{
+		LaptopId:     laptopID,
+		RatedCount:   rating.Count,
+		AverageScore: rating.Average(),
+		Histogram:    make(map[uint32]uint32),
+	}
+	for score := MinRatingScore; score <= MaxRatingScore; score++ {
+		res.Histogram[uint32(score)] = rating.Histogram[score]
+	}
+	for _, review := range reviews {
+		res.Reviews = append(res.Reviews, &pb.Review{
+			Username:  review.Username,
+			Score:     review.Score,
+			Review:    review.Review,
+			UpdatedAt: timestamppb.New(review.UpdatedAt),
+			CreatedAt: timestamppb.New(review.CreatedAt),
+		})
+	}
+	if offset+len(reviews) < total {
+		res.NextPageToken = strconv.Itoa(offset + len(reviews))
+	}
+
+	return res, nil
+}
+
+// DeleteLaptop เป็นฟังก์ชันที่ลบแล็ปท็อป ถ้า cascade เป็น true จะลบภาพและคะแนนของแล็ปท็อปด้วย
+// มิฉะนั้นจะปฏิเสธการลบด้วย FailedPrecondition เมื่อยังมีภาพหรือคะแนนอ้างถึงแล็ปท็อปอยู่
+func (server *LaptopServer) DeleteLaptop(ctx context.Context, req *pb.DeleteLaptopRequest) (*pb.DeleteLaptopResponse, error) {
+	laptopID := req.GetId()
+	logger := LoggerFromContext(ctx)
+	logger.Info("received delete-laptop request", "laptop_id", laptopID, "cascade", req.GetCascade())
+
+	result, err := server.catalog.DeleteLaptop(ctx, laptopID, req.GetCascade())
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, logError(ctx, laptopNotFound(laptopID))
+	case errors.Is(err, ErrHasDependents):
+		return nil, logError(ctx, apperror.New(
+			codes.FailedPrecondition, apperror.ReasonLaptopHasDependents, "laptop %s has images or ratings, delete it with cascade", laptopID,
+		).WithMetadata("laptop_id", laptopID).WithCause(err))
+	case err != nil:
+		return nil, logError(ctx, apperror.Wrap(err, "cannot delete laptop"))
+	}
+
+	logger.Info("deleted laptop", "laptop_id", laptopID, "deleted_images", result.DeletedImages, "deleted_ratings", result.DeletedRatings)
+	return &pb.DeleteLaptopResponse{
+		Id:             laptopID,
+		DeletedImages:  uint32(result.DeletedImages),
+		DeletedRatings: uint32(result.DeletedRatings),
+	}, nil
+}
+
+// laptopScore คำนวณคะแนนสำหรับจัดอันดับของแล็ปท็อป หรือคืนค่า nil ถ้าเซิร์ฟเวอร์ไม่มี ranker
+func (server *LaptopServer) laptopScore(ctx context.Context, laptopID string) (*pb.LaptopScore, error) {
+	if server.ranker == nil {
+		return nil, nil
+	}
+
+	score, err := server.ranker.Score(ctx, laptopID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.LaptopScore{
+		RatedCount:    score.Count,
+		AverageScore:  score.Average,
+		BayesianScore: score.Bayesian,
+		DecayedScore:  score.Decayed,
+	}, nil
+}
+
+// sortByScore เรียงผลการค้นหาจากคะแนนมากไปน้อยตามชนิดคะแนนที่เลือก
+func sortByScore(results []*pb.SearchLaptopResponse, sortBy pb.SearchLaptopRequest_SortBy) {
+	value := func(score *pb.LaptopScore) float64 {
+		switch sortBy {
+		case pb.SearchLaptopRequest_BAYESIAN_SCORE:
+			return score.GetBayesianScore()
+		case pb.SearchLaptopRequest_DECAYED_SCORE:
+			return score.GetDecayedScore()
+		default:
+			return score.GetAverageScore()
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return value(results[i].GetScore()) > value(results[j].GetScore())
+	})
+}
+
+// laptopNotFound คืนค่าข้อผิดพลาด NotFound ของแล็ปท็อปที่ไม่มีอยู่ใน store
+func laptopNotFound(laptopID string) *apperror.Error {
+	return apperror.New(
+		codes.NotFound, apperror.ReasonLaptopNotFound, "laptop %s is not found", laptopID,
+	).WithMetadata("laptop_id", laptopID)
+}
+
+// itemStatus แปลงข้อผิดพลาดของคำขอหนึ่งรายการใน stream เป็นสถานะที่แปลตาม locale ของไคลเอนต์
+func itemStatus(err *apperror.Error, locale string) *pb.ItemStatus {
+	st := err.Localize(locale)
+	itemStatus := &pb.ItemStatus{
+		Code:    int32(st.Code()),
+		Message: st.Message(),
+		Reason:  err.Reason,
+	}
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.GetFieldViolations() {
+				itemStatus.FieldViolations = append(itemStatus.FieldViolations, &pb.FieldViolation{
+					Field:       violation.GetField(),
+					Description: violation.GetDescription(),
+				})
+			}
+		}
+	}
+	return itemStatus
+}
+
+// streamBroken คืนค่าข้อผิดพลาดเมื่อรับหรือส่งข้อความบน stream ไม่สำเร็จ
+func streamBroken(err error, message string) *apperror.Error {
+	return apperror.New(codes.Unknown, apperror.ReasonStreamBroken, message).WithCause(err)
+}
+
 // contextError ตรวจสอบ context ว่าถูกยกเลิกหรือหมดเวลาหรือไม่
 func contextError(ctx context.Context) error {
-	switch ctx.Err() {
-	case context.Canceled:
-		return logError(status.Error(codes.Canceled, "request is canceled"))
-	case context.DeadlineExceeded:
-		return logError(status.Error(codes.DeadlineExceeded, "deadline is exceeded"))
-	default:
+	if ctx.Err() == nil {
 		return nil
 	}
+	return logError(ctx, apperror.From(ctx.Err()))
 }
 
-// logError บันทึกข้อผิดพลาดลงใน log และคืนค่าข้อผิดพลาด
-func logError(err error) error {
+// logError บันทึกข้อผิดพลาดลงใน log ของคำขอและคืนค่าข้อผิดพลาด
+// ใช้ระดับ debug เพราะ LoggingInterceptor บันทึกผลลัพธ์สุดท้ายของทุกคำขออยู่แล้ว
+func logError(ctx context.Context, err error) error {
 	if err != nil {
-		log.Print(err)
+		LoggerFromContext(ctx).Debug("request failed", "error", err)
 	}
 	return err
 }
@@ -2,12 +2,14 @@ package service_test
 
 import (
 	"context"
+	"fmt"
 	"grpc-project/example.com/pcbook/pb"
 	"grpc-project/sample"
 	"grpc-project/service"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -27,7 +29,7 @@ func TestServerCreateLaptop(t *testing.T) {
 	// สร้างตัวอย่าง Laptop ที่มี ID ซ้ำกัน และเก็บไว้ใน storeDuplicateID
 	laptopDuplicateID := sample.NewLaptop()
 	storeDuplicateID := service.NewInMemoryLaptopStore()
-	err := storeDuplicateID.Save(laptopDuplicateID) // บันทึก Laptop ที่มี ID ไม่ถูกต้อง
+	err := storeDuplicateID.Save(context.Background(), laptopDuplicateID) // บันทึก Laptop ที่มี ID ไม่ถูกต้อง
 	require.Nil(t, err)                           // ตรวจสอบว่าไม่มีข้อผิดพลาดในการบันทึก
 
 	// กำหนดกรณีทดสอบต่าง ๆ
@@ -76,7 +78,7 @@ func TestServerCreateLaptop(t *testing.T) {
 			}
 
 			// สร้าง instance ของ LaptopServer โดยใช้ store ที่กำหนดในกรณีทดสอบ
-			server := service.NewLaptopServer(tc.store,nil,nil)
+			server := service.NewLaptopServer(tc.store, nil, nil, nil)
 
 			// เรียกใช้ CreateLaptop และตรวจสอบผลลัพธ์
 			res, err := server.CreateLaptop(context.Background(), req)
@@ -98,3 +100,67 @@ func TestServerCreateLaptop(t *testing.T) {
 		})
 	}
 }
+
+// TestServerSearchLaptopSortByScore ทดสอบการเรียงผลการค้นหาตามคะแนนแบบ Bayesian
+func TestServerSearchLaptopSortByScore(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	ratingStore := service.NewInMemoryRatingStore()
+
+	// แล็ปท็อปที่ได้ 10 คะแนนจากผู้ใช้คนเดียวต้องอยู่หลังแล็ปท็อปที่ได้ 9 คะแนนจากผู้ใช้ 20 คน
+	oneVote := sample.NewLaptop()
+	manyVotes := sample.NewLaptop()
+	noVote := sample.NewLaptop()
+	for _, laptop := range []*pb.Laptop{oneVote, manyVotes, noVote} {
+		laptop.PriceUsd = 1000
+		require.NoError(t, laptopStore.Save(context.Background(), laptop))
+	}
+
+	_, err := ratingStore.Add(context.Background(), oneVote.GetId(), "user0", 10, "")
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		_, err := ratingStore.Add(context.Background(), manyVotes.GetId(), fmt.Sprintf("user%d", i), 9, "")
+		require.NoError(t, err)
+	}
+
+	server := service.NewLaptopServer(laptopStore, nil, ratingStore, nil)
+	stream := &testSearchLaptopStream{ctx: context.Background()}
+	req := &pb.SearchLaptopRequest{
+		Filter: &pb.Filter{MaxPriceUsd: 2000},
+		SortBy: pb.SearchLaptopRequest_BAYESIAN_SCORE,
+	}
+	err = server.SearchLaptop(req, stream)
+	require.NoError(t, err)
+
+	require.Len(t, stream.responses, 3)
+	require.Equal(t, manyVotes.GetId(), stream.responses[0].GetLaptop().GetId())
+	require.Equal(t, oneVote.GetId(), stream.responses[1].GetLaptop().GetId())
+	require.Equal(t, noVote.GetId(), stream.responses[2].GetLaptop().GetId())
+	require.Equal(t, uint32(20), stream.responses[0].GetScore().GetRatedCount())
+
+	res, err := server.GetLaptop(context.Background(), &pb.GetLaptopRequest{Id: oneVote.GetId()})
+	require.NoError(t, err)
+	require.Equal(t, oneVote.GetId(), res.GetLaptop().GetId())
+	require.Equal(t, 10.0, res.GetScore().GetAverageScore())
+	require.Less(t, res.GetScore().GetBayesianScore(), 10.0)
+
+	_, err = server.GetLaptop(context.Background(), &pb.GetLaptopRequest{Id: "unknown"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// testSearchLaptopStream เป็น server stream ของ SearchLaptop ที่เก็บ response ไว้ในหน่วยความจำ
+type testSearchLaptopStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses []*pb.SearchLaptopResponse
+}
+
+func (stream *testSearchLaptopStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *testSearchLaptopStream) Send(res *pb.SearchLaptopResponse) error {
+	stream.responses = append(stream.responses, res)
+	return nil
+}
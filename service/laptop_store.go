@@ -2,10 +2,11 @@ package service
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"grpc-project/apperror"
 	"grpc-project/example.com/pcbook/pb"
-	"log"
+	"grpc-project/tracing"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,14 +14,23 @@ import (
 )
 
 // ErrAlreadyExists เป็นข้อผิดพลาดที่ระบุว่าข้อมูลที่พยายามจะบันทึกมีอยู่แล้วใน store
-var ErrAlreadyExists = errors.New("record already exists")
+var ErrAlreadyExists = apperror.ErrAlreadyExists
+
+// ErrNotFound เป็นข้อผิดพลาดที่ระบุว่าไม่พบข้อมูลที่ต้องการใน store
+var ErrNotFound = apperror.ErrNotFound
 
 // LaptopStore เป็น interface ที่กำหนดว่า struct ใดๆ ที่ต้องการทำหน้าที่เกี่ยวกับการจัดเก็บข้อมูลแล็ปท็อป
 // จะต้องมีฟังก์ชัน Save ที่รับพารามิเตอร์เป็น pointer ของ pb.Laptop และส่งคืน error หากเกิดปัญหา
 type LaptopStore interface {
-	Save(laptop *pb.Laptop) error
-	Find(id string) (*pb.Laptop, error)
+	Save(ctx context.Context, laptop *pb.Laptop) error
+	Find(ctx context.Context, id string) (*pb.Laptop, error)
 	Search(ctx context.Context,filter *pb.Filter, found func(laptop *pb.Laptop) error) error
+	// List เรียก found กับแล็ปท็อปทุกเครื่องใน store เรียงตาม ID
+	List(ctx context.Context, found func(laptop *pb.Laptop) error) error
+	// Delete ลบแล็ปท็อปออกจาก store และคืนค่า ErrNotFound ถ้าไม่พบแล็ปท็อป
+	Delete(ctx context.Context, id string) error
+	// Count คืนค่าจำนวนแล็ปท็อปใน store
+	Count(ctx context.Context) (int, error)
 }
 
 // InMemoryLaptopStore เป็น struct ที่ใช้สำหรับเก็บข้อมูลแล็ปท็อปในหน่วยความจำ
@@ -38,7 +48,10 @@ func NewInMemoryLaptopStore() *InMemoryLaptopStore {
 }
 
 // Save ฟังก์ชันสำหรับบันทึกข้อมูลแล็ปท็อปลงใน store
-func (store *InMemoryLaptopStore) Save(laptop *pb.Laptop) error {
+func (store *InMemoryLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	_, span := tracing.Start(ctx, "InMemoryLaptopStore.Save", tracing.WithAttributes(tracing.String("laptop.id", laptop.GetId())))
+	defer span.End()
+
 	store.mutex.Lock() // ทำการ Lock ข้อมูลเพื่อป้องกันการเข้าถึงพร้อมกัน
 
 	defer store.mutex.Unlock() // ทำการ Unlock ข้อมูลเมื่อฟังก์ชันเสร็จสิ้น
@@ -59,7 +72,10 @@ func (store *InMemoryLaptopStore) Save(laptop *pb.Laptop) error {
 	return nil // คืนค่าผลลัพธ์เป็น nil เพื่อแสดงว่าการบันทึกสำเร็จ
 }
 
-func (store *InMemoryLaptopStore) Find(id string) (*pb.Laptop, error) {
+func (store *InMemoryLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	_, span := tracing.Start(ctx, "InMemoryLaptopStore.Find", tracing.WithAttributes(tracing.String("laptop.id", id)))
+	defer span.End()
+
 	store.mutex.RLock()
 	defer store.mutex.RUnlock()
 	laptop := store.data[id]
@@ -69,20 +85,46 @@ func (store *InMemoryLaptopStore) Find(id string) (*pb.Laptop, error) {
 	return deepCopy(laptop)
 }
 
+// Delete ลบแล็ปท็อปออกจาก store
+func (store *InMemoryLaptopStore) Delete(ctx context.Context, id string) error {
+	_, span := tracing.Start(ctx, "InMemoryLaptopStore.Delete", tracing.WithAttributes(tracing.String("laptop.id", id)))
+	defer span.End()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.data[id] == nil {
+		return ErrNotFound
+	}
+
+	delete(store.data, id)
+	return nil
+}
+
+// Count คืนค่าจำนวนแล็ปท็อปใน store
+func (store *InMemoryLaptopStore) Count(ctx context.Context) (int, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return len(store.data), nil
+}
+
 func (store *InMemoryLaptopStore) Search(
     ctx context.Context,
     filter *pb.Filter,
     found func(laptop *pb.Laptop) error,
 ) error {
+    ctx, span := tracing.Start(ctx, "InMemoryLaptopStore.Search")
+    defer span.End()
+
     store.mutex.RLock()
     defer store.mutex.RUnlock()
 
     for _, laptop := range store.data {
         time.Sleep(time.Second) // เลียนแบบการทำงานที่ใช้เวลานาน
-        log.Print("checking laptop id: ", laptop.GetId())
+        LoggerFromContext(ctx).Debug("checking laptop", "laptop_id", laptop.GetId())
 
         if ctx.Err() == context.Canceled || ctx.Err() == context.DeadlineExceeded {
-            log.Print("context is cancelled")
+            LoggerFromContext(ctx).Info("search is canceled", "error", ctx.Err())
             return ctx.Err() // คืนค่า context error
         }
 
@@ -105,6 +147,41 @@ func (store *InMemoryLaptopStore) Search(
 
 
 
+// List เรียก found กับสำเนาของแล็ปท็อปทุกเครื่องเรียงตาม ID
+// found ถูกเรียกหลังจากปลด lock แล้ว เพื่อไม่ให้ผู้เรียกที่ช้าขวางการบันทึกแล็ปท็อป
+func (store *InMemoryLaptopStore) List(ctx context.Context, found func(laptop *pb.Laptop) error) error {
+	ctx, span := tracing.Start(ctx, "InMemoryLaptopStore.List")
+	defer span.End()
+
+	store.mutex.RLock()
+	laptops := make([]*pb.Laptop, 0, len(store.data))
+	for _, laptop := range store.data {
+		other, err := deepCopy(laptop)
+		if err != nil {
+			store.mutex.RUnlock()
+			return fmt.Errorf("failed to copy laptop: %w", err)
+		}
+		laptops = append(laptops, other)
+	}
+	store.mutex.RUnlock()
+
+	sort.Slice(laptops, func(i, j int) bool {
+		return laptops[i].GetId() < laptops[j].GetId()
+	})
+
+	for _, laptop := range laptops {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err := found(laptop)
+		if err != nil {
+			return fmt.Errorf("error calling found function: %w", err)
+		}
+	}
+	return nil
+}
+
 func isQualified(filter *pb.Filter, laptop *pb.Laptop) bool {
 	if laptop.GetPriceUsd() > filter.GetMaxPriceUsd() {
 		return false
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"grpc-project/apperror"
+	"grpc-project/i18n"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type localeKey struct{}
+
+// ContextWithLocale returns a copy of ctx that carries the locale of the client
+func ContextWithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext returns the locale carried by ctx, or English if there is none
+func LocaleFromContext(ctx context.Context) string {
+	locale, ok := ctx.Value(localeKey{}).(string)
+	if !ok {
+		return i18n.English
+	}
+	return locale
+}
+
+// LocaleInterceptor is a server interceptor that chooses the language of the error messages
+// from the accept-language metadata of the request. It must run before the logging
+// interceptor, which needs the application errors before they are turned into statuses.
+type LocaleInterceptor struct{}
+
+// NewLocaleInterceptor returns a new locale interceptor
+func NewLocaleInterceptor() *LocaleInterceptor {
+	return &LocaleInterceptor{}
+}
+
+// Unary returns a server interceptor function to localize the errors of unary RPC
+func (interceptor *LocaleInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx = requestLocale(ctx)
+		res, err := handler(ctx, req)
+		return res, localizeError(LocaleFromContext(ctx), err)
+	}
+}
+
+// Stream returns a server interceptor function to localize the errors of stream RPC
+func (interceptor *LocaleInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx := requestLocale(stream.Context())
+		err := handler(srv, &serverStreamWithContext{ServerStream: stream, ctx: ctx})
+		return localizeError(LocaleFromContext(ctx), err)
+	}
+}
+
+// requestLocale returns a context that carries the locale matching the accept-language
+// metadata of the request
+func requestLocale(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return ContextWithLocale(ctx, i18n.MatchLocale(md[i18n.AcceptLanguageKey]...))
+}
+
+// localizeError turns an application error into a status with its message in the locale,
+// other errors are returned as they are
+func localizeError(locale string, err error) error {
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		return appErr.Localize(locale).Err()
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"grpc-project/client"
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/i18n"
+	"grpc-project/sample"
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLocaleInterceptor(t *testing.T) {
+	t.Parallel()
+
+	localeInterceptor := service.NewLocaleInterceptor()
+	serverAddress := startTestLaptopServer(
+		t, service.NewInMemoryLaptopStore(), nil, nil,
+		grpc.UnaryInterceptor(localeInterceptor.Unary()),
+		grpc.StreamInterceptor(localeInterceptor.Stream()),
+	)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	laptop := sample.NewLaptop()
+	laptop.Id = "invalid-uuid"
+	req := &pb.CreateLaptopRequest{Laptop: laptop}
+
+	// English is the default
+	_, err := laptopClient.CreateLaptop(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, "laptop is invalid", status.Convert(err).Message())
+	message, locale := client.LocalizedMessage(err)
+	require.Equal(t, "laptop is invalid", message)
+	require.Equal(t, i18n.English, locale)
+
+	_, err = laptopClient.CreateLaptop(client.WithAcceptLanguage(context.Background(), "th-TH,en;q=0.5"), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, "ข้อมูลแล็ปท็อปไม่ถูกต้อง", status.Convert(err).Message())
+	message, locale = client.LocalizedMessage(err)
+	require.Equal(t, "ข้อมูลแล็ปท็อปไม่ถูกต้อง", message)
+	require.Equal(t, i18n.Thai, locale)
+	_, badRequest, _ := testErrorDetails(t, status.Convert(err))
+	require.Equal(t, "laptop.id", badRequest.GetFieldViolations()[0].GetField())
+	require.Equal(t, "ต้องเป็น UUID ที่ถูกต้อง", badRequest.GetFieldViolations()[0].GetDescription())
+
+	// stream errors are localized too, formatted with their arguments
+	stream, err := laptopClient.RateLaptop(client.WithAcceptLanguage(context.Background(), "th"))
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.RateLaptopRequest{LaptopId: "unknown", Score: 5}))
+	_, err = stream.Recv()
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	require.Equal(t, "ผู้ใช้ยังไม่ได้ยืนยันตัวตน", status.Convert(err).Message())
+
+	_, err = laptopClient.GetLaptop(client.WithAcceptLanguage(context.Background(), "th"), &pb.GetLaptopRequest{Id: "42"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	require.Equal(t, "ไม่พบแล็ปท็อป 42", status.Convert(err).Message())
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"grpc-project/apperror"
+	"grpc-project/tracing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey is the metadata key that carries the ID of a request between client and server
+const RequestIDKey = "x-request-id"
+
+// maxRequestIDLength bounds the request IDs accepted from clients, longer ones are replaced
+const maxRequestIDLength = 128
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+type requestAttrsKey struct{}
+
+// requestAttrs holds the attributes that interceptors after the logging interceptor, such as auth,
+// learn about a request. They cannot change the context of the logging interceptor, so the
+// "finished request" line reads them from here.
+type requestAttrs struct {
+	mutex sync.Mutex
+	attrs []any
+}
+
+// ContextWithLogger returns a copy of ctx that carries the logger
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or the default logger if there is none
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+	return logger
+}
+
+// ContextWithLogAttrs returns a copy of ctx whose logger adds attrs to every line, and adds attrs
+// to the "finished request" line written by the logging interceptor of the request
+func ContextWithLogAttrs(ctx context.Context, attrs ...any) context.Context {
+	if holder, ok := ctx.Value(requestAttrsKey{}).(*requestAttrs); ok {
+		holder.mutex.Lock()
+		holder.attrs = append(holder.attrs, attrs...)
+		holder.mutex.Unlock()
+	}
+	return ContextWithLogger(ctx, LoggerFromContext(ctx).With(attrs...))
+}
+
+// RequestIDFromContext returns the ID of the request handled with ctx
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok
+}
+
+// LoggingInterceptor is a server interceptor that gives every request an ID and a context logger
+type LoggingInterceptor struct {
+	logger *slog.Logger
+}
+
+// NewLoggingInterceptor returns a new logging interceptor that derives request loggers from logger
+func NewLoggingInterceptor(logger *slog.Logger) *LoggingInterceptor {
+	return &LoggingInterceptor{logger: logger}
+}
+
+// Unary returns a server interceptor function to log unary RPC
+func (interceptor *LoggingInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, requestID := interceptor.requestContext(ctx, info.FullMethod)
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, requestID))
+
+		start := time.Now()
+		res, err := handler(ctx, req)
+		logFinished(ctx, start, err)
+		return res, err
+	}
+}
+
+// Stream returns a server interceptor function to log stream RPC
+func (interceptor *LoggingInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, requestID := interceptor.requestContext(stream.Context(), info.FullMethod)
+		stream.SetHeader(metadata.Pairs(RequestIDKey, requestID))
+
+		start := time.Now()
+		err := handler(srv, &serverStreamWithContext{ServerStream: stream, ctx: ctx})
+		logFinished(ctx, start, err)
+		return err
+	}
+}
+
+// requestContext takes the request ID from the incoming metadata, or generates one,
+// and returns a context carrying it together with a logger for the request
+func (interceptor *LoggingInterceptor) requestContext(ctx context.Context, method string) (context.Context, string) {
+	requestID := ""
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		values := md[RequestIDKey]
+		if len(values) > 0 && len(values[0]) <= maxRequestIDLength {
+			requestID = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+
+	attrs := []any{"request_id", requestID, "method", method}
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, "peer", p.Addr.String())
+	}
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs, "trace_id", sc.TraceID.String())
+	}
+
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	ctx = context.WithValue(ctx, requestAttrsKey{}, &requestAttrs{})
+	return ContextWithLogger(ctx, interceptor.logger.With(attrs...)), requestID
+}
+
+// logFinished logs the result of a request, server-side failures at error level
+func logFinished(ctx context.Context, start time.Time, err error) {
+	code := status.Code(err)
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK:
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+
+	attrs := []any{}
+	if holder, ok := ctx.Value(requestAttrsKey{}).(*requestAttrs); ok {
+		holder.mutex.Lock()
+		attrs = append(attrs, holder.attrs...)
+		holder.mutex.Unlock()
+	}
+	attrs = append(attrs, "code", code.String(), "duration", time.Since(start))
+	if err != nil {
+		attrs = append(attrs, "error", status.Convert(err).Message())
+	}
+	// the cause of an application error is not sent to the client, so it is only found here
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		attrs = append(attrs, "reason", appErr.Reason)
+		if appErr.Cause != nil {
+			attrs = append(attrs, "cause", appErr.Cause.Error())
+		}
+	}
+	LoggerFromContext(ctx).Log(ctx, level, "finished request", attrs...)
+}
@@ -0,0 +1,119 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc-project/client"
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestLoggingInterceptorRequestID(t *testing.T) {
+	t.Parallel()
+
+	serverLogs := &testLogBuffer{}
+	logging := service.NewLoggingInterceptor(slog.New(slog.NewJSONHandler(serverLogs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	jwtManager := service.NewJWTManager("secret", time.Minute)
+	auth := service.NewAuthInterceptor(jwtManager, map[string][]string{
+		"/techshcool.pcbook.LaptopService/CreateLaptop": {"user"},
+	})
+
+	serverAddress := startTestLaptopServer(
+		t, service.NewInMemoryLaptopStore(), nil, nil,
+		grpc.ChainUnaryInterceptor(logging.Unary(), auth.Unary()),
+		grpc.ChainStreamInterceptor(logging.Stream(), auth.Stream()),
+	)
+
+	clientLogs := &testLogBuffer{}
+	clientLogging := client.NewLoggingInterceptor(slog.New(slog.NewJSONHandler(clientLogs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	conn, err := grpc.NewClient(
+		serverAddress,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(clientLogging.Unary()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	laptopClient := pb.NewLaptopServiceClient(conn)
+
+	// the request ID given by the caller is propagated to the server
+	ctx := client.WithRequestID(testContextWithToken(t, jwtManager, "user1"), "req-42")
+	laptop := sample.NewLaptop()
+	var header metadata.MD
+	_, err = laptopClient.CreateLaptop(ctx, &pb.CreateLaptopRequest{Laptop: laptop}, grpc.Header(&header))
+	require.NoError(t, err)
+	require.Equal(t, []string{"req-42"}, header.Get(service.RequestIDKey))
+
+	saved := serverLogs.find(t, "saved laptop", "req-42")
+	require.Equal(t, "/techshcool.pcbook.LaptopService/CreateLaptop", saved["method"])
+	require.Equal(t, laptop.GetId(), saved["laptop_id"])
+	require.Equal(t, "user1", saved["user"])
+	require.Equal(t, "user", saved["role"])
+	require.NotEmpty(t, saved["peer"])
+
+	// auth runs after the logging interceptor, and the user is still in its last line
+	finished := serverLogs.find(t, "finished request", "req-42")
+	require.Equal(t, "OK", finished["code"])
+	require.Equal(t, "INFO", finished["level"])
+	require.Equal(t, "user1", finished["user"])
+	require.Equal(t, "user", finished["role"])
+
+	// otherwise the client interceptor generates one
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()}, grpc.Header(&header))
+	require.Error(t, err)
+	requestID := header.Get(service.RequestIDKey)
+	require.Len(t, requestID, 1)
+	_, err = uuid.Parse(requestID[0])
+	require.NoError(t, err)
+
+	failed := clientLogs.find(t, "rpc failed", requestID[0])
+	require.Equal(t, "Unauthenticated", failed["code"])
+	finished = serverLogs.find(t, "finished request", requestID[0])
+	require.Equal(t, "Unauthenticated", finished["code"])
+	require.Equal(t, "WARN", finished["level"])
+	require.NotContains(t, finished, "user")
+}
+
+// testLogBuffer collects the JSON log lines written by a slog handler
+type testLogBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (logs *testLogBuffer) Write(p []byte) (int, error) {
+	logs.mutex.Lock()
+	defer logs.mutex.Unlock()
+	return logs.buffer.Write(p)
+}
+
+// find returns the first log record with the given message and request ID
+func (logs *testLogBuffer) find(t *testing.T, message string, requestID string) map[string]interface{} {
+	logs.mutex.Lock()
+	defer logs.mutex.Unlock()
+
+	for _, line := range bytes.Split(logs.buffer.Bytes(), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		record := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(line, &record))
+		if record["msg"] == message && record["request_id"] == requestID {
+			return record
+		}
+	}
+
+	require.Failf(t, "log record not found", "%q with request ID %s in:\n%s", message, requestID, logs.buffer.String())
+	return nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/metrics"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsInterceptor is a server interceptor that counts requests and stream messages
+// and measures how long the requests take
+type MetricsInterceptor struct {
+	handled       *metrics.CounterVec
+	handlingTime  *metrics.HistogramVec
+	msgReceived   *metrics.CounterVec
+	msgSent       *metrics.CounterVec
+	uploadedBytes *metrics.Counter
+}
+
+// NewMetricsInterceptor returns a new metrics interceptor that registers its metrics in registry
+func NewMetricsInterceptor(registry *metrics.Registry) *MetricsInterceptor {
+	return &MetricsInterceptor{
+		handled: registry.NewCounterVec(
+			"grpc_server_handled_total",
+			"Total number of RPCs completed on the server, regardless of success or failure.",
+			"grpc_service", "grpc_method", "grpc_type", "grpc_code",
+		),
+		handlingTime: registry.NewHistogramVec(
+			"grpc_server_handling_seconds",
+			"Histogram of response latency (seconds) of RPCs handled by the server.",
+			metrics.DefaultBuckets,
+			"grpc_service", "grpc_method", "grpc_type",
+		),
+		msgReceived: registry.NewCounterVec(
+			"grpc_server_msg_received_total",
+			"Total number of stream messages received from the client.",
+			"grpc_service", "grpc_method", "grpc_type",
+		),
+		msgSent: registry.NewCounterVec(
+			"grpc_server_msg_sent_total",
+			"Total number of stream messages sent by the server.",
+			"grpc_service", "grpc_method", "grpc_type",
+		),
+		uploadedBytes: registry.NewCounterVec(
+			"pcbook_image_uploaded_bytes_total",
+			"Total number of image bytes uploaded by clients.",
+		).With(),
+	}
+}
+
+// RegisterStoreMetrics registers gauges for the number of laptops, images and ratings in the stores
+func RegisterStoreMetrics(registry *metrics.Registry, laptopStore LaptopStore, imageStore ImageStore, ratingStore RatingStore) {
+	registry.NewGaugeFunc("pcbook_laptops_stored", "Number of laptops in the laptop store.", storeCount("laptop", laptopStore.Count))
+	registry.NewGaugeFunc("pcbook_images_stored", "Number of images in the image store.", storeCount("image", imageStore.Count))
+	registry.NewGaugeFunc("pcbook_ratings", "Number of user ratings in the rating store.", storeCount("rating", ratingStore.Count))
+}
+
+// storeCount adapts the Count method of a store to a gauge value
+func storeCount(store string, count func(ctx context.Context) (int, error)) func() float64 {
+	return func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		n, err := count(ctx)
+		if err != nil {
+			slog.Warn("cannot count store items", "store", store, "error", err)
+			return 0
+		}
+		return float64(n)
+	}
+}
+
+// Unary returns a server interceptor function to measure unary RPC
+func (interceptor *MetricsInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		labels := methodLabels(info.FullMethod, "unary")
+
+		start := time.Now()
+		res, err := handler(ctx, req)
+		interceptor.observe(labels, start, err)
+		return res, err
+	}
+}
+
+// Stream returns a server interceptor function to measure stream RPC
+func (interceptor *MetricsInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		labels := methodLabels(info.FullMethod, streamType(info))
+
+		ctx, replayed := contextWithReplayedFlag(stream.Context())
+
+		start := time.Now()
+		err := handler(srv, &monitoredServerStream{
+			ServerStream: stream,
+			ctx:          ctx,
+			interceptor:  interceptor,
+			received:     interceptor.msgReceived.With(labels...),
+			sent:         interceptor.msgSent.With(labels...),
+			replayed:     replayed,
+		})
+		interceptor.observe(labels, start, err)
+		return err
+	}
+}
+
+func (interceptor *MetricsInterceptor) observe(labels []string, start time.Time, err error) {
+	interceptor.handlingTime.With(labels...).Observe(time.Since(start).Seconds())
+	interceptor.handled.With(append(labels, status.Code(err).String())...).Inc()
+}
+
+// methodLabels splits a full method name like /package.Service/Method into the service, method and type labels
+func methodLabels(fullMethod string, rpcType string) []string {
+	service, method, found := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !found {
+		return []string{"unknown", fullMethod, rpcType}
+	}
+	return []string{service, method, rpcType}
+}
+
+func streamType(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return "bidi_stream"
+	case info.IsClientStream:
+		return "client_stream"
+	default:
+		return "server_stream"
+	}
+}
+
+// monitoredServerStream counts the messages sent and received on a server stream
+type monitoredServerStream struct {
+	grpc.ServerStream
+	ctx         context.Context
+	interceptor *MetricsInterceptor
+	received    *metrics.Counter
+	sent        *metrics.Counter
+	replayed    *atomic.Bool // set when the response is replayed for an idempotency key
+}
+
+func (stream *monitoredServerStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *monitoredServerStream) SendMsg(m interface{}) error {
+	err := stream.ServerStream.SendMsg(m)
+	if err != nil {
+		return err
+	}
+
+	stream.sent.Inc()
+	// a replayed response is of an image that is already counted
+	if res, ok := m.(*pb.UploadImageResponse); ok && !stream.replayed.Load() {
+		stream.interceptor.uploadedBytes.Add(float64(res.GetSize()))
+	}
+	return nil
+}
+
+func (stream *monitoredServerStream) RecvMsg(m interface{}) error {
+	err := stream.ServerStream.RecvMsg(m)
+	if err != nil {
+		return err
+	}
+
+	stream.received.Inc()
+	return nil
+}
@@ -0,0 +1,134 @@
+package service_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-project/client"
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/metrics"
+	"grpc-project/sample"
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetricsInterceptor(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	imageStore := service.NewDiskImageStore(t.TempDir())
+	ratingStore := service.NewInMemoryRatingStore()
+
+	registry := metrics.NewRegistry()
+	monitoring := service.NewMetricsInterceptor(registry)
+	service.RegisterStoreMetrics(registry, laptopStore, imageStore, ratingStore)
+
+	jwtManager := service.NewJWTManager("secret", time.Minute)
+	auth := service.NewAuthInterceptor(jwtManager, map[string][]string{
+		"/techshcool.pcbook.LaptopService/RateLaptop": {"user"},
+	})
+	serverAddress := startTestLaptopServer(
+		t, laptopStore, imageStore, ratingStore,
+		grpc.ChainUnaryInterceptor(monitoring.Unary(), auth.Unary()),
+		grpc.ChainStreamInterceptor(monitoring.Stream(), auth.Stream()),
+	)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	laptop := sample.NewLaptop()
+	_, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	invalidLaptop := sample.NewLaptop()
+	invalidLaptop.Id = "invalid-uuid"
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: invalidLaptop})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	upload, err := laptopClient.UploadImage(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&pb.UploadImageRequest{
+		Data: &pb.UploadImageRequest_Info{Info: &pb.ImageInfo{LaptopId: laptop.GetId(), ImageType: ".jpg"}},
+	}))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, upload.Send(&pb.UploadImageRequest{
+			Data: &pb.UploadImageRequest_ChunkData{ChunkData: make([]byte, 100)},
+		}))
+	}
+	_, err = upload.CloseAndRecv()
+	require.NoError(t, err)
+
+	rate, err := laptopClient.RateLaptop(testContextWithToken(t, jwtManager, "user1"))
+	require.NoError(t, err)
+	require.NoError(t, rate.Send(&pb.RateLaptopRequest{LaptopId: laptop.GetId(), Score: 9}))
+	_, err = rate.Recv()
+	require.NoError(t, err)
+	require.NoError(t, rate.CloseSend())
+	_, err = rate.Recv()
+	require.Error(t, err)
+
+	var out strings.Builder
+	require.NoError(t, registry.Write(&out))
+	exposition := out.String()
+
+	for _, line := range []string{
+		`grpc_server_handled_total{grpc_service="techshcool.pcbook.LaptopService",grpc_method="CreateLaptop",grpc_type="unary",grpc_code="OK"} 1`,
+		`grpc_server_handled_total{grpc_service="techshcool.pcbook.LaptopService",grpc_method="CreateLaptop",grpc_type="unary",grpc_code="InvalidArgument"} 1`,
+		`grpc_server_handled_total{grpc_service="techshcool.pcbook.LaptopService",grpc_method="UploadImage",grpc_type="client_stream",grpc_code="OK"} 1`,
+		`grpc_server_handling_seconds_count{grpc_service="techshcool.pcbook.LaptopService",grpc_method="CreateLaptop",grpc_type="unary"} 2`,
+		`grpc_server_msg_received_total{grpc_service="techshcool.pcbook.LaptopService",grpc_method="UploadImage",grpc_type="client_stream"} 4`,
+		`grpc_server_msg_sent_total{grpc_service="techshcool.pcbook.LaptopService",grpc_method="UploadImage",grpc_type="client_stream"} 1`,
+		`grpc_server_msg_received_total{grpc_service="techshcool.pcbook.LaptopService",grpc_method="RateLaptop",grpc_type="bidi_stream"} 1`,
+		`grpc_server_msg_sent_total{grpc_service="techshcool.pcbook.LaptopService",grpc_method="RateLaptop",grpc_type="bidi_stream"} 1`,
+		`pcbook_image_uploaded_bytes_total 300`,
+		`pcbook_laptops_stored 1`,
+		`pcbook_images_stored 1`,
+		`pcbook_ratings 1`,
+	} {
+		require.Contains(t, exposition, line+"\n")
+	}
+}
+
+func TestMetricsSkipReplayedUploads(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(context.Background(), laptop))
+	laptopServer := service.NewLaptopServer(laptopStore, service.NewDiskImageStore(t.TempDir()), nil, nil)
+	laptopServer.SetIdempotencyStore(service.NewInMemoryIdempotencyStore(0), time.Hour)
+
+	registry := metrics.NewRegistry()
+	monitoring := service.NewMetricsInterceptor(registry)
+	grpcServer := grpc.NewServer(grpc.StreamInterceptor(monitoring.Stream()))
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+	laptopClient := newTestLaptopClient(t, listener.Addr().String())
+
+	// the second upload with the same key gets the response of the first one
+	ctx := client.WithIdempotencyKey(context.Background(), "key-1")
+	for i := 0; i < 2; i++ {
+		stream, err := laptopClient.UploadImage(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.UploadImageRequest{
+			Data: &pb.UploadImageRequest_Info{Info: &pb.ImageInfo{LaptopId: laptop.GetId(), ImageType: ".jpg"}},
+		}))
+		require.NoError(t, stream.Send(&pb.UploadImageRequest{
+			Data: &pb.UploadImageRequest_ChunkData{ChunkData: make([]byte, 300)},
+		}))
+		_, err = stream.CloseAndRecv()
+		require.NoError(t, err)
+	}
+
+	var out strings.Builder
+	require.NoError(t, registry.Write(&out))
+	require.Contains(t, out.String(), "pcbook_image_uploaded_bytes_total 300\n")
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// RankingConfig contains the parameters of the laptop ranking scores
+type RankingConfig struct {
+	// PriorMean is the score a laptop is assumed to have before anyone rates it
+	PriorMean float64
+	// PriorWeight is how many votes the prior mean is worth
+	PriorWeight float64
+	// HalfLife is the age after which a rating counts half as much in the decayed score.
+	// Ratings never decay when it is zero.
+	HalfLife time.Duration
+}
+
+// DefaultRankingConfig returns the default ranking parameters
+func DefaultRankingConfig() RankingConfig {
+	return RankingConfig{
+		PriorMean:   5.5,
+		PriorWeight: 10,
+		HalfLife:    90 * 24 * time.Hour,
+	}
+}
+
+// LaptopScore contains the ranking scores of a laptop
+type LaptopScore struct {
+	Count    uint32
+	Average  float64
+	Bayesian float64 // average pulled towards the prior mean when there are few ratings
+	Decayed  float64 // bayesian average where old ratings weigh less
+}
+
+// Ranker computes ranking scores on top of a rating store
+type Ranker struct {
+	ratingStore RatingStore
+	config      RankingConfig
+	now         func() time.Time
+}
+
+// NewRanker returns a new ranker
+func NewRanker(ratingStore RatingStore, config RankingConfig) *Ranker {
+	return &Ranker{
+		ratingStore: ratingStore,
+		config:      config,
+		now:         time.Now,
+	}
+}
+
+// Score returns the ranking scores of a laptop
+func (ranker *Ranker) Score(ctx context.Context, laptopID string) (*LaptopScore, error) {
+	ratings, err := ranker.ratingStore.ListRatings(ctx, laptopID)
+	if err != nil {
+		return nil, err
+	}
+	return ranker.score(ratings), nil
+}
+
+func (ranker *Ranker) score(ratings []*UserRating) *LaptopScore {
+	prior := ranker.config.PriorMean * ranker.config.PriorWeight
+	now := ranker.now()
+
+	sum := 0.0
+	decayedSum := 0.0
+	decayedWeight := 0.0
+	for _, rating := range ratings {
+		weight := ranker.decay(now.Sub(rating.UpdatedAt))
+		sum += rating.Score
+		decayedSum += weight * rating.Score
+		decayedWeight += weight
+	}
+
+	score := &LaptopScore{
+		Count:    uint32(len(ratings)),
+		Bayesian: ranker.config.PriorMean,
+		Decayed:  ranker.config.PriorMean,
+	}
+	if len(ratings) > 0 {
+		score.Average = sum / float64(len(ratings))
+	}
+	if total := ranker.config.PriorWeight + float64(len(ratings)); total > 0 {
+		score.Bayesian = (prior + sum) / total
+	}
+	if total := ranker.config.PriorWeight + decayedWeight; total > 0 {
+		score.Decayed = (prior + decayedSum) / total
+	}
+	return score
+}
+
+// decay returns the weight of a rating of the given age
+func (ranker *Ranker) decay(age time.Duration) float64 {
+	if ranker.config.HalfLife <= 0 || age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(ranker.config.HalfLife))
+}
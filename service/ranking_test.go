@@ -0,0 +1,61 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankerBayesianScore(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	ranker := NewRanker(nil, RankingConfig{PriorMean: 5.5, PriorWeight: 10})
+
+	oneVote := ranker.score([]*UserRating{{Score: 10, UpdatedAt: now}})
+	require.Equal(t, uint32(1), oneVote.Count)
+	require.Equal(t, 10.0, oneVote.Average)
+	require.InDelta(t, (5.5*10+10)/11, oneVote.Bayesian, 1e-9)
+
+	manyVotes := make([]*UserRating, 500)
+	for i := range manyVotes {
+		manyVotes[i] = &UserRating{Score: 9.6, UpdatedAt: now}
+	}
+	popular := ranker.score(manyVotes)
+	require.InDelta(t, 9.6, popular.Average, 1e-9)
+
+	// a single perfect vote has a higher average but must rank below 500 votes at 9.6
+	require.Greater(t, oneVote.Average, popular.Average)
+	require.Greater(t, popular.Bayesian, oneVote.Bayesian)
+
+	empty := ranker.score(nil)
+	require.Equal(t, uint32(0), empty.Count)
+	require.Equal(t, 5.5, empty.Bayesian)
+	require.Equal(t, 5.5, empty.Decayed)
+}
+
+func TestRankerDecayedScore(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	halfLife := 30 * 24 * time.Hour
+	ranker := NewRanker(nil, RankingConfig{PriorMean: 5, PriorWeight: 0, HalfLife: halfLife})
+	ranker.now = func() time.Time { return now }
+
+	score := ranker.score([]*UserRating{
+		{Score: 2, UpdatedAt: now.Add(-halfLife)}, // counts half
+		{Score: 8, UpdatedAt: now},
+	})
+	require.InDelta(t, 5.0, score.Average, 1e-9)
+	require.InDelta(t, 5.0, score.Bayesian, 1e-9)
+	require.InDelta(t, (0.5*2+8)/1.5, score.Decayed, 1e-9)
+
+	// without half-life the decayed score is the bayesian score
+	ranker.config.HalfLife = 0
+	score = ranker.score([]*UserRating{
+		{Score: 2, UpdatedAt: now.Add(-halfLife)},
+		{Score: 8, UpdatedAt: now},
+	})
+	require.InDelta(t, score.Bayesian, score.Decayed, 1e-9)
+}
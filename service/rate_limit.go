@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"grpc-project/apperror"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// AnonymousRole is the role that rate limit rules use for callers without an access token
+const AnonymousRole = "anonymous"
+
+// idleBucketTimeout is how long the limiter keeps the state of a caller that sends no requests
+const idleBucketTimeout = 10 * time.Minute
+
+// RateLimitRule limits the requests of every caller of the methods and roles it matches.
+// Requests are taken from a token bucket that refills Rate tokens per second up to Burst tokens.
+// A Rate of 0 disables the request limit and a MaxStreams of 0 disables the stream limit.
+type RateLimitRule struct {
+	// Method is a full method name, empty for all methods
+	Method string
+	// Role is a user role or AnonymousRole, empty for all callers
+	Role       string
+	Rate       float64
+	Burst      int
+	MaxStreams int
+}
+
+// RateLimiter is a server interceptor that limits the request rate and the number of
+// open streams of every caller, per method. A caller is the authenticated user, or the
+// IP address of the client for anonymous calls, so it must run after the auth interceptor.
+type RateLimiter struct {
+	mutex       sync.Mutex
+	defaultRule RateLimitRule
+	rules       []RateLimitRule
+	buckets     map[callerMethod]*tokenBucket
+	streams     map[callerMethod]int
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+// callerMethod is the key of the state the limiter keeps for a caller of a method
+type callerMethod struct {
+	caller string
+	method string
+}
+
+// NewRateLimiter returns a new rate limiter. The rules override defaultRule, a rule that
+// names both the method and the role takes precedence over one that names only the method,
+// then over one that names only the role.
+func NewRateLimiter(defaultRule RateLimitRule, rules []RateLimitRule) *RateLimiter {
+	return &RateLimiter{
+		defaultRule: defaultRule,
+		rules:       rules,
+		buckets:     make(map[callerMethod]*tokenBucket),
+		streams:     make(map[callerMethod]int),
+		lastCleanup: time.Now(),
+		now:         time.Now,
+	}
+}
+
+// Unary returns a server interceptor function to rate limit unary RPC
+func (limiter *RateLimiter) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		err := limiter.allow(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns a server interceptor function to rate limit the opening of stream RPC
+// and the number of streams a caller keeps open
+func (limiter *RateLimiter) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx := stream.Context()
+		err := limiter.allow(ctx, info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		release, err := limiter.openStream(ctx, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return handler(srv, stream)
+	}
+}
+
+// allow takes a token from the bucket of the caller, or returns a ResourceExhausted error
+// that tells the caller when to retry
+func (limiter *RateLimiter) allow(ctx context.Context, method string) error {
+	caller, role := rateLimitCaller(ctx)
+	rule := limiter.rule(method, role)
+	if rule.Rate <= 0 {
+		return nil
+	}
+
+	limiter.mutex.Lock()
+	now := limiter.now()
+	limiter.cleanup(now)
+
+	key := callerMethod{caller: caller, method: method}
+	bucket := limiter.buckets[key]
+	if bucket == nil {
+		bucket = &tokenBucket{tokens: float64(rule.Burst), updatedAt: now}
+		limiter.buckets[key] = bucket
+	}
+	wait := bucket.take(now, rule.Rate, float64(rule.Burst))
+	limiter.mutex.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	LoggerFromContext(ctx).Warn("rate limit exceeded", "caller", caller, "retry_after", wait)
+	err := apperror.New(codes.ResourceExhausted, apperror.ReasonRateLimited, "too many requests, retry after %s", wait.Round(time.Millisecond))
+	return rateLimitError(err, caller, fmt.Sprintf("%g requests per second with bursts of %d", rule.Rate, rule.Burst), wait)
+}
+
+// openStream counts a new stream of the caller and returns the function that releases it
+func (limiter *RateLimiter) openStream(ctx context.Context, method string) (func(), error) {
+	caller, role := rateLimitCaller(ctx)
+	rule := limiter.rule(method, role)
+	if rule.MaxStreams <= 0 {
+		return func() {}, nil
+	}
+
+	key := callerMethod{caller: caller, method: method}
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if limiter.streams[key] >= rule.MaxStreams {
+		LoggerFromContext(ctx).Warn("too many open streams", "caller", caller, "max_streams", rule.MaxStreams)
+		err := apperror.New(codes.ResourceExhausted, apperror.ReasonRateLimited, "too many open streams, at most %d are allowed", rule.MaxStreams)
+		return nil, rateLimitError(err, caller, fmt.Sprintf("%d concurrent streams", rule.MaxStreams), 0)
+	}
+	limiter.streams[key]++
+
+	return func() {
+		limiter.mutex.Lock()
+		defer limiter.mutex.Unlock()
+
+		limiter.streams[key]--
+		if limiter.streams[key] == 0 {
+			delete(limiter.streams, key)
+		}
+	}, nil
+}
+
+// rule returns the most specific rule for the method and role
+func (limiter *RateLimiter) rule(method string, role string) RateLimitRule {
+	best := limiter.defaultRule
+	bestScore := 0
+	for _, rule := range limiter.rules {
+		if (rule.Method != "" && rule.Method != method) || (rule.Role != "" && rule.Role != role) {
+			continue
+		}
+
+		score := 1
+		if rule.Role != "" {
+			score = 2
+		}
+		if rule.Method != "" {
+			score += 2
+		}
+		if score > bestScore {
+			best, bestScore = rule, score
+		}
+	}
+	return best
+}
+
+// cleanup drops the buckets of callers that sent no request for idleBucketTimeout
+func (limiter *RateLimiter) cleanup(now time.Time) {
+	if now.Sub(limiter.lastCleanup) < idleBucketTimeout {
+		return
+	}
+	limiter.lastCleanup = now
+
+	for key, bucket := range limiter.buckets {
+		if now.Sub(bucket.updatedAt) >= idleBucketTimeout {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+// rateLimitCaller returns the key and role of the caller of a request
+func rateLimitCaller(ctx context.Context) (string, string) {
+	if claims, ok := UserClaimsFromContext(ctx); ok {
+		return "user:" + claims.Username, claims.Role
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "ip:unknown", AnonymousRole
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host, AnonymousRole
+}
+
+// rateLimitError returns err with the quota that failed and, if wait is positive,
+// the delay after which the request can be retried
+func rateLimitError(err *apperror.Error, subject string, description string, wait time.Duration) error {
+	err = err.WithDetails(&errdetails.QuotaFailure{
+		Violations: []*errdetails.QuotaFailure_Violation{{Subject: subject, Description: description}},
+	})
+	if wait > 0 {
+		err = err.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)})
+	}
+	return err
+}
+
+// tokenBucket holds the tokens of a caller, it is refilled lazily when a token is taken
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// take refills the bucket and takes a token. If the bucket is empty, it returns how long
+// to wait until the next token is available.
+func (bucket *tokenBucket) take(now time.Time, rate float64, burst float64) time.Duration {
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = math.Min(burst, bucket.tokens+elapsed*rate)
+		bucket.updatedAt = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - bucket.tokens) / rate * float64(time.Second)))
+}
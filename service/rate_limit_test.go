@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"grpc-project/apperror"
+	"grpc-project/i18n"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testSearchMethod = "/techshcool.pcbook.LaptopService/SearchLaptop"
+	testCreateMethod = "/techshcool.pcbook.LaptopService/CreateLaptop"
+)
+
+func TestRateLimiterRule(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(RateLimitRule{Rate: 10, Burst: 10}, []RateLimitRule{
+		{Role: "admin", Rate: 100, Burst: 100},
+		{Method: testSearchMethod, Rate: 1, Burst: 1},
+		{Method: testSearchMethod, Role: "admin", Rate: 5, Burst: 5},
+		{Role: AnonymousRole, Rate: 2, Burst: 2},
+	})
+
+	require.Equal(t, 10.0, limiter.rule(testCreateMethod, "user").Rate)
+	require.Equal(t, 100.0, limiter.rule(testCreateMethod, "admin").Rate)
+	require.Equal(t, 2.0, limiter.rule(testCreateMethod, AnonymousRole).Rate)
+	require.Equal(t, 1.0, limiter.rule(testSearchMethod, "user").Rate)
+	require.Equal(t, 1.0, limiter.rule(testSearchMethod, AnonymousRole).Rate)
+	require.Equal(t, 5.0, limiter.rule(testSearchMethod, "admin").Rate)
+}
+
+func TestRateLimiterUnary(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	limiter := NewRateLimiter(RateLimitRule{Rate: 2, Burst: 3}, nil)
+	limiter.now = func() time.Time { return now }
+
+	interceptor := limiter.Unary()
+	info := &grpc.UnaryServerInfo{FullMethod: testCreateMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	call := func(ctx context.Context) error {
+		_, err := interceptor(ctx, nil, info, handler)
+		return err
+	}
+
+	user1 := ContextWithUserClaims(testPeerContext("10.0.0.1:5000"), &UserClaims{Username: "user1", Role: "user"})
+	user2 := ContextWithUserClaims(testPeerContext("10.0.0.1:5001"), &UserClaims{Username: "user2", Role: "user"})
+
+	// the burst is available at once
+	for i := 0; i < 3; i++ {
+		require.NoError(t, call(user1))
+	}
+	err := call(user1)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Equal(t, 500*time.Millisecond, testRetryDelay(t, err))
+
+	// another user from the same address has its own bucket
+	require.NoError(t, call(user2))
+
+	// the bucket refills at the rate
+	now = now.Add(250 * time.Millisecond)
+	err = call(user1)
+	require.Equal(t, 250*time.Millisecond, testRetryDelay(t, err))
+	now = now.Add(250 * time.Millisecond)
+	require.NoError(t, call(user1))
+
+	// anonymous callers are limited by IP address, whatever their port
+	anonymous := testPeerContext("10.0.0.2:6000")
+	for i := 0; i < 3; i++ {
+		require.NoError(t, call(anonymous))
+	}
+	err = call(testPeerContext("10.0.0.2:6001"))
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// the error has a reason and a message in the locale of the client, like other errors
+	var appErr *apperror.Error
+	require.ErrorAs(t, err, &appErr)
+	require.Equal(t, apperror.ReasonRateLimited, appErr.Reason)
+	details := appErr.Localize(i18n.Thai).Details()
+	require.Len(t, details, 4)
+	require.Equal(t, apperror.ReasonRateLimited, details[0].(*errdetails.ErrorInfo).GetReason())
+	quotaFailure, ok := details[1].(*errdetails.QuotaFailure)
+	require.True(t, ok)
+	require.Equal(t, "ip:10.0.0.2", quotaFailure.GetViolations()[0].GetSubject())
+	require.Equal(t, "ส่งคำขอมากเกินไป โปรดลองใหม่หลังจาก 500ms", details[3].(*errdetails.LocalizedMessage).GetMessage())
+}
+
+func TestRateLimiterStreams(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(RateLimitRule{}, []RateLimitRule{
+		{Method: testSearchMethod, MaxStreams: 2},
+	})
+	interceptor := limiter.Stream()
+	info := &grpc.StreamServerInfo{FullMethod: testSearchMethod, IsServerStream: true}
+
+	ctx := ContextWithUserClaims(testPeerContext("10.0.0.1:5000"), &UserClaims{Username: "user1", Role: "user"})
+	stream := &testServerStream{ctx: ctx}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error)
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		go func() {
+			done <- interceptor(nil, stream, info, handler)
+		}()
+		<-started
+	}
+
+	err := interceptor(nil, stream, info, handler)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// closing a stream allows a new one
+	release <- struct{}{}
+	require.NoError(t, <-done)
+	go func() {
+		done <- interceptor(nil, stream, info, handler)
+	}()
+	<-started
+
+	close(release)
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+	require.Empty(t, limiter.streams)
+}
+
+func testPeerContext(address string) context.Context {
+	addr, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		panic(err)
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+}
+
+// testRetryDelay returns the retry delay in the details of a rate limit error
+func testRetryDelay(t *testing.T, err error) time.Duration {
+	for _, detail := range status.Convert(err).Details() {
+		if retryInfo, ok := detail.(*errdetails.RetryInfo); ok {
+			return retryInfo.GetRetryDelay().AsDuration()
+		}
+	}
+	require.Fail(t, "retry info not found", "%v", err)
+	return 0
+}
+
+// testServerStream is a server stream that only has a context
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *testServerStream) Context() context.Context {
+	return stream.ctx
+}
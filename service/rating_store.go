@@ -1,49 +1,268 @@
 package service
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"grpc-project/tracing"
+)
+
+const (
+	// MinRatingScore is the lowest score a user can give to a laptop
+	MinRatingScore = 1
+	// MaxRatingScore is the highest score a user can give to a laptop
+	MaxRatingScore = 10
+)
 
 // RatingStore is an interface to store laptop ratings
 type RatingStore interface {
-	// Add adds a new laptop score to the store and returns its rating
-	Add(laptopID string, score float64) (*Rating, error)
+	// Add adds or replaces the score of a user for a laptop and returns the laptop rating
+	Add(ctx context.Context, laptopID string, username string, score float64, review string) (*Rating, error)
+	// Find returns the rating of a laptop, or nil if nobody rated it yet
+	Find(ctx context.Context, laptopID string) (*Rating, error)
+	// ListReviews returns the user ratings of a laptop that have a review,
+	// newest first, starting at offset, and the total number of reviews
+	ListReviews(ctx context.Context, laptopID string, offset int, limit int) ([]*UserRating, int, error)
+	// ListRatings returns all user ratings of a laptop
+	ListRatings(ctx context.Context, laptopID string) ([]*UserRating, error)
+	// RemoveAll removes all ratings of a laptop and returns them
+	RemoveAll(ctx context.Context, laptopID string) ([]*UserRating, error)
+	// Restore puts back user ratings returned by RemoveAll
+	Restore(ctx context.Context, ratings []*UserRating) error
+	// Count returns the number of user ratings of all laptops
+	Count(ctx context.Context) (int, error)
 }
 
 // Rating contains the rating information of a laptop
 type Rating struct {
-	Count uint32    // Count เก็บจำนวนครั้งที่มีการให้คะแนน
-	Sum   float64   // Sum เก็บผลรวมของคะแนนทั้งหมด
+	Count     uint32                     // Count เก็บจำนวนผู้ใช้ที่ให้คะแนน
+	Sum       float64                    // Sum เก็บผลรวมของคะแนนทั้งหมด
+	Histogram [MaxRatingScore + 1]uint32 // Histogram[n] เก็บจำนวนคะแนนที่ปัดเศษลงแล้วเท่ากับ n
+}
+
+// Average returns the average score of the laptop
+func (rating *Rating) Average() float64 {
+	if rating.Count == 0 {
+		return 0
+	}
+	return rating.Sum / float64(rating.Count)
+}
+
+// UserRating is the score and review a user gave to a laptop
+type UserRating struct {
+	LaptopID  string
+	Username  string
+	Score     float64
+	Review    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 // InMemoryRatingStore stores laptop ratings in memory
 type InMemoryRatingStore struct {
-	mutex  sync.RWMutex        // mutex เพื่อใช้ในการจัดการความปลอดภัยของการเข้าถึงข้อมูลพร้อมกัน
-	rating map[string]*Rating  // rating เป็น map ที่เก็บข้อมูลคะแนนโดยใช้ laptopID เป็น key
+	mutex   sync.RWMutex                      // mutex เพื่อใช้ในการจัดการความปลอดภัยของการเข้าถึงข้อมูลพร้อมกัน
+	rating  map[string]*Rating                // rating เป็น map ที่เก็บข้อมูลคะแนนรวมโดยใช้ laptopID เป็น key
+	ratings map[string]map[string]*UserRating // ratings เก็บคะแนนของผู้ใช้แต่ละคน โดยใช้ laptopID และ username เป็น key
 }
 
 // NewInMemoryRatingStore returns a new InMemoryRatingStore
 func NewInMemoryRatingStore() *InMemoryRatingStore {
 	return &InMemoryRatingStore{
-		rating: make(map[string]*Rating),  // สร้าง InMemoryRatingStore ใหม่พร้อมกำหนด map เปล่าๆ
+		rating:  make(map[string]*Rating),
+		ratings: make(map[string]map[string]*UserRating),
+	}
+}
+
+// Add adds or replaces the score of a user for a laptop and returns the laptop rating
+func (store *InMemoryRatingStore) Add(ctx context.Context, laptopID string, username string, score float64, review string) (*Rating, error) {
+	_, span := tracing.Start(ctx, "InMemoryRatingStore.Add", tracing.WithAttributes(tracing.String("laptop.id", laptopID)))
+	defer span.End()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	rating := store.rating[laptopID]
+	if rating == nil {
+		rating = &Rating{}
+		store.rating[laptopID] = rating
+	}
+
+	users := store.ratings[laptopID]
+	if users == nil {
+		users = make(map[string]*UserRating)
+		store.ratings[laptopID] = users
+	}
+
+	now := time.Now()
+	userRating := users[username]
+	if userRating == nil {
+		userRating = &UserRating{
+			LaptopID:  laptopID,
+			Username:  username,
+			CreatedAt: now,
+		}
+		users[username] = userRating
+		rating.Count++
+	} else {
+		// ผู้ใช้เคยให้คะแนนแล้ว ให้ลบคะแนนเดิมออกก่อนแทนที่ด้วยคะแนนใหม่
+		rating.Sum -= userRating.Score
+		rating.Histogram[histogramBucket(userRating.Score)]--
+	}
+
+	userRating.Score = score
+	userRating.Review = review
+	userRating.UpdatedAt = now
+
+	rating.Sum += score
+	rating.Histogram[histogramBucket(score)]++
+
+	other := *rating
+	return &other, nil
+}
+
+// Find returns the rating of a laptop, or nil if nobody rated it yet
+func (store *InMemoryRatingStore) Find(ctx context.Context, laptopID string) (*Rating, error) {
+	_, span := tracing.Start(ctx, "InMemoryRatingStore.Find", tracing.WithAttributes(tracing.String("laptop.id", laptopID)))
+	defer span.End()
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	rating := store.rating[laptopID]
+	if rating == nil {
+		return nil, nil
+	}
+
+	other := *rating
+	return &other, nil
+}
+
+// ListReviews returns the user ratings of a laptop that have a review,
+// newest first, starting at offset, and the total number of reviews
+func (store *InMemoryRatingStore) ListReviews(ctx context.Context, laptopID string, offset int, limit int) ([]*UserRating, int, error) {
+	_, span := tracing.Start(ctx, "InMemoryRatingStore.ListReviews", tracing.WithAttributes(tracing.String("laptop.id", laptopID)))
+	defer span.End()
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	reviews := []*UserRating{}
+	for _, userRating := range store.ratings[laptopID] {
+		if userRating.Review != "" {
+			other := *userRating
+			reviews = append(reviews, &other)
+		}
+	}
+
+	sort.Slice(reviews, func(i, j int) bool {
+		if !reviews[i].UpdatedAt.Equal(reviews[j].UpdatedAt) {
+			return reviews[i].UpdatedAt.After(reviews[j].UpdatedAt)
+		}
+		return reviews[i].Username < reviews[j].Username
+	})
+
+	total := len(reviews)
+	if offset >= total {
+		return []*UserRating{}, total, nil
+	}
+
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return reviews[offset:end], total, nil
+}
+
+// ListRatings returns all user ratings of a laptop
+func (store *InMemoryRatingStore) ListRatings(ctx context.Context, laptopID string) ([]*UserRating, error) {
+	_, span := tracing.Start(ctx, "InMemoryRatingStore.ListRatings", tracing.WithAttributes(tracing.String("laptop.id", laptopID)))
+	defer span.End()
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	ratings := make([]*UserRating, 0, len(store.ratings[laptopID]))
+	for _, userRating := range store.ratings[laptopID] {
+		other := *userRating
+		ratings = append(ratings, &other)
+	}
+	return ratings, nil
+}
+
+// RemoveAll removes all ratings of a laptop and returns them
+func (store *InMemoryRatingStore) RemoveAll(ctx context.Context, laptopID string) ([]*UserRating, error) {
+	_, span := tracing.Start(ctx, "InMemoryRatingStore.RemoveAll", tracing.WithAttributes(tracing.String("laptop.id", laptopID)))
+	defer span.End()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	ratings := make([]*UserRating, 0, len(store.ratings[laptopID]))
+	for _, userRating := range store.ratings[laptopID] {
+		ratings = append(ratings, userRating)
 	}
+
+	delete(store.ratings, laptopID)
+	delete(store.rating, laptopID)
+	return ratings, nil
 }
 
-// Add adds a new laptop score to the store and returns its rating
-func (store *InMemoryRatingStore) Add(laptopID string, score float64) (*Rating, error) {
-	store.mutex.Lock()         // ล็อก mutex เพื่อป้องกันการเข้าถึงข้อมูลพร้อมกัน
-	defer store.mutex.Unlock() // ปลดล็อก mutex หลังจากทำงานเสร็จ
+// Restore puts back user ratings returned by RemoveAll, keeping their timestamps
+func (store *InMemoryRatingStore) Restore(ctx context.Context, ratings []*UserRating) error {
+	_, span := tracing.Start(ctx, "InMemoryRatingStore.Restore")
+	defer span.End()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, userRating := range ratings {
+		users := store.ratings[userRating.LaptopID]
+		if users == nil {
+			users = make(map[string]*UserRating)
+			store.ratings[userRating.LaptopID] = users
+		}
+		if users[userRating.Username] != nil {
+			return fmt.Errorf("rating of %s for laptop %s: %w", userRating.Username, userRating.LaptopID, ErrAlreadyExists)
+		}
 
-	rating := store.rating[laptopID]  // ดึงข้อมูล rating ของ laptopID นี้ออกมา
-	if rating == nil {                // ถ้า rating ยังไม่มีอยู่
-		rating = &Rating{             // สร้าง rating ใหม่
-			Count: 1,                  // ตั้งค่า Count เป็น 1
-			Sum:   score,              // ตั้งค่า Sum เป็นค่า score ที่รับเข้ามา
+		rating := store.rating[userRating.LaptopID]
+		if rating == nil {
+			rating = &Rating{}
+			store.rating[userRating.LaptopID] = rating
 		}
-	} else {                          // ถ้า rating มีอยู่แล้ว
-		rating.Count++                // เพิ่มค่า Count ขึ้น 1
-		rating.Sum += score           // เพิ่มค่า Sum ด้วย score ที่รับเข้ามา
+
+		other := *userRating
+		users[userRating.Username] = &other
+		rating.Count++
+		rating.Sum += userRating.Score
+		rating.Histogram[histogramBucket(userRating.Score)]++
 	}
+	return nil
+}
 
-	store.rating[laptopID] = rating   // เก็บ rating ที่อัปเดตแล้วกลับเข้าไปใน map
+// Count returns the number of user ratings of all laptops
+func (store *InMemoryRatingStore) Count(ctx context.Context) (int, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
 
-	return rating, nil                // ส่งค่า rating ที่อัปเดตแล้วกลับไป
+	count := 0
+	for _, users := range store.ratings {
+		count += len(users)
+	}
+	return count, nil
+}
+
+// histogramBucket returns the histogram bucket of a score, which is the score rounded down
+func histogramBucket(score float64) int {
+	bucket := int(score)
+	if bucket < MinRatingScore {
+		return MinRatingScore
+	}
+	if bucket > MaxRatingScore {
+		return MaxRatingScore
+	}
+	return bucket
 }
@@ -0,0 +1,555 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc-project/tracing"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// s3MinPartSize is the smallest part size accepted by S3 for every part but the last one
+	s3MinPartSize = 5 << 20
+
+	defaultS3Region = "us-east-1"
+
+	defaultS3CountTTL = time.Minute
+)
+
+// S3Config contains the settings to connect to an S3-compatible object storage
+type S3Config struct {
+	Endpoint        string // base URL of the S3 API, e.g. https://s3.us-east-1.amazonaws.com
+	Region          string
+	Bucket          string
+	Prefix          string // optional key prefix for every image object, stored as <prefix><laptopID>/<imageID><ext>
+	AccessKeyID     string
+	SecretAccessKey string
+	PartSize        int          // images larger than this are sent with multipart upload, 5MiB by default, which is also the minimum S3 accepts
+	HTTPClient      *http.Client // optional, http.DefaultClient is used when nil
+	// CountTTL is how long Count reuses the number of images it listed, 1 minute by default.
+	// Images saved and deleted by other replicas are counted once it expires.
+	CountTTL time.Duration
+}
+
+// S3ImageStore stores laptop images in an S3-compatible object storage
+// so that several server replicas can share them. The bucket is the only state:
+// images are found by listing the keys under the prefix of their laptop.
+type S3ImageStore struct {
+	client   *http.Client
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	partSize int
+	creds    sigV4Credentials
+	now      func() time.Time
+
+	// count caches the result of Count, listing the whole bucket on every metrics scrape is slow
+	countMutex sync.Mutex
+	count      int
+	countedAt  time.Time
+	countTTL   time.Duration
+}
+
+// NewS3ImageStore returns a new S3 image store
+func NewS3ImageStore(config S3Config) (*S3ImageStore, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("s3 endpoint is not provided")
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is not provided")
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse s3 endpoint: %w", err)
+	}
+
+	region := config.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	partSize := config.PartSize
+	if partSize <= 0 {
+		partSize = s3MinPartSize
+	}
+	if partSize < s3MinPartSize {
+		// S3 rejects the completion of a multipart upload with a smaller part that is not the last one
+		return nil, fmt.Errorf("s3 part size %d is smaller than the minimum %d", partSize, s3MinPartSize)
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	countTTL := config.CountTTL
+	if countTTL <= 0 {
+		countTTL = defaultS3CountTTL
+	}
+
+	return &S3ImageStore{
+		client:   client,
+		endpoint: endpoint,
+		bucket:   config.Bucket,
+		prefix:   config.Prefix,
+		partSize: partSize,
+		creds: sigV4Credentials{
+			AccessKeyID:     config.AccessKeyID,
+			SecretAccessKey: config.SecretAccessKey,
+			Region:          region,
+			Service:         "s3",
+		},
+		now:      time.Now,
+		countTTL: countTTL,
+	}, nil
+}
+
+// Save uploads a new laptop image to the bucket
+func (store *S3ImageStore) Save(
+	ctx context.Context,
+	laptopID string,
+	imageType string,
+	imageData bytes.Buffer,
+) (string, error) {
+	ctx, span := tracing.Start(ctx, "S3ImageStore.Save", tracing.WithAttributes(tracing.String("laptop.id", laptopID)))
+	defer span.End()
+
+	imageID, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("cannot generate image id: %w", err)
+	}
+
+	key := store.imageKey(laptopID, imageID.String(), imageType)
+	data := imageData.Bytes()
+
+	if len(data) > store.partSize {
+		err = store.multipartUpload(ctx, key, data)
+	} else {
+		err = store.putObject(ctx, key, data)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	store.addCount(1)
+	return imageID.String(), nil
+}
+
+// ListByLaptop returns the IDs of the images of a laptop
+func (store *S3ImageStore) ListByLaptop(ctx context.Context, laptopID string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "S3ImageStore.ListByLaptop", tracing.WithAttributes(tracing.String("laptop.id", laptopID)))
+	defer span.End()
+
+	keys, err := store.listObjects(ctx, store.laptopPrefix(laptopID))
+	if err != nil {
+		return nil, err
+	}
+
+	imageIDs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		imageIDs = append(imageIDs, imageIDOfKey(key))
+	}
+	return imageIDs, nil
+}
+
+// DeleteByLaptop deletes the objects of all images of a laptop.
+// When a delete fails, the images deleted so far are reported together with the error.
+func (store *S3ImageStore) DeleteByLaptop(ctx context.Context, laptopID string) (int, error) {
+	ctx, span := tracing.Start(ctx, "S3ImageStore.DeleteByLaptop", tracing.WithAttributes(tracing.String("laptop.id", laptopID)))
+	defer span.End()
+
+	keys, err := store.listObjects(ctx, store.laptopPrefix(laptopID))
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	defer func() { store.addCount(-deleted) }()
+	for _, key := range keys {
+		req, err := store.newRequest(ctx, http.MethodDelete, key, nil, nil)
+		if err != nil {
+			return deleted, err
+		}
+
+		res, err := store.do(req, nil)
+		if err != nil {
+			return deleted, fmt.Errorf("cannot delete image object: %w", err)
+		}
+		res.Body.Close()
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// Count returns the number of images in the bucket under the prefix of the store. The bucket is
+// listed again once CountTTL has passed, in between the count follows the images saved and
+// deleted by this store.
+func (store *S3ImageStore) Count(ctx context.Context) (int, error) {
+	store.countMutex.Lock()
+	defer store.countMutex.Unlock()
+
+	now := store.now()
+	if !store.countedAt.IsZero() && now.Sub(store.countedAt) < store.countTTL {
+		return store.count, nil
+	}
+
+	keys, err := store.listObjects(ctx, store.prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, key := range keys {
+		// objects outside of the <laptopID>/<imageID><ext> layout are not images
+		if strings.Contains(strings.TrimPrefix(key, store.prefix), "/") {
+			count++
+		}
+	}
+
+	store.count = count
+	store.countedAt = now
+	return count, nil
+}
+
+// addCount changes the cached count of images, if there is one, by delta
+func (store *S3ImageStore) addCount(delta int) {
+	store.countMutex.Lock()
+	defer store.countMutex.Unlock()
+
+	if !store.countedAt.IsZero() {
+		store.count = max(store.count+delta, 0)
+	}
+}
+
+// PresignGetURL returns a URL that can be used to download an image of a laptop without
+// credentials until it expires. The image is looked up in the bucket to find its extension.
+func (store *S3ImageStore) PresignGetURL(ctx context.Context, laptopID string, imageID string, expires time.Duration) (string, error) {
+	keys, err := store.listObjects(ctx, store.laptopPrefix(laptopID)+imageID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, key := range keys {
+		if imageIDOfKey(key) == imageID {
+			return store.creds.presignURL(http.MethodGet, store.objectURL(key, nil), expires, store.now())
+		}
+	}
+	return "", fmt.Errorf("image %s of laptop %s is not found", imageID, laptopID)
+}
+
+// imageKey returns the object key of an image, <prefix><laptopID>/<imageID><ext>
+func (store *S3ImageStore) imageKey(laptopID string, imageID string, imageType string) string {
+	return store.laptopPrefix(laptopID) + imageID + imageType
+}
+
+// laptopPrefix returns the key prefix of all images of a laptop
+func (store *S3ImageStore) laptopPrefix(laptopID string) string {
+	return store.prefix + laptopID + "/"
+}
+
+// imageIDOfKey returns the image ID of an object key, which is its base name without the extension
+func imageIDOfKey(key string) string {
+	name := key[strings.LastIndex(key, "/")+1:]
+	if dot := strings.Index(name, "."); dot >= 0 {
+		name = name[:dot]
+	}
+	return name
+}
+
+// listObjects returns the keys of all objects that start with prefix, following the pages of ListObjectsV2
+func (store *S3ImageStore) listObjects(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	continuationToken := ""
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {prefix},
+		}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		req, err := store.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := store.do(req, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list image objects: %w", err)
+		}
+
+		result := listBucketResult{}
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode list objects response: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated {
+			return keys, nil
+		}
+		if result.NextContinuationToken == "" {
+			return nil, fmt.Errorf("list objects response is truncated without a continuation token")
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+// Ping checks that the bucket exists and the credentials are accepted
+func (store *S3ImageStore) Ping(ctx context.Context) error {
+	req, err := store.newRequest(ctx, http.MethodHead, "", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := store.do(req, nil)
+	if err != nil {
+		return fmt.Errorf("cannot reach bucket: %w", err)
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (store *S3ImageStore) putObject(ctx context.Context, key string, data []byte) error {
+	req, err := store.newRequest(ctx, http.MethodPut, key, nil, data)
+	if err != nil {
+		return err
+	}
+
+	res, err := store.do(req, data)
+	if err != nil {
+		return fmt.Errorf("cannot put image object: %w", err)
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (store *S3ImageStore) multipartUpload(ctx context.Context, key string, data []byte) error {
+	uploadID, err := store.createMultipartUpload(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	complete := completeMultipartUpload{}
+	for offset, number := 0, 1; offset < len(data); offset, number = offset+store.partSize, number+1 {
+		end := offset + store.partSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		etag, err := store.uploadPart(ctx, key, uploadID, number, data[offset:end])
+		if err != nil {
+			store.abortMultipartUpload(ctx, key, uploadID)
+			return err
+		}
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: number, ETag: etag})
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		store.abortMultipartUpload(ctx, key, uploadID)
+		return fmt.Errorf("cannot marshal complete multipart upload: %w", err)
+	}
+
+	req, err := store.newRequest(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body)
+	if err != nil {
+		store.abortMultipartUpload(ctx, key, uploadID)
+		return err
+	}
+
+	res, err := store.do(req, body)
+	if err != nil {
+		store.abortMultipartUpload(ctx, key, uploadID)
+		return fmt.Errorf("cannot complete multipart upload: %w", err)
+	}
+	defer res.Body.Close()
+
+	// S3 may report an error in the body of a 200 response to CompleteMultipartUpload
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		store.abortMultipartUpload(ctx, key, uploadID)
+		return fmt.Errorf("cannot read complete multipart upload response: %w", err)
+	}
+	if s3Err := parseS3Error(resBody); s3Err != nil {
+		store.abortMultipartUpload(ctx, key, uploadID)
+		return fmt.Errorf("cannot complete multipart upload: %w", s3Err)
+	}
+
+	return nil
+}
+
+func (store *S3ImageStore) createMultipartUpload(ctx context.Context, key string) (string, error) {
+	req, err := store.newRequest(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := store.do(req, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot create multipart upload: %w", err)
+	}
+	defer res.Body.Close()
+
+	result := initiateMultipartUploadResult{}
+	err = xml.NewDecoder(res.Body).Decode(&result)
+	if err != nil {
+		return "", fmt.Errorf("cannot decode create multipart upload response: %w", err)
+	}
+	if result.UploadID == "" {
+		return "", fmt.Errorf("create multipart upload response has no upload id")
+	}
+
+	return result.UploadID, nil
+}
+
+func (store *S3ImageStore) uploadPart(ctx context.Context, key string, uploadID string, number int, data []byte) (string, error) {
+	query := url.Values{
+		"partNumber": {strconv.Itoa(number)},
+		"uploadId":   {uploadID},
+	}
+	req, err := store.newRequest(ctx, http.MethodPut, key, query, data)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := store.do(req, data)
+	if err != nil {
+		return "", fmt.Errorf("cannot upload part %d: %w", number, err)
+	}
+	res.Body.Close()
+
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("upload part %d response has no etag", number)
+	}
+	return etag, nil
+}
+
+// abortMultipartUpload aborts an upload even when ctx is canceled, so that no parts are left behind
+func (store *S3ImageStore) abortMultipartUpload(ctx context.Context, key string, uploadID string) {
+	req, err := store.newRequest(context.WithoutCancel(ctx), http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil)
+	if err != nil {
+		return
+	}
+
+	res, err := store.do(req, nil)
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+}
+
+func (store *S3ImageStore) objectURL(key string, query url.Values) string {
+	u := *store.endpoint
+	u.Path = u.Path + "/" + store.bucket + "/" + key
+	u.RawQuery = canonicalQuery(query)
+	return u.String()
+}
+
+func (store *S3ImageStore) newRequest(ctx context.Context, method string, key string, query url.Values, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, store.objectURL(key, query), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create s3 request: %w", err)
+	}
+	req.ContentLength = int64(len(body))
+	return req, nil
+}
+
+// do signs and sends the request and turns non-2xx responses into errors
+func (store *S3ImageStore) do(req *http.Request, body []byte) (*http.Response, error) {
+	_, span := tracing.Start(req.Context(), "S3 "+req.Method,
+		tracing.WithSpanKind(tracing.SpanKindClient),
+		tracing.WithAttributes(
+			tracing.String("http.request.method", req.Method),
+			tracing.String("server.address", req.URL.Host),
+			tracing.String("url.path", req.URL.Path),
+		),
+	)
+	defer span.End()
+
+	store.creds.signRequest(req, sha256Hex(body), store.now())
+
+	res, err := store.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttributes(tracing.Int("http.response.status_code", res.StatusCode))
+	if res.StatusCode/100 != 2 {
+		span.SetStatus(tracing.StatusError, res.Status)
+		defer res.Body.Close()
+		resBody, _ := io.ReadAll(res.Body)
+		if s3Err := parseS3Error(resBody); s3Err != nil {
+			return nil, s3Err
+		}
+		return nil, fmt.Errorf("unexpected s3 status: %s", res.Status)
+	}
+
+	return res, nil
+}
+
+// S3Error is an error returned by the S3 API
+type S3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (err *S3Error) Error() string {
+	return fmt.Sprintf("s3 error %s: %s", err.Code, err.Message)
+}
+
+func parseS3Error(body []byte) *S3Error {
+	if !bytes.Contains(body, []byte("<Error>")) {
+		return nil
+	}
+
+	s3Err := &S3Error{}
+	if err := xml.Unmarshal(body, s3Err); err != nil || s3Err.Code == "" {
+		return nil
+	}
+	return s3Err
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type listBucketResult struct {
+	XMLName  xml.Name `xml:"ListBucketResult"`
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
@@ -0,0 +1,442 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc-project/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3ImageStoreSave(t *testing.T) {
+	t.Parallel()
+
+	s3 := newFakeS3()
+	s3.maxKeys = 2
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	config := service.S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "pcbook",
+		Prefix:          "images/",
+		AccessKeyID:     "test-access-key",
+		SecretAccessKey: "test-secret-key",
+	}
+	store, err := service.NewS3ImageStore(config)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		size      int
+		multipart bool
+	}{
+		{name: "single_put", size: 1000},
+		{name: "multipart", size: 5<<20 + 1000, multipart: true},
+		{name: "another_single_put", size: 10},
+	}
+
+	for _, tc := range testCases {
+		data := bytes.Repeat([]byte{byte(tc.size)}, tc.size)
+
+		imageID, err := store.Save(context.Background(), "laptop-id", ".jpg", *bytes.NewBuffer(data))
+		require.NoError(t, err, tc.name)
+		require.NotEmpty(t, imageID, tc.name)
+
+		object := s3.object("pcbook/images/laptop-id/" + imageID + ".jpg")
+		require.NotNil(t, object, tc.name)
+		require.Equal(t, data, object.data, tc.name)
+		require.Equal(t, tc.multipart, object.multipart, tc.name)
+
+		signedURL, err := store.PresignGetURL(context.Background(), "laptop-id", imageID, time.Minute)
+		require.NoError(t, err, tc.name)
+
+		res, err := http.Get(signedURL)
+		require.NoError(t, err, tc.name)
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		require.NoError(t, err, tc.name)
+		require.Equal(t, http.StatusOK, res.StatusCode, tc.name)
+		require.Equal(t, data, body, tc.name)
+	}
+
+	require.Empty(t, s3.pendingUploads())
+
+	// another replica, or the same one after a restart, sees the images through the bucket
+	otherImageID, err := store.Save(context.Background(), "other-laptop-id", ".png", *bytes.NewBufferString("image"))
+	require.NoError(t, err)
+	replica, err := service.NewS3ImageStore(config)
+	require.NoError(t, err)
+
+	imageIDs, err := replica.ListByLaptop(context.Background(), "laptop-id")
+	require.NoError(t, err)
+	require.Len(t, imageIDs, len(testCases))
+	count, err := replica.Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, len(testCases)+1, count)
+	_, err = replica.PresignGetURL(context.Background(), "other-laptop-id", otherImageID, time.Minute)
+	require.NoError(t, err)
+
+	deleted, err := replica.DeleteByLaptop(context.Background(), "laptop-id")
+	require.NoError(t, err)
+	require.Equal(t, len(testCases), deleted)
+	for _, imageID := range imageIDs {
+		require.Nil(t, s3.object("pcbook/images/laptop-id/"+imageID+".jpg"))
+	}
+
+	imageIDs, err = store.ListByLaptop(context.Background(), "laptop-id")
+	require.NoError(t, err)
+	require.Empty(t, imageIDs)
+	imageIDs, err = store.ListByLaptop(context.Background(), "other-laptop-id")
+	require.NoError(t, err)
+	require.Equal(t, []string{otherImageID}, imageIDs)
+}
+
+func TestS3ImageStoreCachesCount(t *testing.T) {
+	t.Parallel()
+
+	s3 := newFakeS3()
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	config := service.S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "pcbook",
+		AccessKeyID:     "test-access-key",
+		SecretAccessKey: "test-secret-key",
+		CountTTL:        100 * time.Millisecond,
+	}
+	store, err := service.NewS3ImageStore(config)
+	require.NoError(t, err)
+	replica, err := service.NewS3ImageStore(config)
+	require.NoError(t, err)
+
+	_, err = store.Save(context.Background(), "laptop-id", ".jpg", *bytes.NewBufferString("image"))
+	require.NoError(t, err)
+
+	count, err := store.Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// the images of the store are counted without listing the bucket again
+	_, err = store.Save(context.Background(), "laptop-id", ".jpg", *bytes.NewBufferString("image"))
+	require.NoError(t, err)
+	count, err = store.Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Equal(t, 1, s3.listRequests())
+
+	// the images of another replica are counted once the cache expires
+	_, err = replica.Save(context.Background(), "laptop-id", ".jpg", *bytes.NewBufferString("image"))
+	require.NoError(t, err)
+	count, err = store.Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	time.Sleep(150 * time.Millisecond)
+	count, err = store.Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, 2, s3.listRequests())
+
+	deleted, err := store.DeleteByLaptop(context.Background(), "laptop-id")
+	require.NoError(t, err)
+	require.Equal(t, 3, deleted)
+	count, err = store.Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+}
+
+func TestS3ImageStoreAbortsFailedMultipartUpload(t *testing.T) {
+	t.Parallel()
+
+	s3 := newFakeS3()
+	s3.failPart = 2
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	store, err := service.NewS3ImageStore(service.S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "pcbook",
+		AccessKeyID:     "test-access-key",
+		SecretAccessKey: "test-secret-key",
+	})
+	require.NoError(t, err)
+
+	_, err = store.Save(context.Background(), "laptop-id", ".jpg", *bytes.NewBuffer(make([]byte, 11<<20)))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "InternalError")
+	require.Empty(t, s3.pendingUploads())
+}
+
+func TestS3ImageStoreAbortsUnreadableCompleteResponse(t *testing.T) {
+	t.Parallel()
+
+	s3 := newFakeS3()
+	s3.truncateComplete = true
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	store, err := service.NewS3ImageStore(service.S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "pcbook",
+		AccessKeyID:     "test-access-key",
+		SecretAccessKey: "test-secret-key",
+	})
+	require.NoError(t, err)
+
+	_, err = store.Save(context.Background(), "laptop-id", ".jpg", *bytes.NewBuffer(make([]byte, 11<<20)))
+	require.ErrorContains(t, err, "cannot read complete multipart upload response")
+	require.Empty(t, s3.pendingUploads())
+}
+
+func TestNewS3ImageStoreRejectsSmallPartSize(t *testing.T) {
+	t.Parallel()
+
+	_, err := service.NewS3ImageStore(service.S3Config{Endpoint: "http://localhost", Bucket: "pcbook", PartSize: 1024})
+	require.ErrorContains(t, err, "part size")
+
+	_, err = service.NewS3ImageStore(service.S3Config{Endpoint: "http://localhost", Bucket: "pcbook", PartSize: 8 << 20})
+	require.NoError(t, err)
+}
+
+func TestS3ImageStorePresignUnknownImage(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(newFakeS3())
+	defer server.Close()
+
+	store, err := service.NewS3ImageStore(service.S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "pcbook",
+		AccessKeyID:     "test-access-key",
+		SecretAccessKey: "test-secret-key",
+	})
+	require.NoError(t, err)
+
+	_, err = store.PresignGetURL(context.Background(), "laptop-id", "unknown", time.Minute)
+	require.ErrorContains(t, err, "is not found")
+}
+
+type fakeS3Object struct {
+	data      []byte
+	multipart bool
+}
+
+type fakeS3Upload struct {
+	parts map[int][]byte
+}
+
+// fakeS3 is a minimal in-process implementation of the S3 object API used by S3ImageStore
+type fakeS3 struct {
+	mutex    sync.Mutex
+	objects  map[string]*fakeS3Object
+	uploads  map[string]*fakeS3Upload
+	nextID   int
+	failPart int
+	maxKeys  int // keys per page of ListObjectsV2, 1000 when 0
+	lists    int // number of ListObjectsV2 requests
+	// truncateComplete cuts the response to CompleteMultipartUpload short and keeps the upload
+	truncateComplete bool
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		objects: make(map[string]*fakeS3Object),
+		uploads: make(map[string]*fakeS3Upload),
+	}
+}
+
+func (s3 *fakeS3) object(key string) *fakeS3Object {
+	s3.mutex.Lock()
+	defer s3.mutex.Unlock()
+	return s3.objects[key]
+}
+
+func (s3 *fakeS3) listRequests() int {
+	s3.mutex.Lock()
+	defer s3.mutex.Unlock()
+	return s3.lists
+}
+
+func (s3 *fakeS3) pendingUploads() []string {
+	s3.mutex.Lock()
+	defer s3.mutex.Unlock()
+
+	ids := []string{}
+	for id := range s3.uploads {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (s3 *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s3.mutex.Lock()
+	defer s3.mutex.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+
+	if r.Method == http.MethodGet && !query.Has("list-type") {
+		if query.Get("X-Amz-Signature") == "" || query.Get("X-Amz-Algorithm") != "AWS4-HMAC-SHA256" {
+			writeFakeS3Error(w, http.StatusForbidden, "AccessDenied", "request is not presigned")
+			return
+		}
+		object := s3.objects[key]
+		if object == nil {
+			writeFakeS3Error(w, http.StatusNotFound, "NoSuchKey", "key does not exist")
+			return
+		}
+		w.Write(object.data)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeFakeS3Error(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-access-key/") {
+		writeFakeS3Error(w, http.StatusForbidden, "AccessDenied", "request is not signed")
+		return
+	}
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		writeFakeS3Error(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "payload hash does not match")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet:
+		s3.lists++
+		s3.listObjects(w, key, query)
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		upload := s3.uploads[query.Get("uploadId")]
+		if upload == nil {
+			writeFakeS3Error(w, http.StatusNotFound, "NoSuchUpload", "upload does not exist")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		if number == s3.failPart {
+			writeFakeS3Error(w, http.StatusInternalServerError, "InternalError", "part upload failed")
+			return
+		}
+		upload.parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(body)))
+
+	case r.Method == http.MethodPut:
+		s3.objects[key] = &fakeS3Object{data: body}
+
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s3.nextID++
+		uploadID := strconv.Itoa(s3.nextID)
+		s3.uploads[uploadID] = &fakeS3Upload{parts: make(map[int][]byte)}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, uploadID)
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		upload := s3.uploads[query.Get("uploadId")]
+		if upload == nil {
+			writeFakeS3Error(w, http.StatusNotFound, "NoSuchUpload", "upload does not exist")
+			return
+		}
+		complete := struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}{}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			writeFakeS3Error(w, http.StatusBadRequest, "MalformedXML", err.Error())
+			return
+		}
+		if s3.truncateComplete {
+			w.Header().Set("Content-Length", "1000")
+			w.Write([]byte("<CompleteMultipartUploadResult>"))
+			return
+		}
+		numbers := []int{}
+		for _, part := range complete.Parts {
+			numbers = append(numbers, part.PartNumber)
+		}
+		sort.Ints(numbers)
+		data := []byte{}
+		for _, number := range numbers {
+			data = append(data, upload.parts[number]...)
+		}
+		s3.objects[key] = &fakeS3Object{data: data, multipart: true}
+		delete(s3.uploads, query.Get("uploadId"))
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>", key)
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(s3.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodHead && key == "pcbook/":
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodDelete:
+		delete(s3.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeFakeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+// listObjects writes a page of ListObjectsV2, the continuation token is the last key of the previous page
+func (s3 *fakeS3) listObjects(w http.ResponseWriter, bucket string, query url.Values) {
+	if query.Get("list-type") != "2" {
+		writeFakeS3Error(w, http.StatusBadRequest, "InvalidArgument", "only list-type 2 is supported")
+		return
+	}
+
+	keys := []string{}
+	for key := range s3.objects {
+		key = strings.TrimPrefix(key, bucket)
+		if strings.HasPrefix(key, query.Get("prefix")) && key > query.Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	maxKeys := s3.maxKeys
+	if maxKeys == 0 {
+		maxKeys = 1000
+	}
+	truncated := len(keys) > maxKeys
+	if truncated {
+		keys = keys[:maxKeys]
+	}
+
+	fmt.Fprint(w, "<ListBucketResult>")
+	for _, key := range keys {
+		fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+	}
+	fmt.Fprintf(w, "<IsTruncated>%t</IsTruncated>", truncated)
+	if truncated {
+		fmt.Fprintf(w, "<NextContinuationToken>%s</NextContinuationToken>", keys[len(keys)-1])
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+}
+
+func writeFakeS3Error(w http.ResponseWriter, code int, s3Code string, message string) {
+	w.WriteHeader(code)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", s3Code, message)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"grpc-project/tracing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// TracingInterceptor is a server interceptor that continues the trace of the caller,
+// or starts a new one, with a server span around every request
+type TracingInterceptor struct {
+	tracer *tracing.Tracer
+}
+
+// NewTracingInterceptor returns a new tracing interceptor that records spans with tracer
+func NewTracingInterceptor(tracer *tracing.Tracer) *TracingInterceptor {
+	return &TracingInterceptor{tracer: tracer}
+}
+
+// Unary returns a server interceptor function to trace unary RPC
+func (interceptor *TracingInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, span := interceptor.startSpan(ctx, info.FullMethod)
+		defer span.End()
+
+		res, err := handler(ctx, req)
+		endServerSpan(span, err)
+		return res, err
+	}
+}
+
+// Stream returns a server interceptor function to trace stream RPC
+func (interceptor *TracingInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, span := interceptor.startSpan(stream.Context(), info.FullMethod)
+		defer span.End()
+
+		err := handler(srv, &serverStreamWithContext{ServerStream: stream, ctx: ctx})
+		endServerSpan(span, err)
+		return err
+	}
+}
+
+// startSpan starts the server span of a request, as a child of the traceparent sent by the client
+func (interceptor *TracingInterceptor) startSpan(ctx context.Context, fullMethod string) (context.Context, *tracing.Span) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		values := md[tracing.TraceparentKey]
+		if len(values) > 0 {
+			sc, err := tracing.ParseTraceparent(values[0])
+			if err == nil {
+				ctx = tracing.ContextWithRemoteSpanContext(ctx, sc)
+			}
+		}
+	}
+
+	name := strings.TrimPrefix(fullMethod, "/")
+	service, method, _ := strings.Cut(name, "/")
+	attrs := []tracing.Attribute{
+		tracing.String("rpc.system", "grpc"),
+		tracing.String("rpc.service", service),
+		tracing.String("rpc.method", method),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, tracing.String("client.address", p.Addr.String()))
+	}
+
+	return interceptor.tracer.Start(ctx, name, tracing.WithSpanKind(tracing.SpanKindServer), tracing.WithAttributes(attrs...))
+}
+
+func endServerSpan(span *tracing.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(tracing.Int("rpc.grpc.status_code", int(code)))
+	if code != codes.OK {
+		span.SetStatus(tracing.StatusError, status.Convert(err).Message())
+	}
+}
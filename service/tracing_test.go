@@ -0,0 +1,108 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"grpc-project/client"
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/service"
+	"grpc-project/tracing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestTracingInterceptors(t *testing.T) {
+	t.Parallel()
+
+	serverSpans := &testLogBuffer{}
+	serverTracing := service.NewTracingInterceptor(tracing.NewTracer(tracing.NewStdoutExporter(serverSpans)))
+	serverLogs := &testLogBuffer{}
+	logging := service.NewLoggingInterceptor(slog.New(slog.NewJSONHandler(serverLogs, nil)))
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	serverAddress := startTestLaptopServer(
+		t, laptopStore, nil, nil,
+		grpc.ChainUnaryInterceptor(serverTracing.Unary(), logging.Unary()),
+		grpc.ChainStreamInterceptor(serverTracing.Stream(), logging.Stream()),
+	)
+
+	clientSpans := &testLogBuffer{}
+	clientTracing := client.NewTracingInterceptor(tracing.NewTracer(tracing.NewStdoutExporter(clientSpans)))
+	conn, err := grpc.NewClient(
+		serverAddress,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(clientTracing.Unary()),
+		grpc.WithStreamInterceptor(clientTracing.Stream()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	laptopClient := pb.NewLaptopServiceClient(conn)
+
+	laptop := sample.NewLaptop()
+	_, err = laptopClient.CreateLaptop(client.WithRequestID(context.Background(), "req-create"), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	stream, err := laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{Filter: &pb.Filter{}})
+	require.NoError(t, err)
+	for {
+		_, err = stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	const laptopService = "techshcool.pcbook.LaptopService/"
+	clientCreate := findSpan(t, clientSpans, laptopService+"CreateLaptop")
+	serverCreate := findSpan(t, serverSpans, laptopService+"CreateLaptop")
+	storeSave := findSpan(t, serverSpans, "InMemoryLaptopStore.Save")
+
+	require.Equal(t, "client", clientCreate["kind"])
+	require.Equal(t, "server", serverCreate["kind"])
+	require.Equal(t, clientCreate["trace_id"], serverCreate["trace_id"])
+	require.Equal(t, clientCreate["span_id"], serverCreate["parent_span_id"])
+	require.Equal(t, serverCreate["trace_id"], storeSave["trace_id"])
+	require.Equal(t, serverCreate["span_id"], storeSave["parent_span_id"])
+	require.Equal(t, laptop.GetId(), storeSave["attributes"].(map[string]interface{})["laptop.id"])
+	require.EqualValues(t, 0, serverCreate["attributes"].(map[string]interface{})["rpc.grpc.status_code"])
+
+	saved := serverLogs.find(t, "saved laptop", "req-create")
+	require.Equal(t, clientCreate["trace_id"], saved["trace_id"])
+
+	clientSearch := findSpan(t, clientSpans, laptopService+"SearchLaptop")
+	serverSearch := findSpan(t, serverSpans, laptopService+"SearchLaptop")
+	storeSearch := findSpan(t, serverSpans, "InMemoryLaptopStore.Search")
+
+	require.NotEqual(t, clientCreate["trace_id"], clientSearch["trace_id"])
+	require.Equal(t, clientSearch["span_id"], serverSearch["parent_span_id"])
+	require.Equal(t, serverSearch["span_id"], storeSearch["parent_span_id"])
+	require.Equal(t, "unset", clientSearch["status"])
+}
+
+// findSpan returns the span with the given name written by a stdout exporter
+func findSpan(t *testing.T, spans *testLogBuffer, name string) map[string]interface{} {
+	spans.mutex.Lock()
+	defer spans.mutex.Unlock()
+
+	for _, line := range bytes.Split(spans.buffer.Bytes(), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		span := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(line, &span))
+		if span["name"] == name {
+			return span
+		}
+	}
+
+	require.Failf(t, "span not found", "%q in:\n%s", name, spans.buffer.String())
+	return nil
+}
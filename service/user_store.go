@@ -1,16 +1,21 @@
 package service
 
-import "sync" // เรียกใช้งาน package sync เพื่อใช้ mutex สำหรับการจัดการ concurrent access
+import (
+	"context" // เรียกใช้งาน package context เพื่อส่งต่อ context ของคำขอไปยัง store
+	"sync"    // เรียกใช้งาน package sync เพื่อใช้ mutex สำหรับการจัดการ concurrent access
+
+	"grpc-project/tracing" // เรียกใช้งาน package tracing เพื่อสร้าง span รอบการทำงานของ store
+)
 
 // UserStore is an interface to store users
 // UserStore เป็น interface สำหรับการเก็บข้อมูลผู้ใช้
 type UserStore interface {
 	// Save saves a user to the store
 	// Save เก็บข้อมูลผู้ใช้ไปยัง store
-	Save(user *User) error
+	Save(ctx context.Context, user *User) error
 	// Find finds a user by username
 	// Find ค้นหาผู้ใช้โดยใช้ชื่อผู้ใช้
-	Find(username string) (*User, error)
+	Find(ctx context.Context, username string) (*User, error)
 }
 
 // InMemoryUserStore stores users in memory
@@ -30,7 +35,10 @@ func NewInMemoryUserStore() *InMemoryUserStore {
 
 // Save saves a user to the store
 // Save เก็บข้อมูลผู้ใช้ไปยัง store
-func (store *InMemoryUserStore) Save(user *User) error {
+func (store *InMemoryUserStore) Save(ctx context.Context, user *User) error {
+	_, span := tracing.Start(ctx, "InMemoryUserStore.Save")
+	defer span.End()
+
 	store.mutex.Lock() // ล็อค mutex เพื่อป้องกันการเข้าถึงพร้อมกัน
 	defer store.mutex.Unlock() // ปลดล็อค mutex เมื่อฟังก์ชันทำงานเสร็จ
 
@@ -45,7 +53,10 @@ func (store *InMemoryUserStore) Save(user *User) error {
 
 // Find finds a user by username
 // Find ค้นหาผู้ใช้โดยใช้ชื่อผู้ใช้
-func (store *InMemoryUserStore) Find(username string) (*User, error) {
+func (store *InMemoryUserStore) Find(ctx context.Context, username string) (*User, error) {
+	_, span := tracing.Start(ctx, "InMemoryUserStore.Find")
+	defer span.End()
+
 	store.mutex.RLock() // ล็อค mutex เพื่อป้องกันการเข้าถึงพร้อมกันแบบอ่านอย่างเดียว
 	defer store.mutex.RUnlock() // ปลดล็อค mutex เมื่อฟังก์ชันทำงานเสร็จ
 
@@ -0,0 +1,178 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StdoutExporter writes every span as a line of JSON meant to be read by people
+type StdoutExporter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewStdoutExporter returns a new exporter that writes spans to w, usually os.Stdout
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	return &StdoutExporter{encoder: json.NewEncoder(w)}
+}
+
+type stdoutSpan struct {
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Start        time.Time              `json:"start"`
+	Duration     string                 `json:"duration"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Status       string                 `json:"status"`
+	Message      string                 `json:"message,omitempty"`
+}
+
+// ExportSpan writes the span
+func (exporter *StdoutExporter) ExportSpan(span *SpanData) error {
+	out := stdoutSpan{
+		Name:     span.Name,
+		Kind:     span.Kind.String(),
+		TraceID:  span.SpanContext.TraceID.String(),
+		SpanID:   span.SpanContext.SpanID.String(),
+		Start:    span.Start,
+		Duration: span.End.Sub(span.Start).String(),
+		Status:   span.StatusCode.String(),
+		Message:  span.StatusMessage,
+	}
+	if span.ParentSpanID.IsValid() {
+		out.ParentSpanID = span.ParentSpanID.String()
+	}
+	if len(span.Attributes) > 0 {
+		out.Attributes = make(map[string]interface{}, len(span.Attributes))
+		for _, attr := range span.Attributes {
+			out.Attributes[attr.Key] = attr.Value
+		}
+	}
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	return exporter.encoder.Encode(out)
+}
+
+// OTLPFileExporter writes spans in the OTLP/JSON format, one TracesData object per line,
+// like the file exporter of the OpenTelemetry collector. The files can be replayed into
+// any OTLP backend.
+type OTLPFileExporter struct {
+	mutex       sync.Mutex
+	encoder     *json.Encoder
+	serviceName string
+}
+
+// NewOTLPFileExporter returns a new exporter that writes the spans of serviceName to w
+func NewOTLPFileExporter(w io.Writer, serviceName string) *OTLPFileExporter {
+	return &OTLPFileExporter{encoder: json.NewEncoder(w), serviceName: serviceName}
+}
+
+type otlpTracesData struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue is an AnyValue, exactly one of the fields is set.
+// 64-bit integers are strings in OTLP/JSON.
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// ExportSpan writes the span
+func (exporter *OTLPFileExporter) ExportSpan(span *SpanData) error {
+	out := otlpSpan{
+		TraceID:           span.SpanContext.TraceID.String(),
+		SpanID:            span.SpanContext.SpanID.String(),
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+		Attributes:        otlpAttributes(span.Attributes),
+		Status:            otlpStatus{Code: span.StatusCode, Message: span.StatusMessage},
+	}
+	if span.ParentSpanID.IsValid() {
+		out.ParentSpanID = span.ParentSpanID.String()
+	}
+
+	data := otlpTracesData{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttributes([]Attribute{String("service.name", exporter.serviceName)})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "grpc-project/tracing"},
+			Spans: []otlpSpan{out},
+		}},
+	}}}
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	return exporter.encoder.Encode(data)
+}
+
+func otlpAttributes(attrs []Attribute) []otlpKeyValue {
+	keyValues := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		keyValue := otlpKeyValue{Key: attr.Key}
+		switch value := attr.Value.(type) {
+		case string:
+			keyValue.Value.StringValue = &value
+		case bool:
+			keyValue.Value.BoolValue = &value
+		case int64:
+			s := strconv.FormatInt(value, 10)
+			keyValue.Value.IntValue = &s
+		case float64:
+			keyValue.Value.DoubleValue = &value
+		default:
+			continue
+		}
+		keyValues = append(keyValues, keyValue)
+	}
+	return keyValues
+}
@@ -0,0 +1,381 @@
+// Package tracing records spans of work done for a request and propagates the trace
+// between processes with the W3C traceparent header
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentKey is the header, or gRPC metadata key, that carries the W3C trace context
+const TraceparentKey = "traceparent"
+
+// TraceID identifies a trace, all spans of a request share it
+type TraceID [16]byte
+
+// String returns the ID as 32 lowercase hex digits
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the ID is not all zeros
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the ID as 16 lowercase hex digits
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the ID is not all zeros
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// SpanContext is the part of a span that is propagated to other processes
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats the span context as a version 00 traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ErrInvalidTraceparent is returned when a traceparent header cannot be parsed
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses a traceparent header value.
+// Versions above 00 are accepted as long as they start with the version 00 fields.
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	sc := SpanContext{}
+	_, err = hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	if err != nil || strings.ToLower(parts[1]) != parts[1] {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	_, err = hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	if err != nil || strings.ToLower(parts[2]) != parts[2] {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	sc.Sampled = flags[0]&0x01 == 0x01
+	return sc, nil
+}
+
+// SpanKind tells whether a span is a server or client side of an RPC, or internal work.
+// The values are the ones used by OTLP.
+type SpanKind int
+
+// Span kinds
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// String returns the lowercase name of the kind
+func (kind SpanKind) String() string {
+	switch kind {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// StatusCode is the status of a finished span, the values are the ones used by OTLP
+type StatusCode int
+
+// Status codes
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// String returns the lowercase name of the status code
+func (code StatusCode) String() string {
+	switch code {
+	case StatusOK:
+		return "ok"
+	case StatusError:
+		return "error"
+	default:
+		return "unset"
+	}
+}
+
+// Attribute is a key-value pair that describes a span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+// Float64 returns a floating point attribute
+func Float64(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData is a finished span as it is given to the exporter
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	StatusCode    StatusCode
+	StatusMessage string
+}
+
+// Exporter sends finished spans to a backend
+type Exporter interface {
+	ExportSpan(span *SpanData) error
+}
+
+// Tracer creates spans and exports them when they end
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer returns a new tracer that exports spans with exporter
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// SpanOption configures a new span
+type SpanOption func(data *SpanData)
+
+// WithSpanKind sets the kind of a new span, spans are internal by default
+func WithSpanKind(kind SpanKind) SpanOption {
+	return func(data *SpanData) {
+		data.Kind = kind
+	}
+}
+
+// WithAttributes sets attributes of a new span
+func WithAttributes(attrs ...Attribute) SpanOption {
+	return func(data *SpanData) {
+		data.Attributes = append(data.Attributes, attrs...)
+	}
+}
+
+// Start starts a span that is a child of the span in ctx, or of the remote span context in ctx.
+// If there is neither, the span starts a new trace.
+// The returned context carries the new span, End must be called when the work is done.
+func (tracer *Tracer) Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	span := &Span{
+		tracer: tracer,
+		data:   SpanData{Name: name, Kind: SpanKindInternal, Start: time.Now()},
+	}
+	for _, opt := range opts {
+		opt(&span.data)
+	}
+
+	if parent.IsValid() {
+		span.data.SpanContext = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+		span.data.ParentSpanID = parent.SpanID
+	} else {
+		span.data.SpanContext = SpanContext{TraceID: newTraceID(), Sampled: true}
+	}
+	span.data.SpanContext.SpanID = newSpanID()
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Start starts a child of the span in ctx with the tracer of that span.
+// If ctx carries no span, nothing is recorded and the returned span is a no-op.
+func Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	parent, ok := ctx.Value(spanKey{}).(*Span)
+	if !ok || parent.tracer == nil {
+		return ctx, noopSpan
+	}
+	return parent.tracer.Start(ctx, name, opts...)
+}
+
+// Span is an operation that is part of a trace
+type Span struct {
+	tracer *Tracer
+	mutex  sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// noopSpan is returned when there is no trace to add a span to
+var noopSpan = &Span{}
+
+type spanKey struct{}
+
+type remoteSpanContextKey struct{}
+
+// SpanFromContext returns the span carried by ctx, or a no-op span if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, ok := ctx.Value(spanKey{}).(*Span)
+	if !ok {
+		return noopSpan
+	}
+	return span
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx that carries a span context received from
+// another process, the next span started with ctx becomes its child
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteSpanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of the span in ctx,
+// or the remote span context carried by ctx
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok && span.tracer != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteSpanContextKey{}).(SpanContext)
+	return sc
+}
+
+// SpanContext returns the span context of the span
+func (span *Span) SpanContext() SpanContext {
+	return span.data.SpanContext
+}
+
+// IsRecording reports whether the span will be exported when it ends
+func (span *Span) IsRecording() bool {
+	return span.tracer != nil && span.data.SpanContext.Sampled
+}
+
+// SetAttributes adds attributes to the span
+func (span *Span) SetAttributes(attrs ...Attribute) {
+	if !span.IsRecording() {
+		return
+	}
+
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.data.Attributes = append(span.data.Attributes, attrs...)
+}
+
+// SetStatus sets the status of the span, the message is only kept for errors
+func (span *Span) SetStatus(code StatusCode, message string) {
+	if !span.IsRecording() {
+		return
+	}
+
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.data.StatusCode = code
+	span.data.StatusMessage = ""
+	if code == StatusError {
+		span.data.StatusMessage = message
+	}
+}
+
+// RecordError marks the span as failed with err, a nil error is ignored
+func (span *Span) RecordError(err error) {
+	if err != nil {
+		span.SetStatus(StatusError, err.Error())
+	}
+}
+
+// End finishes the span and exports it, calls after the first one are ignored
+func (span *Span) End() {
+	if !span.IsRecording() {
+		return
+	}
+
+	span.mutex.Lock()
+	if span.ended {
+		span.mutex.Unlock()
+		return
+	}
+	span.ended = true
+	span.data.End = time.Now()
+	data := span.data
+	span.mutex.Unlock()
+
+	err := span.tracer.exporter.ExportSpan(&data)
+	if err != nil {
+		slog.Warn("cannot export span", "span", data.Name, "error", err)
+	}
+}
+
+func newTraceID() TraceID {
+	id := TraceID{}
+	for !id.IsValid() {
+		mustRead(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	id := SpanID{}
+	for !id.IsValid() {
+		mustRead(id[:])
+	}
+	return id
+}
+
+func mustRead(b []byte) {
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(fmt.Sprintf("tracing: cannot generate ID: %v", err))
+	}
+}
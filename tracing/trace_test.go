@@ -0,0 +1,154 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"grpc-project/tracing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	sc, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	require.True(t, sc.Sampled)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	sc, err = tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.NoError(t, err)
+	require.False(t, sc.Sampled)
+
+	// a future version may add fields after the flags
+	_, err = tracing.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	require.NoError(t, err)
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		_, err := tracing.ParseTraceparent(value)
+		require.ErrorIs(t, err, tracing.ErrInvalidTraceparent, value)
+	}
+}
+
+func TestTracerStart(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	tracer := tracing.NewTracer(tracing.NewStdoutExporter(&output))
+
+	// without a span in ctx, nothing is recorded
+	_, span := tracing.Start(context.Background(), "orphan")
+	require.False(t, span.IsRecording())
+	span.End()
+	require.Zero(t, output.Len())
+
+	ctx, root := tracer.Start(context.Background(), "root", tracing.WithSpanKind(tracing.SpanKindServer))
+	_, child := tracing.Start(ctx, "child", tracing.WithAttributes(tracing.String("laptop.id", "42")))
+	child.RecordError(errors.New("not found"))
+	child.End()
+	child.End()
+	root.End()
+
+	require.Equal(t, root.SpanContext().TraceID, child.SpanContext().TraceID)
+	require.NotEqual(t, root.SpanContext().SpanID, child.SpanContext().SpanID)
+
+	decoder := json.NewDecoder(&output)
+	spans := []map[string]interface{}{}
+	for decoder.More() {
+		span := map[string]interface{}{}
+		require.NoError(t, decoder.Decode(&span))
+		spans = append(spans, span)
+	}
+	require.Len(t, spans, 2)
+
+	require.Equal(t, "child", spans[0]["name"])
+	require.Equal(t, "internal", spans[0]["kind"])
+	require.Equal(t, root.SpanContext().SpanID.String(), spans[0]["parent_span_id"])
+	require.Equal(t, map[string]interface{}{"laptop.id": "42"}, spans[0]["attributes"])
+	require.Equal(t, "error", spans[0]["status"])
+	require.Equal(t, "not found", spans[0]["message"])
+
+	require.Equal(t, "root", spans[1]["name"])
+	require.Equal(t, "server", spans[1]["kind"])
+	require.NotContains(t, spans[1], "parent_span_id")
+}
+
+func TestTracerRemoteParent(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	tracer := tracing.NewTracer(tracing.NewStdoutExporter(&output))
+
+	remote, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	_, span := tracer.Start(tracing.ContextWithRemoteSpanContext(context.Background(), remote), "server")
+	require.Equal(t, remote.TraceID, span.SpanContext().TraceID)
+	require.True(t, span.IsRecording())
+	span.End()
+	require.Contains(t, output.String(), `"parent_span_id":"00f067aa0ba902b7"`)
+
+	// the caller decided not to sample the trace, the IDs are still propagated
+	output.Reset()
+	remote.Sampled = false
+	ctx, span := tracer.Start(tracing.ContextWithRemoteSpanContext(context.Background(), remote), "server")
+	require.False(t, span.IsRecording())
+	require.Equal(t, remote.TraceID, tracing.SpanContextFromContext(ctx).TraceID)
+	span.End()
+	require.Zero(t, output.Len())
+}
+
+func TestOTLPFileExporter(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	tracer := tracing.NewTracer(tracing.NewOTLPFileExporter(&output, "pcbook-test"))
+
+	_, span := tracer.Start(context.Background(), "techshcool.pcbook.LaptopService/CreateLaptop",
+		tracing.WithSpanKind(tracing.SpanKindClient),
+		tracing.WithAttributes(tracing.Int("rpc.grpc.status_code", 5), tracing.Bool("retry", false)),
+	)
+	span.SetStatus(tracing.StatusError, "laptop not found")
+	span.End()
+
+	var data struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []map[string]interface{} `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []map[string]interface{} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &data))
+	require.Len(t, data.ResourceSpans, 1)
+	require.Equal(t, []map[string]interface{}{
+		{"key": "service.name", "value": map[string]interface{}{"stringValue": "pcbook-test"}},
+	}, data.ResourceSpans[0].Resource.Attributes)
+
+	otlpSpan := data.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	require.Equal(t, span.SpanContext().TraceID.String(), otlpSpan["traceId"])
+	require.Equal(t, span.SpanContext().SpanID.String(), otlpSpan["spanId"])
+	require.EqualValues(t, 3, otlpSpan["kind"])
+	require.IsType(t, "", otlpSpan["startTimeUnixNano"])
+	require.Equal(t, map[string]interface{}{"code": float64(2), "message": "laptop not found"}, otlpSpan["status"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"key": "rpc.grpc.status_code", "value": map[string]interface{}{"intValue": "5"}},
+		map[string]interface{}{"key": "retry", "value": map[string]interface{}{"boolValue": false}},
+	}, otlpSpan["attributes"])
+}
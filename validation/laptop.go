@@ -0,0 +1,209 @@
+// Package validation checks the messages sent by clients before they reach the stores.
+// It reports every invalid field at once, so that a client can fix a request in one go.
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"grpc-project/apperror"
+	"grpc-project/example.com/pcbook/pb"
+
+	"github.com/google/uuid"
+)
+
+// MaxNameLength is the maximum number of characters of a brand or a name
+const MaxNameLength = 100
+
+// MinReleaseYear is the earliest release year of a laptop
+const MinReleaseYear = 1970
+
+// ValidateLaptop returns an InvalidArgument error with a field violation for every invalid
+// field of the laptop, or nil if the laptop is valid. An empty ID is valid, the server
+// generates one.
+func ValidateLaptop(laptop *pb.Laptop) error {
+	violations := LaptopViolations("laptop", laptop)
+	if len(violations) > 0 {
+		return apperror.InvalidArgument("laptop is invalid", violations...)
+	}
+	return nil
+}
+
+// LaptopViolations returns the field violations of a laptop, field paths are prefixed with path
+func LaptopViolations(path string, laptop *pb.Laptop) []apperror.FieldViolation {
+	v := &validator{}
+	if laptop == nil {
+		v.add(path, "is required")
+		return v.violations
+	}
+
+	if laptop.GetId() != "" {
+		if _, err := uuid.Parse(laptop.GetId()); err != nil {
+			v.add(path+".id", "must be a valid UUID")
+		}
+	}
+	v.name(path+".brand", laptop.GetBrand())
+	v.name(path+".name", laptop.GetName())
+	v.cpu(path+".cpu", laptop.GetCpu())
+	v.memory(path+".ram", laptop.GetRam())
+
+	for i, gpu := range laptop.GetGpus() {
+		v.gpu(fmt.Sprintf("%s.gpus[%d]", path, i), gpu)
+	}
+
+	if len(laptop.GetStorages()) == 0 {
+		v.add(path+".storages", "must have at least one storage")
+	}
+	for i, storage := range laptop.GetStorages() {
+		v.storage(fmt.Sprintf("%s.storages[%d]", path, i), storage)
+	}
+
+	v.screen(path+".screen", laptop.GetScreen())
+	v.keyboard(path+".keyboard", laptop.GetKeyboard())
+
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		v.positive(path+".weight_kg", weight.WeightKg)
+	case *pb.Laptop_WeightLb:
+		v.positive(path+".weight_lb", weight.WeightLb)
+	default:
+		v.add(path+".weight", "is required")
+	}
+
+	v.positive(path+".price_usd", laptop.GetPriceUsd())
+
+	maxReleaseYear := time.Now().Year() + 1
+	if year := int(laptop.GetReleaseYear()); year < MinReleaseYear || year > maxReleaseYear {
+		v.add(path+".release_year", "must be between %d and %d", MinReleaseYear, maxReleaseYear)
+	}
+
+	return v.violations
+}
+
+// validator collects the violations of a message and its sub-messages
+type validator struct {
+	violations []apperror.FieldViolation
+}
+
+func (v *validator) add(field string, format string, args ...interface{}) {
+	v.violations = append(v.violations, apperror.Violation(field, format, args...))
+}
+
+func (v *validator) name(field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+	} else if utf8.RuneCountInString(value) > MaxNameLength {
+		v.add(field, "must be at most %d characters", MaxNameLength)
+	}
+}
+
+// positive also rejects NaN, which fails every comparison
+func (v *validator) positive(field string, value float64) {
+	if !(value > 0) {
+		v.add(field, "must be positive")
+	}
+}
+
+func (v *validator) frequency(path string, minGhz float64, maxGhz float64) {
+	v.positive(path+".min_ghz", minGhz)
+	if minGhz > 0 && !(maxGhz >= minGhz) {
+		v.add(path+".max_ghz", "must be at least min_ghz (%g)", minGhz)
+	}
+}
+
+func (v *validator) enum(field string, value int32, names map[int32]string) {
+	if _, ok := names[value]; value != 0 && ok {
+		return
+	}
+
+	valid := []string{}
+	for number, name := range names {
+		if number != 0 {
+			valid = append(valid, name)
+		}
+	}
+	sort.Strings(valid)
+	v.add(field, "must be one of %s", strings.Join(valid, ", "))
+}
+
+func (v *validator) cpu(path string, cpu *pb.CPU) {
+	if cpu == nil {
+		v.add(path, "is required")
+		return
+	}
+
+	v.name(path+".brand", cpu.GetBrand())
+	v.name(path+".name", cpu.GetName())
+	if cpu.GetNumberCores() == 0 {
+		v.add(path+".number_cores", "must be positive")
+	} else if cpu.GetNumberThreads() < cpu.GetNumberCores() {
+		v.add(path+".number_threads", "must be at least number_cores (%d)", cpu.GetNumberCores())
+	}
+	v.frequency(path, cpu.GetMinGhz(), cpu.GetMaxGhz())
+}
+
+func (v *validator) gpu(path string, gpu *pb.GPU) {
+	if gpu == nil {
+		v.add(path, "is required")
+		return
+	}
+
+	v.name(path+".brand", gpu.GetBrand())
+	v.name(path+".name", gpu.GetName())
+	v.frequency(path, gpu.GetMinGhz(), gpu.GetMaxGhz())
+	v.memory(path+".memory", gpu.GetMemory())
+}
+
+func (v *validator) memory(path string, memory *pb.Memory) {
+	if memory == nil {
+		v.add(path, "is required")
+		return
+	}
+
+	if memory.GetValue() == 0 {
+		v.add(path+".value", "must be positive")
+	}
+	v.enum(path+".unit", int32(memory.GetUnit()), pb.Memory_Unit_name)
+}
+
+func (v *validator) storage(path string, storage *pb.Storage) {
+	if storage == nil {
+		v.add(path, "is required")
+		return
+	}
+
+	v.enum(path+".driver", int32(storage.GetDriver()), pb.Storage_Driver_name)
+	v.memory(path+".memory", storage.GetMemory())
+}
+
+func (v *validator) screen(path string, screen *pb.Screen) {
+	if screen == nil {
+		v.add(path, "is required")
+		return
+	}
+
+	v.positive(path+".size_inch", float64(screen.GetSizeInch()))
+	if resolution := screen.GetResolution(); resolution == nil {
+		v.add(path+".resolution", "is required")
+	} else {
+		if resolution.GetWidth() == 0 {
+			v.add(path+".resolution.width", "must be positive")
+		}
+		if resolution.GetHeight() == 0 {
+			v.add(path+".resolution.height", "must be positive")
+		}
+	}
+	v.enum(path+".panal", int32(screen.GetPanal()), pb.Screen_Panal_name)
+}
+
+func (v *validator) keyboard(path string, keyboard *pb.Keyboard) {
+	if keyboard == nil {
+		v.add(path, "is required")
+		return
+	}
+
+	v.enum(path+".layout", int32(keyboard.GetLayout()), pb.Keyboard_Layout_name)
+}
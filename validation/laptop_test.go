@@ -0,0 +1,100 @@
+package validation_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"grpc-project/example.com/pcbook/pb"
+	"grpc-project/sample"
+	"grpc-project/validation"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateLaptop(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, validation.ValidateLaptop(sample.NewLaptop()))
+
+	laptop := sample.NewLaptop()
+	laptop.Id = ""
+	require.NoError(t, validation.ValidateLaptop(laptop))
+
+	laptop = sample.NewLaptop()
+	laptop.Id = "invalid-uuid"
+	laptop.Brand = " "
+	laptop.PriceUsd = -1
+	laptop.ReleaseYear = 0
+	laptop.Cpu.NumberCores = 4
+	laptop.Cpu.NumberThreads = 2
+	laptop.Cpu.MinGhz = 3.5
+	laptop.Cpu.MaxGhz = 2.5
+	laptop.Ram.Unit = pb.Memory_UNKNOWN
+	laptop.Gpus = []*pb.GPU{sample.NewGPU()}
+	laptop.Gpus[0].Memory = nil
+	laptop.Storages = []*pb.Storage{sample.NewSSD(), sample.NewHDD(), {Memory: &pb.Memory{Value: 1, Unit: pb.Memory_Unit(42)}}}
+	laptop.Screen.Resolution.Width = 0
+	laptop.Screen.SizeInch = float32(math.NaN())
+	laptop.Keyboard = nil
+	laptop.Weight = &pb.Laptop_WeightLb{WeightLb: 0}
+
+	err := validation.ValidateLaptop(laptop)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, map[string]string{
+		"laptop.id":                      "must be a valid UUID",
+		"laptop.brand":                   "is required",
+		"laptop.cpu.number_threads":      "must be at least number_cores (4)",
+		"laptop.cpu.max_ghz":             "must be at least min_ghz (3.5)",
+		"laptop.ram.unit":                "must be one of BIT, BYTE, GIGABYTE, KILOBYTE, MEGABYTE, TERABYTE",
+		"laptop.gpus[0].memory":          "is required",
+		"laptop.storages[2].driver":      "must be one of HDD, SSD",
+		"laptop.storages[2].memory.unit": "must be one of BIT, BYTE, GIGABYTE, KILOBYTE, MEGABYTE, TERABYTE",
+		"laptop.screen.size_inch":        "must be positive",
+		"laptop.screen.resolution.width": "must be positive",
+		"laptop.keyboard":                "is required",
+		"laptop.weight_lb":               "must be positive",
+		"laptop.price_usd":               "must be positive",
+		"laptop.release_year":            fmt.Sprintf("must be between 1970 and %d", time.Now().Year()+1),
+	}, testViolations(t, err))
+}
+
+func TestValidateLaptopMissingFields(t *testing.T) {
+	t.Parallel()
+
+	err := validation.ValidateLaptop(nil)
+	require.Equal(t, map[string]string{"laptop": "is required"}, testViolations(t, err))
+
+	err = validation.ValidateLaptop(&pb.Laptop{ReleaseYear: 2020})
+	require.Equal(t, map[string]string{
+		"laptop.brand":     "is required",
+		"laptop.name":      "is required",
+		"laptop.cpu":       "is required",
+		"laptop.ram":       "is required",
+		"laptop.storages":  "must have at least one storage",
+		"laptop.screen":    "is required",
+		"laptop.keyboard":  "is required",
+		"laptop.weight":    "is required",
+		"laptop.price_usd": "must be positive",
+	}, testViolations(t, err))
+}
+
+// testViolations returns the descriptions of the field violations of an error by field
+func testViolations(t *testing.T, err error) map[string]string {
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations := map[string]string{}
+			for _, violation := range badRequest.GetFieldViolations() {
+				violations[violation.GetField()] = violation.GetDescription()
+			}
+			return violations
+		}
+	}
+
+	require.Fail(t, "bad request not found", "%v", err)
+	return nil
+}